@@ -0,0 +1,5 @@
+// Package ethparserv1 - generated gRPC client and server of the parser API described in parser.proto.
+// Regenerate with protoc-gen-go and protoc-gen-go-grpc on the PATH.
+package ethparserv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative ethparser/v1/parser.proto
@@ -0,0 +1,641 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: ethparser/v1/parser.proto
+
+package ethparserv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetCurrentBlockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrentBlockRequest) Reset() {
+	*x = GetCurrentBlockRequest{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrentBlockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrentBlockRequest) ProtoMessage() {}
+
+func (x *GetCurrentBlockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrentBlockRequest.ProtoReflect.Descriptor instead.
+func (*GetCurrentBlockRequest) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{0}
+}
+
+type GetCurrentBlockResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Block         int64                  `protobuf:"varint,1,opt,name=block,proto3" json:"block,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCurrentBlockResponse) Reset() {
+	*x = GetCurrentBlockResponse{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCurrentBlockResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCurrentBlockResponse) ProtoMessage() {}
+
+func (x *GetCurrentBlockResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCurrentBlockResponse.ProtoReflect.Descriptor instead.
+func (*GetCurrentBlockResponse) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetCurrentBlockResponse) GetBlock() int64 {
+	if x != nil {
+		return x.Block
+	}
+	return 0
+}
+
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SubscribeRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type SubscribeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// created - false when the address was watched already
+	Created       bool `protobuf:"varint,1,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeResponse) Reset() {
+	*x = SubscribeResponse{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeResponse) ProtoMessage() {}
+
+func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeResponse.ProtoReflect.Descriptor instead.
+func (*SubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SubscribeResponse) GetCreated() bool {
+	if x != nil {
+		return x.Created
+	}
+	return false
+}
+
+type UnsubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnsubscribeRequest) Reset() {
+	*x = UnsubscribeRequest{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnsubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnsubscribeRequest) ProtoMessage() {}
+
+func (x *UnsubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnsubscribeRequest.ProtoReflect.Descriptor instead.
+func (*UnsubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UnsubscribeRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type UnsubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnsubscribeResponse) Reset() {
+	*x = UnsubscribeResponse{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnsubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnsubscribeResponse) ProtoMessage() {}
+
+func (x *UnsubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnsubscribeResponse.ProtoReflect.Descriptor instead.
+func (*UnsubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{5}
+}
+
+type GetTransactionsRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Address string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// from_block, to_block - inclusive block range, 0 leaves the bound open
+	FromBlock     int64 `protobuf:"varint,2,opt,name=from_block,json=fromBlock,proto3" json:"from_block,omitempty"`
+	ToBlock       int64 `protobuf:"varint,3,opt,name=to_block,json=toBlock,proto3" json:"to_block,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransactionsRequest) Reset() {
+	*x = GetTransactionsRequest{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransactionsRequest) ProtoMessage() {}
+
+func (x *GetTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*GetTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetTransactionsRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *GetTransactionsRequest) GetFromBlock() int64 {
+	if x != nil {
+		return x.FromBlock
+	}
+	return 0
+}
+
+func (x *GetTransactionsRequest) GetToBlock() int64 {
+	if x != nil {
+		return x.ToBlock
+	}
+	return 0
+}
+
+type WatchTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Addresses     []string               `protobuf:"bytes,1,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTransactionsRequest) Reset() {
+	*x = WatchTransactionsRequest{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTransactionsRequest) ProtoMessage() {}
+
+func (x *WatchTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*WatchTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchTransactionsRequest) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+// Transaction - quantities are 0x prefixed hex as returned by the node
+type Transaction struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	BlockNumber      string                 `protobuf:"bytes,1,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
+	Hash             string                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	From             string                 `protobuf:"bytes,3,opt,name=from,proto3" json:"from,omitempty"`
+	To               string                 `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`
+	TransactionIndex string                 `protobuf:"bytes,5,opt,name=transaction_index,json=transactionIndex,proto3" json:"transaction_index,omitempty"`
+	// value - wei
+	Value string `protobuf:"bytes,6,opt,name=value,proto3" json:"value,omitempty"`
+	Input string `protobuf:"bytes,7,opt,name=input,proto3" json:"input,omitempty"`
+	// log_index - set on token transfer event logs
+	LogIndex      string `protobuf:"bytes,8,opt,name=log_index,json=logIndex,proto3" json:"log_index,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Transaction) GetBlockNumber() string {
+	if x != nil {
+		return x.BlockNumber
+	}
+	return ""
+}
+
+func (x *Transaction) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *Transaction) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *Transaction) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *Transaction) GetTransactionIndex() string {
+	if x != nil {
+		return x.TransactionIndex
+	}
+	return ""
+}
+
+func (x *Transaction) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *Transaction) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *Transaction) GetLogIndex() string {
+	if x != nil {
+		return x.LogIndex
+	}
+	return ""
+}
+
+type TransactionMatch struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// address - the subscribed address the transaction matched
+	Address       string       `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Transaction   *Transaction `protobuf:"bytes,2,opt,name=transaction,proto3" json:"transaction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionMatch) Reset() {
+	*x = TransactionMatch{}
+	mi := &file_ethparser_v1_parser_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionMatch) ProtoMessage() {}
+
+func (x *TransactionMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_ethparser_v1_parser_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionMatch.ProtoReflect.Descriptor instead.
+func (*TransactionMatch) Descriptor() ([]byte, []int) {
+	return file_ethparser_v1_parser_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TransactionMatch) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *TransactionMatch) GetTransaction() *Transaction {
+	if x != nil {
+		return x.Transaction
+	}
+	return nil
+}
+
+var File_ethparser_v1_parser_proto protoreflect.FileDescriptor
+
+const file_ethparser_v1_parser_proto_rawDesc = "" +
+	"\n" +
+	"\x19ethparser/v1/parser.proto\x12\fethparser.v1\"\x18\n" +
+	"\x16GetCurrentBlockRequest\"/\n" +
+	"\x17GetCurrentBlockResponse\x12\x14\n" +
+	"\x05block\x18\x01 \x01(\x03R\x05block\",\n" +
+	"\x10SubscribeRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\"-\n" +
+	"\x11SubscribeResponse\x12\x18\n" +
+	"\acreated\x18\x01 \x01(\bR\acreated\".\n" +
+	"\x12UnsubscribeRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\"\x15\n" +
+	"\x13UnsubscribeResponse\"l\n" +
+	"\x16GetTransactionsRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x1d\n" +
+	"\n" +
+	"from_block\x18\x02 \x01(\x03R\tfromBlock\x12\x19\n" +
+	"\bto_block\x18\x03 \x01(\x03R\atoBlock\"8\n" +
+	"\x18WatchTransactionsRequest\x12\x1c\n" +
+	"\taddresses\x18\x01 \x03(\tR\taddresses\"\xde\x01\n" +
+	"\vTransaction\x12!\n" +
+	"\fblock_number\x18\x01 \x01(\tR\vblockNumber\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\tR\x04hash\x12\x12\n" +
+	"\x04from\x18\x03 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\tR\x02to\x12+\n" +
+	"\x11transaction_index\x18\x05 \x01(\tR\x10transactionIndex\x12\x14\n" +
+	"\x05value\x18\x06 \x01(\tR\x05value\x12\x14\n" +
+	"\x05input\x18\a \x01(\tR\x05input\x12\x1b\n" +
+	"\tlog_index\x18\b \x01(\tR\blogIndex\"i\n" +
+	"\x10TransactionMatch\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12;\n" +
+	"\vtransaction\x18\x02 \x01(\v2\x19.ethparser.v1.TransactionR\vtransaction2\xc6\x03\n" +
+	"\rParserService\x12^\n" +
+	"\x0fGetCurrentBlock\x12$.ethparser.v1.GetCurrentBlockRequest\x1a%.ethparser.v1.GetCurrentBlockResponse\x12L\n" +
+	"\tSubscribe\x12\x1e.ethparser.v1.SubscribeRequest\x1a\x1f.ethparser.v1.SubscribeResponse\x12R\n" +
+	"\vUnsubscribe\x12 .ethparser.v1.UnsubscribeRequest\x1a!.ethparser.v1.UnsubscribeResponse\x12T\n" +
+	"\x0fGetTransactions\x12$.ethparser.v1.GetTransactionsRequest\x1a\x19.ethparser.v1.Transaction0\x01\x12]\n" +
+	"\x11WatchTransactions\x12&.ethparser.v1.WatchTransactionsRequest\x1a\x1e.ethparser.v1.TransactionMatch0\x01BAZ?github.com/galecic/ethereum_parser/api/ethparser/v1;ethparserv1b\x06proto3"
+
+var (
+	file_ethparser_v1_parser_proto_rawDescOnce sync.Once
+	file_ethparser_v1_parser_proto_rawDescData []byte
+)
+
+func file_ethparser_v1_parser_proto_rawDescGZIP() []byte {
+	file_ethparser_v1_parser_proto_rawDescOnce.Do(func() {
+		file_ethparser_v1_parser_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_ethparser_v1_parser_proto_rawDesc), len(file_ethparser_v1_parser_proto_rawDesc)))
+	})
+	return file_ethparser_v1_parser_proto_rawDescData
+}
+
+var file_ethparser_v1_parser_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_ethparser_v1_parser_proto_goTypes = []any{
+	(*GetCurrentBlockRequest)(nil),   // 0: ethparser.v1.GetCurrentBlockRequest
+	(*GetCurrentBlockResponse)(nil),  // 1: ethparser.v1.GetCurrentBlockResponse
+	(*SubscribeRequest)(nil),         // 2: ethparser.v1.SubscribeRequest
+	(*SubscribeResponse)(nil),        // 3: ethparser.v1.SubscribeResponse
+	(*UnsubscribeRequest)(nil),       // 4: ethparser.v1.UnsubscribeRequest
+	(*UnsubscribeResponse)(nil),      // 5: ethparser.v1.UnsubscribeResponse
+	(*GetTransactionsRequest)(nil),   // 6: ethparser.v1.GetTransactionsRequest
+	(*WatchTransactionsRequest)(nil), // 7: ethparser.v1.WatchTransactionsRequest
+	(*Transaction)(nil),              // 8: ethparser.v1.Transaction
+	(*TransactionMatch)(nil),         // 9: ethparser.v1.TransactionMatch
+}
+var file_ethparser_v1_parser_proto_depIdxs = []int32{
+	8, // 0: ethparser.v1.TransactionMatch.transaction:type_name -> ethparser.v1.Transaction
+	0, // 1: ethparser.v1.ParserService.GetCurrentBlock:input_type -> ethparser.v1.GetCurrentBlockRequest
+	2, // 2: ethparser.v1.ParserService.Subscribe:input_type -> ethparser.v1.SubscribeRequest
+	4, // 3: ethparser.v1.ParserService.Unsubscribe:input_type -> ethparser.v1.UnsubscribeRequest
+	6, // 4: ethparser.v1.ParserService.GetTransactions:input_type -> ethparser.v1.GetTransactionsRequest
+	7, // 5: ethparser.v1.ParserService.WatchTransactions:input_type -> ethparser.v1.WatchTransactionsRequest
+	1, // 6: ethparser.v1.ParserService.GetCurrentBlock:output_type -> ethparser.v1.GetCurrentBlockResponse
+	3, // 7: ethparser.v1.ParserService.Subscribe:output_type -> ethparser.v1.SubscribeResponse
+	5, // 8: ethparser.v1.ParserService.Unsubscribe:output_type -> ethparser.v1.UnsubscribeResponse
+	8, // 9: ethparser.v1.ParserService.GetTransactions:output_type -> ethparser.v1.Transaction
+	9, // 10: ethparser.v1.ParserService.WatchTransactions:output_type -> ethparser.v1.TransactionMatch
+	6, // [6:11] is the sub-list for method output_type
+	1, // [1:6] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_ethparser_v1_parser_proto_init() }
+func file_ethparser_v1_parser_proto_init() {
+	if File_ethparser_v1_parser_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ethparser_v1_parser_proto_rawDesc), len(file_ethparser_v1_parser_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_ethparser_v1_parser_proto_goTypes,
+		DependencyIndexes: file_ethparser_v1_parser_proto_depIdxs,
+		MessageInfos:      file_ethparser_v1_parser_proto_msgTypes,
+	}.Build()
+	File_ethparser_v1_parser_proto = out.File
+	file_ethparser_v1_parser_proto_goTypes = nil
+	file_ethparser_v1_parser_proto_depIdxs = nil
+}
@@ -0,0 +1,263 @@
+// Package apiclient - Go client of the parser's HTTP API, used by the ethparser CLI and by services talking to a parser.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiKeyHeader - header the API key is sent in
+const apiKeyHeader = "X-API-Key"
+
+// export formats of ExportTransactions
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// bulk subscribe statuses of an address
+const (
+	BulkCreated = "created"
+	BulkExists  = "exists"
+	BulkInvalid = "invalid"
+)
+
+type BulkSubscribeResult struct {
+	Address string `json:"address"`
+	Status  string `json:"status"`
+	// Line - line of a CSV or plain text upload the address was read from
+	Line int `json:"line,omitempty"`
+}
+
+type BulkSubscribeResponse struct {
+	Created int                   `json:"created"`
+	Exists  int                   `json:"exists"`
+	Invalid int                   `json:"invalid"`
+	Results []BulkSubscribeResult `json:"results"`
+}
+
+// APIError - a response with an error status
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Err        string `json:"error"`
+	Msg        string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	if e.Msg == "" && e.Err == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s", e.Msg, e.Err)
+}
+
+// IsStatus - err is an APIError with status code
+func IsStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient HTTPClient
+	apiKey     string
+}
+
+type Option func(c *Client)
+
+// WithAPIKey - key sent with every call, needed when the server has auth enabled
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient - client the calls are made with, http.DefaultClient by default. Watch dials its own connection.
+func WithHTTPClient(hc HTTPClient) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// New - client of the API served at baseURL, e.g. http://localhost:8000
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("api url %q: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("api url %q: want http(s)://host[:port]", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// CurrentBlock - last block the parser processed
+func (c *Client) CurrentBlock(ctx context.Context) (int, error) {
+	var response struct {
+		CurrentBlockHeight int `json:"currentBlockHeight"`
+	}
+	err := c.do(ctx, http.MethodGet, "/current-block", nil, nil, &response)
+	return response.CurrentBlockHeight, err
+}
+
+// Status - parsing progress
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodGet, "/status", nil, nil, &status)
+	return status, err
+}
+
+// Subscribe - starts storing the transactions of addr, subscribing twice is not an error
+func (c *Client) Subscribe(ctx context.Context, addr Address) error {
+	return c.do(ctx, http.MethodPost, "/subscribe", nil, map[string]Address{"address": addr}, nil)
+}
+
+// Unsubscribe - stops storing the transactions of addr and drops them
+func (c *Client) Unsubscribe(ctx context.Context, addr Address) error {
+	return c.do(ctx, http.MethodPost, "/unsubscribe", nil, map[string]Address{"address": addr}, nil)
+}
+
+// Subscribers - addresses the caller is subscribed to, sorted
+func (c *Client) Subscribers(ctx context.Context) ([]Address, error) {
+	var addrs []Address
+	err := c.do(ctx, http.MethodGet, "/subscribers", nil, nil, &addrs)
+	return addrs, err
+}
+
+// BulkSubscribe - subscribes every address of addrs, each gets a result
+func (c *Client) BulkSubscribe(ctx context.Context, addrs []Address) (BulkSubscribeResponse, error) {
+	var response BulkSubscribeResponse
+	err := c.do(ctx, http.MethodPost, "/subscriptions/bulk", nil, addrs, &response)
+	return response, err
+}
+
+// Transactions - stored transactions of a subscribed address, empty for an address the caller does not watch
+func (c *Client) Transactions(ctx context.Context, addr Address) ([]Transaction, error) {
+	var txs []Transaction
+	err := c.do(ctx, http.MethodGet, "/transactions/"+url.PathEscape(string(addr)), nil, nil, &txs)
+	return txs, err
+}
+
+// ExportTransactions - the transactions of addr as CSV or NDJSON, streamed as the server reads them.
+// No columns means the server's default ones. The caller closes the body.
+func (c *Client) ExportTransactions(ctx context.Context, addr Address, format string, columns []string) (io.ReadCloser, error) {
+	query := url.Values{"format": {format}}
+	if len(columns) > 0 {
+		query.Set("columns", strings.Join(columns, ","))
+	}
+	resp, err := c.send(ctx, http.MethodGet, "/transactions/"+url.PathEscape(string(addr)), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// CreateSubscription - registers a rule subscription, matched transactions are stored under its ID
+func (c *Client) CreateSubscription(ctx context.Context, rule Rule) (Subscription, error) {
+	var sub Subscription
+	err := c.do(ctx, http.MethodPost, "/subscriptions", nil, map[string]Rule{"rule": rule}, &sub)
+	return sub, err
+}
+
+// Subscriptions - rule subscriptions of the caller sorted by ID
+func (c *Client) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	var subs []Subscription
+	err := c.do(ctx, http.MethodGet, "/subscriptions", nil, nil, &subs)
+	return subs, err
+}
+
+// SubscriptionTransactions - transactions matched by a rule subscription
+func (c *Client) SubscriptionTransactions(ctx context.Context, id string) ([]Transaction, error) {
+	var txs []Transaction
+	err := c.do(ctx, http.MethodGet, "/subscriptions/"+url.PathEscape(id)+"/transactions", nil, nil, &txs)
+	return txs, err
+}
+
+// DeleteSubscription - drops a rule subscription and its matches
+func (c *Client) DeleteSubscription(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/subscriptions/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// do - sends body as JSON and decodes the JSON response into out, unless out is nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// send - the response of a call, an error status is returned as an APIError
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %w", method, path, readAPIError(resp))
+	}
+
+	return resp, nil
+}
+
+func (c *Client) authorize(h http.Header) {
+	if c.apiKey != "" {
+		h.Set(apiKeyHeader, c.apiKey)
+	}
+}
+
+// readAPIError - the error of a failed response, its body is the server's error JSON when it has one
+func readAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(apiErr)
+	apiErr.StatusCode = resp.StatusCode
+
+	return apiErr
+}
@@ -0,0 +1,109 @@
+package apiclient
+
+import (
+	"encoding/json"
+	"go/build"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	c, err := New("http://localhost:8000/")
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8000", c.baseURL.String())
+
+	for _, bad := range []string{"localhost:8000", "ftp://host", "http://", "::"} {
+		_, err := New(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestClient_Requests(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		switch strings.TrimPrefix(r.URL.Path, "/api") {
+		case "/current-block":
+			_ = json.NewEncoder(w).Encode(map[string]int{"currentBlockHeight": 42})
+		case "/subscriptions/bulk":
+			_, _ = io.WriteString(w, `{"created":0,"exists":0,"invalid":1,"results":[{"address":"0x12","status":"invalid","line":3}]}`)
+		case "/transactions/0xabc":
+			_, _ = io.WriteString(w, "block,hash\n1,0x1\n")
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL+"/api", WithAPIKey("secret"))
+	require.NoError(t, err)
+
+	block, err := c.CurrentBlock(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 42, block)
+	require.Equal(t, "secret", got.Header.Get("X-API-Key"))
+	require.Equal(t, "/api/current-block", got.URL.Path)
+
+	require.NoError(t, c.Subscribe(t.Context(), "0xabc"))
+	require.Equal(t, http.MethodPost, got.Method)
+	require.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"address":"0xabc"}`, body)
+
+	bulk, err := c.BulkSubscribe(t.Context(), []Address{"0x12"})
+	require.NoError(t, err)
+	require.Equal(t, BulkSubscribeResponse{Invalid: 1, Results: []BulkSubscribeResult{{Address: "0x12", Status: BulkInvalid, Line: 3}}}, bulk)
+
+	export, err := c.ExportTransactions(t.Context(), "0xabc", FormatCSV, []string{"block", "hash"})
+	require.NoError(t, err)
+	data, err := io.ReadAll(export)
+	require.NoError(t, err)
+	require.NoError(t, export.Close())
+	require.Equal(t, "block,hash\n1,0x1\n", string(data))
+	require.Equal(t, "csv", got.URL.Query().Get("format"))
+	require.Equal(t, "block,hash", got.URL.Query().Get("columns"))
+
+	require.NoError(t, c.DeleteSubscription(t.Context(), "a/b"))
+	require.Equal(t, "/api/subscriptions/a%2Fb", got.URL.EscapedPath())
+}
+
+func TestClient_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":"subscription not found","msg":"not found subscription"}`)
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL)
+	require.NoError(t, err)
+
+	_, err = c.SubscriptionTransactions(t.Context(), "missing")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, &APIError{StatusCode: http.StatusNotFound, Err: "subscription not found", Msg: "not found subscription"}, apiErr)
+	require.True(t, IsStatus(err, http.StatusNotFound))
+	require.Contains(t, err.Error(), "GET /subscriptions/missing/transactions")
+
+	// a body that is not the error JSON still carries the status
+	_, err = c.Status(t.Context())
+	require.True(t, IsStatus(err, http.StatusBadGateway))
+	require.Contains(t, err.Error(), http.StatusText(http.StatusBadGateway))
+}
+
+// TestImports - services importing the client do not pull in the server's packages
+func TestImports(t *testing.T) {
+	pkg, err := build.ImportDir(".", 0)
+	require.NoError(t, err)
+	for _, path := range pkg.Imports {
+		require.NotContains(t, path, "/internal/")
+	}
+}
@@ -0,0 +1,78 @@
+package apiclient
+
+import "time"
+
+// The types below are the JSON of the API. They are defined here rather than taken from the server's
+// packages, so that a service using the client does not depend on the parser or its internal types.
+
+// Address - 0x followed by 40 hex digits, the server returns addresses lower cased
+type Address string
+
+// Transaction - a stored transaction, quantities are 0x prefixed hex as returned by the node
+type Transaction struct {
+	BlockNumber      string  `json:"blockNumber"`
+	From             Address `json:"from"`
+	Hash             string  `json:"hash"`
+	To               Address `json:"to"`
+	TransactionIndex string  `json:"transactionIndex"`
+	Value            string  `json:"value,omitempty"`
+	Input            string  `json:"input,omitempty"`
+	LogIndex         string  `json:"logIndex,omitempty"`
+}
+
+type RuleType string
+
+const (
+	// RuleAddress - transaction involves one of Addresses as sender or recipient
+	RuleAddress RuleType = "address"
+	// RuleValueAbove - transferred value in wei is greater than Value
+	RuleValueAbove RuleType = "value_above"
+	// RuleValueBelow - transferred value in wei is less than Value
+	RuleValueBelow RuleType = "value_below"
+	// RuleContractCreation - transaction has no recipient
+	RuleContractCreation RuleType = "contract_creation"
+	// RuleMethod - input starts with one of the 4-byte Selectors
+	RuleMethod RuleType = "method"
+	// RuleTokenContract - transaction calls one of the token contracts in Addresses
+	RuleTokenContract RuleType = "token_contract"
+	// RuleAnd - all nested Rules match
+	RuleAnd RuleType = "and"
+	// RuleOr - at least one of nested Rules matches
+	RuleOr RuleType = "or"
+)
+
+// Rule - a matcher of a rule subscription, nested through Rules for and/or
+type Rule struct {
+	Type      RuleType  `json:"type"`
+	Addresses []Address `json:"addresses,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	Selectors []string  `json:"selectors,omitempty"`
+	Rules     []Rule    `json:"rules,omitempty"`
+}
+
+type Subscription struct {
+	ID string `json:"id"`
+	// Tenant - owner, the only one who sees the subscription and its matches
+	Tenant string `json:"tenant"`
+	Rule   Rule   `json:"rule"`
+}
+
+// Status - parsing progress
+type Status struct {
+	RemoteHead   int `json:"remoteHead"`
+	CurrentBlock int `json:"currentBlock"`
+	// LastSuccessfulTick - zero until the first block is processed
+	LastSuccessfulTick time.Time `json:"lastSuccessfulTick"`
+	LastError          string    `json:"lastError,omitempty"`
+	LastErrorAt        time.Time `json:"lastErrorAt"`
+	Endpoint           string    `json:"endpoint"`
+	StartedAt          time.Time `json:"startedAt"`
+	Uptime             string    `json:"uptime"`
+	// Paused - new blocks are not parsed until the parser is resumed
+	Paused bool `json:"paused"`
+}
+
+// Lag - blocks the parser is behind the node
+func (s Status) Lag() int {
+	return s.RemoteHead - s.CurrentBlock
+}
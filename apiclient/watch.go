@@ -0,0 +1,85 @@
+package apiclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Match - a transaction the parser stored for a watched address
+type Match struct {
+	Address     Address     `json:"address"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// wsRequest, wsMessage - the /ws messages Watch sends and reads
+type wsRequest struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id,omitempty"`
+	Addresses []Address `json:"addresses"`
+}
+
+type wsMessage struct {
+	Type        string       `json:"type"`
+	ID          string       `json:"id,omitempty"`
+	Address     Address      `json:"address,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// ErrWatchSubscribe - the server refused to watch the addresses, e.g. one the caller is not subscribed to
+var ErrWatchSubscribe = errors.New("watch refused")
+
+// Watch - calls fn with each live match of addrs, which the caller must be subscribed to, until ctx is done,
+// fn fails or the server closes the connection. The server drops watchers that fall behind, so fn should be quick.
+func (c *Client) Watch(ctx context.Context, addrs []Address, fn func(Match) error) error {
+	u := c.baseURL.JoinPath("/ws")
+	u.Scheme = map[string]string{"http": "ws", "https": "wss"}[u.Scheme]
+	header := http.Header{}
+	c.authorize(header)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			defer resp.Body.Close()
+			return fmt.Errorf("watch: %w", readAPIError(resp))
+		}
+		return fmt.Errorf("watch: %w", err)
+	}
+	defer conn.Close()
+	// unblocks the read below once ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	const subscribeID = "watch"
+	if err := conn.WriteJSON(wsRequest{Type: "subscribe", ID: subscribeID, Addresses: addrs}); err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("watch: %w", err)
+		}
+		switch msg.Type {
+		case "error":
+			if msg.ID == subscribeID {
+				return fmt.Errorf("%w: %s", ErrWatchSubscribe, msg.Error)
+			}
+		case "match":
+			if msg.Transaction == nil {
+				continue
+			}
+			if err := fn(Match{Address: msg.Address, Transaction: *msg.Transaction}); err != nil {
+				return err
+			}
+		}
+	}
+}
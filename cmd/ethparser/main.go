@@ -0,0 +1,404 @@
+// Command ethparser - command line client of the parser's HTTP API.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/galecic/ethereum_parser/apiclient"
+	"github.com/galecic/ethereum_parser/internal/export"
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+const (
+	defaultServer = "http://localhost:8000"
+	envServer     = "ETHPARSER_SERVER"
+	envAPIKey     = "ETHPARSER_API_KEY"
+
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+var errUsage = errors.New("usage")
+
+const usage = `Usage: ethparser [flags] <command> [args]
+
+Commands:
+  subscribe <address>...             store the transactions of addresses
+  subscribe -rule '<json rule>'      create a rule subscription, prints its ID
+  unsubscribe <address or id>...     drop address or rule subscriptions
+  list [-format table|json]          address and rule subscriptions
+  txs [-format table|json|csv] [-columns a,b] <address>
+                                     stored transactions of an address
+  status [-format table|json]        parsing progress
+  tail [-format table|json] <address>...
+                                     follow live matches of subscribed addresses
+
+Flags:
+`
+
+// command - runs a subcommand with its arguments, output goes to stdout
+type command func(ctx context.Context, c *apiclient.Client, args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"subscribe":   subscribe,
+	"unsubscribe": unsubscribe,
+	"list":        list,
+	"txs":         txs,
+	"status":      status,
+	"tail":        tail,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	fs := flag.NewFlagSet("ethparser", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	server := fs.String("server", envOr(getenv, envServer, defaultServer), "API address, env "+envServer)
+	apiKey := fs.String("api_key", getenv(envAPIKey), "API key when auth is enabled, env "+envAPIKey)
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit of a call, tail runs until interrupted")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	name, args := fs.Arg(0), fs.Args()[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "ethparser: unknown command %q\n", name)
+		fs.Usage()
+		return exitUsage
+	}
+
+	client, err := apiclient.New(*server, apiclient.WithAPIKey(*apiKey))
+	if err != nil {
+		fmt.Fprintf(stderr, "ethparser: %v\n", err)
+		return exitUsage
+	}
+	if name != "tail" && *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	err = cmd(ctx, client, args, stdout)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "ethparser %s: %v\n", name, err)
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "ethparser %s: %v\n", name, err)
+		return exitError
+	}
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if v := getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// commandFlags - flags of a subcommand, errors are reported by run
+func commandFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+	return nil
+}
+
+// parseFormat - format is one of allowed
+func parseFormat(format string, allowed ...string) error {
+	for _, a := range allowed {
+		if format == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: format %q, want one of %s", errUsage, format, strings.Join(allowed, ", "))
+}
+
+// addresses - args as addresses
+func addresses(args []string) ([]apiclient.Address, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: no addresses", errUsage)
+	}
+	addrs := make([]apiclient.Address, 0, len(args))
+	for _, arg := range args {
+		if !models.Address(arg).Valid() {
+			return nil, fmt.Errorf("%w: invalid address %q", errUsage, arg)
+		}
+		addrs = append(addrs, apiclient.Address(arg))
+	}
+	return addrs, nil
+}
+
+func subscribe(ctx context.Context, c *apiclient.Client, args []string, stdout io.Writer) error {
+	fs := commandFlags("subscribe")
+	rule := fs.String("rule", "", "rule subscription as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *rule != "" {
+		if fs.NArg() > 0 {
+			return fmt.Errorf("%w: -rule takes no addresses", errUsage)
+		}
+		var r apiclient.Rule
+		if err := json.Unmarshal([]byte(*rule), &r); err != nil {
+			return fmt.Errorf("%w: rule: %w", errUsage, err)
+		}
+		sub, err := c.CreateSubscription(ctx, r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, sub.ID)
+		return nil
+	}
+
+	addrs, err := addresses(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 1 {
+		return c.Subscribe(ctx, addrs[0])
+	}
+	response, err := c.BulkSubscribe(ctx, addrs)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, result := range response.Results {
+		fmt.Fprintf(w, "%s\t%s\n", result.Address, result.Status)
+	}
+	return w.Flush()
+}
+
+// unsubscribe - arguments that are addresses are unsubscribed, anything else is a rule subscription ID
+func unsubscribe(ctx context.Context, c *apiclient.Client, args []string, _ io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no addresses or subscription IDs", errUsage)
+	}
+	for _, arg := range args {
+		var err error
+		if models.Address(arg).Valid() {
+			err = c.Unsubscribe(ctx, apiclient.Address(arg))
+		} else {
+			err = c.DeleteSubscription(ctx, arg)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+	}
+	return nil
+}
+
+// listing - the JSON output of list
+type listing struct {
+	Addresses     []apiclient.Address      `json:"addresses"`
+	Subscriptions []apiclient.Subscription `json:"subscriptions"`
+}
+
+func list(ctx context.Context, c *apiclient.Client, args []string, stdout io.Writer) error {
+	fs := commandFlags("list")
+	format := fs.String("format", formatTable, "table or json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := parseFormat(*format, formatTable, formatJSON); err != nil {
+		return err
+	}
+	addrs, err := c.Subscribers(ctx)
+	if err != nil {
+		return err
+	}
+	subs, err := c.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		return writeJSON(stdout, listing{Addresses: addrs, Subscriptions: subs})
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tRULE")
+	// an address subscription is identified by its address, unsubscribe takes either
+	for _, addr := range addrs {
+		fmt.Fprintf(w, "%s\taddress\t-\n", addr)
+	}
+	for _, sub := range subs {
+		rule, err := json.Marshal(sub.Rule)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", sub.ID, sub.Rule.Type, rule)
+	}
+	return w.Flush()
+}
+
+func txs(ctx context.Context, c *apiclient.Client, args []string, stdout io.Writer) error {
+	fs := commandFlags("txs")
+	format := fs.String("format", formatTable, "table, json or csv")
+	columns := fs.String("columns", "", "csv columns, the server's default when empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := parseFormat(*format, formatTable, formatJSON, formatCSV); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: want one address", errUsage)
+	}
+	addrs, err := addresses(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if *format == formatCSV {
+		var cols []string
+		if *columns != "" {
+			cols = strings.Split(*columns, ",")
+		}
+		body, err := c.ExportTransactions(ctx, addrs[0], apiclient.FormatCSV, cols)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		_, err = io.Copy(stdout, body)
+		return err
+	}
+	transactions, err := c.Transactions(ctx, addrs[0])
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		return writeJSON(stdout, transactions)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BLOCK\tHASH\tFROM\tTO\tVALUE (ETH)")
+	for _, tx := range transactions {
+		writeTxRow(w, tx)
+	}
+	return w.Flush()
+}
+
+func status(ctx context.Context, c *apiclient.Client, args []string, stdout io.Writer) error {
+	fs := commandFlags("status")
+	format := fs.String("format", formatTable, "table or json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := parseFormat(*format, formatTable, formatJSON); err != nil {
+		return err
+	}
+	s, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		return writeJSON(stdout, s)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "current block:\t%d\n", s.CurrentBlock)
+	fmt.Fprintf(w, "remote head:\t%d\n", s.RemoteHead)
+	fmt.Fprintf(w, "lag:\t%d\n", s.Lag())
+	fmt.Fprintf(w, "paused:\t%t\n", s.Paused)
+	fmt.Fprintf(w, "last tick:\t%s\n", formatTime(s.LastSuccessfulTick))
+	if s.LastError != "" {
+		fmt.Fprintf(w, "last error:\t%s (%s)\n", s.LastError, formatTime(s.LastErrorAt))
+	}
+	fmt.Fprintf(w, "endpoint:\t%s\n", s.Endpoint)
+	fmt.Fprintf(w, "uptime:\t%s\n", s.Uptime)
+	return w.Flush()
+}
+
+func tail(ctx context.Context, c *apiclient.Client, args []string, stdout io.Writer) error {
+	fs := commandFlags("tail")
+	format := fs.String("format", formatTable, "table or json, a match per line")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := parseFormat(*format, formatTable, formatJSON); err != nil {
+		return err
+	}
+	addrs, err := addresses(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(stdout)
+	err = c.Watch(ctx, addrs, func(m apiclient.Match) error {
+		if *format == formatJSON {
+			return enc.Encode(m)
+		}
+		// flushed per match, columns are aligned within a line only
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\t", m.Address)
+		writeTxRow(w, m.Transaction)
+		return w.Flush()
+	})
+	if errors.Is(err, context.Canceled) {
+		// interrupted
+		return nil
+	}
+	return err
+}
+
+func writeTxRow(w io.Writer, tx apiclient.Transaction) {
+	block := tx.BlockNumber
+	if n, err := helpers.ParseHexInt(tx.BlockNumber); err == nil {
+		block = strconv.Itoa(n)
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", block, tx.Hash, tx.From, tx.To, export.FormatEth(tx.Value))
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(time.RFC3339)
+}
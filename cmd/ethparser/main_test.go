@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/apiclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	watched = "0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497"
+	apiKey  = "cli-secret-0123456789"
+)
+
+var stored = apiclient.Transaction{
+	BlockNumber:      "0x10",
+	Hash:             "0xabc",
+	From:             watched,
+	To:               "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+	TransactionIndex: "0x0",
+	Value:            "0x14d1120d7b160000",
+}
+
+// fakeAPI - canned answers of the parser API, records the calls it got
+type fakeAPI struct {
+	calls []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.calls = append(f.calls, strings.TrimSpace(r.Method+" "+r.URL.RequestURI()+" "+string(body)))
+	if r.Header.Get("X-API-Key") != apiKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"error":"missing api key","msg":"unauthenticated"}`)
+		return
+	}
+	writeJSON := func(v any) {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case r.URL.Path == "/subscribers":
+		writeJSON([]apiclient.Address{watched})
+	case r.URL.Path == "/subscriptions" && r.Method == http.MethodGet:
+		writeJSON([]apiclient.Subscription{{ID: "SUB1", Tenant: "default", Rule: apiclient.Rule{Type: "contract_creation"}}})
+	case r.URL.Path == "/subscriptions" && r.Method == http.MethodPost:
+		writeJSON(apiclient.Subscription{ID: "SUB2"})
+	case r.URL.Path == "/subscriptions/bulk":
+		writeJSON(apiclient.BulkSubscribeResponse{Results: []apiclient.BulkSubscribeResult{{Address: watched, Status: apiclient.BulkExists}}})
+	case r.URL.Path == "/transactions/"+watched && r.URL.Query().Get("format") == "csv":
+		_, _ = io.WriteString(w, "address,block\n"+watched+",16\n")
+	case r.URL.Path == "/transactions/"+watched:
+		writeJSON([]apiclient.Transaction{stored})
+	case r.URL.Path == "/status":
+		writeJSON(apiclient.Status{CurrentBlock: 16, RemoteHead: 18, Paused: true, Endpoint: "http://node.test"})
+	case r.URL.Path == "/ws":
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var request map[string]any
+		_ = conn.ReadJSON(&request)
+		_ = conn.WriteJSON(map[string]any{"type": "subscribed", "id": request["id"]})
+		_ = conn.WriteJSON(map[string]any{"type": "match", "address": watched, "transaction": stored})
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+		_, _, _ = conn.ReadMessage()
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// runCLI - runs the CLI against api, returns the exit code, stdout and stderr
+func runCLI(t *testing.T, api http.Handler, args ...string) (int, string, string) {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	env := map[string]string{envServer: srv.URL, envAPIKey: apiKey}
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr, func(key string) string {
+		return env[key]
+	})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"watch"},
+		{"subscribe"},
+		{"subscribe", "0x12"},
+		{"subscribe", "-rule", "{", watched},
+		{"txs"},
+		{"txs", "-format", "xml", watched},
+		{"status", "-verbose"},
+		{"-server", "localhost", "status"},
+	} {
+		api := &fakeAPI{}
+		code, _, stderr := runCLI(t, api, args...)
+		require.Equal(t, exitUsage, code, "%q: %s", args, stderr)
+		require.Empty(t, api.calls, "%q", args)
+	}
+}
+
+func TestRun_Subscribe(t *testing.T) {
+	api := &fakeAPI{}
+	// the server normalizes the case, the CLI sends addresses as written
+	checksummed := "0x" + strings.ToUpper(watched[2:])
+	code, _, stderr := runCLI(t, api, "subscribe", checksummed)
+	require.Equal(t, exitOK, code, stderr)
+
+	code, stdout, _ := runCLI(t, api, "subscribe", watched, watched)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, watched+"  exists\n", stdout)
+
+	code, stdout, _ = runCLI(t, api, "subscribe", "-rule", `{"type":"contract_creation"}`)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "SUB2\n", stdout)
+
+	code, _, _ = runCLI(t, api, "unsubscribe", watched, "SUB1")
+	require.Equal(t, exitOK, code)
+
+	require.Equal(t, []string{
+		`POST /subscribe {"address":"` + checksummed + `"}`,
+		`POST /subscriptions/bulk ["` + watched + `","` + watched + `"]`,
+		`POST /subscriptions {"rule":{"type":"contract_creation"}}`,
+		`POST /unsubscribe {"address":"` + watched + `"}`,
+		`DELETE /subscriptions/SUB1`,
+	}, api.calls)
+}
+
+func TestRun_Output(t *testing.T) {
+	api := &fakeAPI{}
+
+	code, stdout, _ := runCLI(t, api, "list")
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "ID                                          TYPE               RULE\n"+
+		watched+"  address            -\n"+
+		"SUB1                                        contract_creation  {\"type\":\"contract_creation\"}\n", stdout)
+
+	code, stdout, _ = runCLI(t, api, "list", "-format", "json")
+	require.Equal(t, exitOK, code)
+	require.JSONEq(t, `{"addresses":["`+watched+`"],"subscriptions":[{"id":"SUB1","tenant":"default","rule":{"type":"contract_creation"}}]}`, stdout)
+
+	code, stdout, _ = runCLI(t, api, "txs", watched)
+	require.Equal(t, exitOK, code)
+	require.Contains(t, stdout, "BLOCK  HASH   FROM")
+	require.Contains(t, stdout, "16     0xabc  "+watched)
+	require.True(t, strings.HasSuffix(stdout, "  1.5\n"), stdout)
+
+	code, stdout, _ = runCLI(t, api, "txs", "-format", "json", watched)
+	require.Equal(t, exitOK, code)
+	var txs []apiclient.Transaction
+	require.NoError(t, json.Unmarshal([]byte(stdout), &txs))
+	require.Equal(t, []apiclient.Transaction{stored}, txs)
+
+	code, stdout, _ = runCLI(t, api, "txs", "-format", "csv", "-columns", "address,block", watched)
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "address,block\n"+watched+",16\n", stdout)
+	require.Equal(t, "GET /transactions/"+watched+"?columns=address%2Cblock&format=csv", api.calls[len(api.calls)-1])
+
+	code, stdout, _ = runCLI(t, api, "status")
+	require.Equal(t, exitOK, code)
+	require.Contains(t, stdout, "current block:  16\n")
+	require.Contains(t, stdout, "lag:            2\n")
+	require.Contains(t, stdout, "paused:         true\n")
+	require.Contains(t, stdout, "last tick:      never\n")
+}
+
+func TestRun_Tail(t *testing.T) {
+	code, stdout, stderr := runCLI(t, &fakeAPI{}, "tail", "-format", "json", watched)
+	// the server went away after one match
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "server shutting down")
+	var match apiclient.Match
+	require.NoError(t, json.Unmarshal([]byte(stdout), &match))
+	require.Equal(t, apiclient.Match{Address: watched, Transaction: stored}, match)
+}
+
+func TestRun_APIError(t *testing.T) {
+	srv := httptest.NewServer(&fakeAPI{})
+	t.Cleanup(srv.Close)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-server", srv.URL, "status"}, &stdout, &stderr, func(string) string { return "" })
+	require.Equal(t, exitError, code)
+	require.Equal(t, "ethparser status: GET /status: unauthenticated: missing api key\n", stderr.String())
+}
@@ -0,0 +1,244 @@
+// Command import - replays archived blocks through the parser and writes the transactions of the watched addresses.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/export"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/watchlist"
+)
+
+// exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+var errUsage = errors.New("usage")
+
+const usage = `Usage: import -source <file or directory> [-address <address>]... [-watchlist <file>] [flags]
+
+Replays blocks archived as eth_getBlockByNumber responses or results, one or more per .json, .jsonl
+or .ndjson file, and writes the transactions of the watched addresses as NDJSON or CSV.
+Blocks missing from the source are skipped with a warning, -strict fails the import instead.
+
+Flags:
+`
+
+type options struct {
+	source    string
+	from, to  int
+	addresses []models.Address
+	watchlist string
+	shards    int
+	workers   int
+	format    string
+	columns   []export.Column
+	out       string
+	strict    bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	opts := options{}
+	fs.StringVar(&opts.source, "source", "", "file or directory of archived blocks")
+	fs.IntVar(&opts.from, "from", 0, "first block to replay, 0 for the lowest block of the source")
+	fs.IntVar(&opts.to, "to", 0, "last block to replay, 0 for the highest block of the source")
+	fs.Func("address", "address to watch, repeatable", func(s string) error {
+		addr := models.Address(s)
+		if !addr.Valid() {
+			return fmt.Errorf("invalid address %q", s)
+		}
+		opts.addresses = append(opts.addresses, addr)
+		return nil
+	})
+	fs.StringVar(&opts.watchlist, "watchlist", "", "file of addresses to watch, one per line or CSV")
+	fs.IntVar(&opts.shards, "store_shards", 0, "number of data store shards, 0 keeps a single-lock store")
+	fs.IntVar(&opts.workers, "threads", 10, "number of coroutines for parsing transactions")
+	fs.StringVar(&opts.format, "format", export.FormatNDJSON, "output format, ndjson or csv")
+	columns := fs.String("columns", "", "comma separated output columns, the export defaults when empty")
+	fs.StringVar(&opts.out, "out", "", "output file, stdout when empty")
+	fs.BoolVar(&opts.strict, "strict", false, "fail when a block of the range is missing from the source")
+	logLevel := fs.String("log_level", "info", "log level: debug, info, warn or error")
+	logFormat := fs.String("log_format", logging.FormatText, "log format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	err := validate(fs, &opts, *columns)
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		fs.Usage()
+		return exitUsage
+	}
+	logger, err := logging.New(stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return exitUsage
+	}
+
+	if err := importBlocks(ctx, opts, stdout, logger); err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		if errors.Is(err, errUsage) {
+			return exitUsage
+		}
+		return exitError
+	}
+
+	return exitOK
+}
+
+func validate(fs *flag.FlagSet, opts *options, columns string) error {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if opts.source == "" {
+		return errors.New("no -source")
+	}
+	if opts.from < 0 || opts.to < 0 || (opts.to > 0 && opts.to < opts.from) {
+		return fmt.Errorf("invalid block range %d..%d", opts.from, opts.to)
+	}
+	if opts.shards < 0 {
+		return fmt.Errorf("-store_shards must not be negative, got %d", opts.shards)
+	}
+	if opts.workers < 1 {
+		return fmt.Errorf("-threads must be at least 1, got %d", opts.workers)
+	}
+	if opts.format != export.FormatNDJSON && opts.format != export.FormatCSV {
+		return fmt.Errorf("-format %q, want %s or %s", opts.format, export.FormatNDJSON, export.FormatCSV)
+	}
+	opts.columns = export.DefaultColumns
+	if columns != "" {
+		parsed, err := export.ParseColumns(columns)
+		if err != nil {
+			return err
+		}
+		opts.columns = parsed
+	}
+	if opts.watchlist != "" {
+		addrs, err := watchlist.Read(opts.watchlist)
+		if err != nil {
+			return err
+		}
+		opts.addresses = append(opts.addresses, addrs...)
+	}
+	if len(opts.addresses) == 0 {
+		return errors.New("no addresses, use -address or -watchlist")
+	}
+
+	return nil
+}
+
+// importBlocks - replays the blocks of opts.source into a fresh store and writes what it stored for the addresses
+func importBlocks(ctx context.Context, opts options, stdout io.Writer, logger *slog.Logger) error {
+	source, err := client.NewFileClient(opts.source)
+	if err != nil {
+		return err
+	}
+	first, last := source.Blocks()
+	from, to := opts.from, opts.to
+	if from == 0 {
+		from = first
+	}
+	if to == 0 {
+		to = last
+	}
+	if from < first || to > last || to < from {
+		return fmt.Errorf("%w: blocks %d..%d, the source has %d..%d", errUsage, from, to, first, last)
+	}
+
+	storeOpts := []data_store.Option{
+		data_store.WithLogger(logger.With(slog.String("component", "store"))),
+	}
+	db := data_store.NewDataStore(storeOpts...)
+	if opts.shards > 0 {
+		db = data_store.NewShardedDataStore(opts.shards, storeOpts...)
+	}
+	p := parser.NewParserRuntime(ctx, source, db, parser.ParserConfig{Workers: opts.workers},
+		parser.WithLogger(logger.With(slog.String("component", "parser"))),
+	)
+	for _, addr := range opts.addresses {
+		p.Subscribe(ctx, models.DefaultTenant, addr)
+	}
+
+	start := time.Now()
+	matches, missing, err := p.Replay(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	if opts.strict && missing > 0 {
+		return fmt.Errorf("%d blocks of %d..%d missing from %s", missing, from, to, opts.source)
+	}
+
+	rows, err := output(stdout, opts, p.ScanTransactions(ctx, data_store.TxQuery{Tenant: models.DefaultTenant}))
+	if err != nil {
+		return err
+	}
+	logger.Info("import finished",
+		slog.String("source", opts.source),
+		slog.Int("from_block", from),
+		slog.Int("to_block", to),
+		slog.Int("addresses", len(opts.addresses)),
+		slog.Int("matches", matches),
+		slog.Int("missing_blocks", missing),
+		slog.Int("rows", rows),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return nil
+}
+
+// output - writes rows to opts.out, or to stdout without one, returns the number written
+func output(stdout io.Writer, opts options, rows iter.Seq2[models.Address, models.Transaction]) (n int, err error) {
+	w := stdout
+	if opts.out != "" {
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			err = errors.Join(err, f.Close())
+		}()
+		w = f
+	}
+	ew, err := export.NewWriter(w, opts.format, opts.columns)
+	if err != nil {
+		return 0, err
+	}
+	for addr, tx := range rows {
+		if err := ew.Write(addr, tx); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, ew.Flush()
+}
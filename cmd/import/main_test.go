@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	watched = "0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497"
+	other   = "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+)
+
+// archive - blocks 100 to 104 as eth_getBlockByNumber responses, watched sends a transaction in the even ones
+func archive(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeBlocks(t, filepath.Join(dir, "blocks.jsonl"), 100, 101, 102, 103, 104)
+	return dir
+}
+
+// writeBlocks - archives blocks as JSON-RPC responses to path, one per line. Each has a transaction
+// between other addresses, the even ones a transfer from the watched address as well.
+func writeBlocks(t *testing.T, path string, blocks ...int) {
+	t.Helper()
+	var lines bytes.Buffer
+	for _, block := range blocks {
+		txs := []map[string]string{{
+			"hash": fmt.Sprintf("0x%x01", block), "blockNumber": fmt.Sprintf("0x%x", block),
+			"from": other, "to": other, "transactionIndex": "0x0", "value": "0x0",
+		}}
+		if block%2 == 0 {
+			txs = append(txs, map[string]string{
+				"hash": fmt.Sprintf("0x%x02", block), "blockNumber": fmt.Sprintf("0x%x", block),
+				"from": watched, "to": other, "transactionIndex": "0x1", "value": "0xde0b6b3a7640000",
+			})
+		}
+		response := map[string]any{"jsonrpc": "2.0", "id": block, "result": map[string]any{"number": fmt.Sprintf("0x%x", block), "transactions": txs}}
+		require.NoError(t, json.NewEncoder(&lines).Encode(response))
+	}
+	require.NoError(t, os.WriteFile(path, lines.Bytes(), 0o600))
+}
+
+func runImport(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestImport(t *testing.T) {
+	source := archive(t)
+
+	code, stdout, stderr := runImport(t, "-source", source, "-address", watched[:2]+strings.ToUpper(watched[2:4])+watched[4:], "-log_level", "warn")
+	require.Equal(t, exitOK, code, stderr)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 3)
+	var row map[string]string
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+	assert.Equal(t, watched, row["address"])
+	assert.Equal(t, "1", row["value"])
+
+	// a sharded store, part of the range, CSV to a file from a watchlist
+	list := filepath.Join(t.TempDir(), "watchlist.txt")
+	require.NoError(t, os.WriteFile(list, []byte(watched+"\n"), 0o600))
+	out := filepath.Join(t.TempDir(), "out.csv")
+	code, stdout, stderr = runImport(t, "-source", source, "-watchlist", list, "-from", "101", "-to", "103",
+		"-store_shards", "4", "-format", "csv", "-columns", "block,hash", "-out", out)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "import finished")
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "block,hash\n102,0x6602\n", string(data))
+}
+
+func TestImport_Gaps(t *testing.T) {
+	// a directory of two archives, blocks 102 and 103 are in neither
+	source := t.TempDir()
+	writeBlocks(t, filepath.Join(source, "a.jsonl"), 100, 101)
+	writeBlocks(t, filepath.Join(source, "b.jsonl"), 104)
+
+	code, stdout, stderr := runImport(t, "-source", source, "-address", watched, "-format", "csv", "-columns", "block")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, "block\n100\n104\n", stdout)
+	assert.Contains(t, stderr, "skipping missing block")
+	assert.Contains(t, stderr, "missing_blocks=2")
+
+	code, stdout, stderr = runImport(t, "-source", source, "-address", watched, "-strict")
+	assert.Equal(t, exitError, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "2 blocks of 100..104 missing")
+}
+
+func TestImport_Errors(t *testing.T) {
+	source := archive(t)
+	for name, tc := range map[string]struct {
+		args []string
+		code int
+	}{
+		"no source":      {[]string{"-address", watched}, exitUsage},
+		"no addresses":   {[]string{"-source", source}, exitUsage},
+		"bad address":    {[]string{"-source", source, "-address", "0x12"}, exitUsage},
+		"bad format":     {[]string{"-source", source, "-address", watched, "-format", "json"}, exitUsage},
+		"bad range":      {[]string{"-source", source, "-address", watched, "-from", "103", "-to", "101"}, exitUsage},
+		"out of source":  {[]string{"-source", source, "-address", watched, "-to", "105"}, exitUsage},
+		"missing source": {[]string{"-source", filepath.Join(source, "missing"), "-address", watched}, exitError},
+	} {
+		t.Run(name, func(t *testing.T) {
+			code, stdout, stderr := runImport(t, tc.args...)
+			assert.Equal(t, tc.code, code, stderr)
+			assert.Empty(t, stdout)
+		})
+	}
+}
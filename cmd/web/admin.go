@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/parser"
+)
+
+// WithParserControl - serve pause, resume, rewind and rescan of the parser under /admin/parser, needs WithAuth
+func WithParserControl(c parser.Controller) RouterOption {
+	return func(r *Router) {
+		r.control = c
+	}
+}
+
+type RewindRequest struct {
+	Block int `json:"block"`
+	// Purge - drop the stored transactions and matches of the blocks after Block before they are parsed again
+	Purge bool `json:"purge"`
+}
+
+type RewindResponse struct {
+	CurrentBlock int `json:"currentBlock"`
+	Purged       int `json:"purged"`
+}
+
+type RescanRequest struct {
+	FromBlock int `json:"fromBlock"`
+	ToBlock   int `json:"toBlock"`
+}
+
+type RescanResponse struct {
+	FromBlock int `json:"fromBlock"`
+	ToBlock   int `json:"toBlock"`
+	// Matches - address matches the parser had missed
+	Matches int `json:"matches"`
+}
+
+func (h *Router) PauseParser(w http.ResponseWriter, _ *http.Request) {
+	h.control.Pause()
+	writeJSON(w, http.StatusOK, h.parser.Status())
+}
+
+func (h *Router) ResumeParser(w http.ResponseWriter, _ *http.Request) {
+	h.control.Resume()
+	writeJSON(w, http.StatusOK, h.parser.Status())
+}
+
+func (h *Router) RewindParser(w http.ResponseWriter, r *http.Request) {
+	var request RewindRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+	purged, err := h.control.Rewind(r.Context(), request.Block, request.Purge)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, RewindResponse{CurrentBlock: request.Block, Purged: purged})
+}
+
+func (h *Router) RescanParser(w http.ResponseWriter, r *http.Request) {
+	var request RescanRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+	matches, err := h.control.Rescan(r.Context(), request.FromBlock, request.ToBlock)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, RescanResponse{FromBlock: request.FromBlock, ToBlock: request.ToBlock, Matches: matches})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// providerClient - a node whose blocks can be corrected while the parser runs
+type providerClient struct {
+	mu     sync.Mutex
+	head   int
+	blocks map[int][]models.Transaction
+	err    error
+}
+
+func (c *providerClient) GetBlockNumber(context.Context) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.head, nil
+}
+
+func (c *providerClient) GetTxsFromBlock(_ context.Context, number int) ([]models.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blocks[number], c.err
+}
+
+func (*providerClient) Endpoint() string { return "http://node.test" }
+
+func (c *providerClient) serve(number int, txs []models.Transaction, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks[number] = txs
+	c.err = err
+}
+
+// postAdmin - posts body to target with the admin key and decodes the JSON answer into out
+func postAdmin(t *testing.T, router http.Handler, target, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.Header, adminKey)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), out), rec.Body.String())
+	}
+	return rec.Code
+}
+
+func hashes(txs []models.Transaction) []string {
+	out := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		out = append(out, tx.Hash)
+	}
+	return out
+}
+
+func TestAdmin_ReparseBadBlocks(t *testing.T) {
+	ctx := t.Context()
+	tx := func(block int, hash string) models.Transaction {
+		record := grpcTx(block, watched)
+		record.Hash = hash
+		return record
+	}
+	node := &providerClient{head: 7, blocks: map[int][]models.Transaction{
+		5: {tx(5, "0x5")},
+		6: {tx(6, "0xbad")},
+		7: {tx(7, "0x7")},
+	}}
+	store := data_store.NewDataStore()
+	p := parser.NewParserRuntime(ctx, node, store, parser.ParserConfig{TxFetchInterval: 5 * time.Millisecond, Workers: 2})
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("admin", "", adminKey, []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err)
+	router := NewRouter(p, WithAuth(keyring), WithParserControl(p))
+
+	require.True(t, p.Subscribe(ctx, models.DefaultTenant, watched))
+	store.SetCurrentBlock(ctx, 5)
+	// Parse stops with the test context
+	go p.Parse()
+	require.Eventually(t, func() bool {
+		return len(p.GetTransactions(ctx, models.DefaultTenant, watched)) == 3
+	}, time.Second, 5*time.Millisecond)
+
+	var status parser.Status
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/pause", "", &status))
+	require.True(t, status.Paused)
+
+	// the provider corrects block 6, the parser is rewound to re-parse it and the blocks after
+	node.serve(6, []models.Transaction{tx(6, "0x6")}, nil)
+	var rewind RewindResponse
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/rewind", `{"block":5,"purge":true}`, &rewind))
+	require.Equal(t, RewindResponse{CurrentBlock: 5, Purged: 2}, rewind)
+
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, []string{"0x5"}, hashes(p.GetTransactions(ctx, models.DefaultTenant, watched)))
+
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/resume", "", &status))
+	require.False(t, status.Paused)
+	require.Eventually(t, func() bool {
+		return len(p.GetTransactions(ctx, models.DefaultTenant, watched)) == 3
+	}, time.Second, 5*time.Millisecond)
+	assert.ElementsMatch(t, []string{"0x5", "0x6", "0x7"}, hashes(p.GetTransactions(ctx, models.DefaultTenant, watched)))
+
+	// a transaction the provider left out of block 7 is picked up by a rescan
+	node.serve(7, []models.Transaction{tx(7, "0x7"), tx(7, "0x7b")}, nil)
+	var rescan RescanResponse
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/rescan", `{"fromBlock":5,"toBlock":7}`, &rescan))
+	require.Equal(t, RescanResponse{FromBlock: 5, ToBlock: 7, Matches: 1}, rescan)
+
+	node.serve(7, nil, assert.AnError)
+	var errResp ErrorResponse
+	require.Equal(t, http.StatusBadGateway, postAdmin(t, router, "/admin/parser/rescan", `{"fromBlock":7,"toBlock":7}`, &errResp))
+	require.Contains(t, errResp.Err, "block 7")
+}
+
+func TestAdmin_NotServedWithoutAuth(t *testing.T) {
+	p := parser.NewParserRuntime(t.Context(), stubClient{}, data_store.NewDataStore(), parser.ParserConfig{})
+	router := NewRouter(p, WithParserControl(p))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/parser/pause", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.False(t, p.Status().Paused)
+}
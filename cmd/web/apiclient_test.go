@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/apiclient"
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPIClient - the shared client against the real router, so the two cannot drift apart
+func TestAPIClient(t *testing.T) {
+	ctx := t.Context()
+	// the client has its own types, it shares none with the server
+	apiWatched, apiUnwatched := apiclient.Address(watched), apiclient.Address(unwatched)
+	node := &providerClient{head: 7, blocks: map[int][]models.Transaction{}}
+	p := parser.NewParserRuntime(ctx, node, data_store.NewDataStore(),
+		parser.ParserConfig{TxFetchInterval: 10 * time.Millisecond, Workers: 2})
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("admin", "", adminKey, []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err)
+	_, err = keyring.Add("acme", "acme", readKey, []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+	srv := httptest.NewServer(NewRouter(p, WithAuth(keyring)))
+	t.Cleanup(srv.Close)
+
+	c, err := apiclient.New(srv.URL, apiclient.WithAPIKey(adminKey))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Subscribe(ctx, apiWatched))
+	bulk, err := c.BulkSubscribe(ctx, []apiclient.Address{apiWatched, apiUnwatched, "0x12"})
+	require.NoError(t, err)
+	require.Equal(t, 1, bulk.Created)
+	require.Equal(t, 1, bulk.Exists)
+	require.Equal(t, 1, bulk.Invalid)
+	require.Equal(t, apiclient.BulkCreated, bulk.Results[1].Status)
+	require.NoError(t, c.Unsubscribe(ctx, apiUnwatched))
+	addrs, err := c.Subscribers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []apiclient.Address{apiWatched}, addrs)
+	// another tenant does not see them
+	acme, err := apiclient.New(srv.URL, apiclient.WithAPIKey(readKey))
+	require.NoError(t, err)
+	addrs, err = acme.Subscribers(ctx)
+	require.NoError(t, err)
+	require.Empty(t, addrs)
+
+	sub, err := c.CreateSubscription(ctx, apiclient.Rule{Type: "address", Addresses: []apiclient.Address{apiWatched}})
+	require.NoError(t, err)
+	subs, err := c.Subscriptions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []apiclient.Subscription{sub}, subs)
+
+	// matches arrive while watching
+	watchErr := make(chan error, 1)
+	matches := make(chan apiclient.Match, 1)
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	go func() {
+		watchErr <- c.Watch(watchCtx, []apiclient.Address{apiWatched}, func(m apiclient.Match) error {
+			matches <- m
+			return nil
+		})
+	}()
+	// Parse stops with the test context
+	go p.Parse()
+	// the block keeps growing until the watch, subscribed at some point, sees a match
+	var served []models.Transaction
+	var match apiclient.Match
+	for match.Address == "" {
+		tx := grpcTx(7, watched)
+		tx.Hash = fmt.Sprintf("0x%064x", len(served))
+		served = append(served, tx)
+		node.serve(7, slices.Clone(served), nil)
+		select {
+		case match = <-matches:
+		case <-time.After(20 * time.Millisecond):
+		}
+		require.Less(t, len(served), 250, "no match")
+	}
+	require.Equal(t, apiWatched, match.Address)
+	require.Contains(t, apiTxs(t, served), match.Transaction)
+	stopWatch()
+	require.ErrorIs(t, <-watchErr, context.Canceled)
+
+	block, err := c.CurrentBlock(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 7, block)
+	status, err := c.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 7, status.CurrentBlock)
+
+	txs, err := c.Transactions(ctx, apiWatched)
+	require.NoError(t, err)
+	require.Contains(t, txs, match.Transaction)
+	matched, err := c.SubscriptionTransactions(ctx, sub.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, matched)
+
+	export, err := c.ExportTransactions(ctx, apiWatched, apiclient.FormatCSV, []string{"block", "hash"})
+	require.NoError(t, err)
+	csv, err := io.ReadAll(export)
+	require.NoError(t, err)
+	require.NoError(t, export.Close())
+	require.Contains(t, string(csv), "block,hash\n")
+	require.Contains(t, string(csv), "7,"+match.Transaction.Hash+"\n")
+
+	require.NoError(t, c.DeleteSubscription(ctx, sub.ID))
+	err = c.DeleteSubscription(ctx, sub.ID)
+	require.True(t, apiclient.IsStatus(err, http.StatusNotFound), "%v", err)
+
+	// a refused watch and a missing key come back as errors
+	err = c.Watch(ctx, []apiclient.Address{apiUnwatched}, func(apiclient.Match) error { return nil })
+	require.ErrorIs(t, err, apiclient.ErrWatchSubscribe)
+	anonymous, err := apiclient.New(srv.URL)
+	require.NoError(t, err)
+	_, err = anonymous.Status(ctx)
+	require.True(t, apiclient.IsStatus(err, http.StatusUnauthorized), "%v", err)
+	err = anonymous.Watch(ctx, []apiclient.Address{apiWatched}, func(apiclient.Match) error { return nil })
+	var apiErr *apiclient.APIError
+	require.True(t, errors.As(err, &apiErr), "%v", err)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+// apiTxs - txs as the client decodes them
+func apiTxs(t *testing.T, txs []models.Transaction) []apiclient.Transaction {
+	t.Helper()
+	data, err := json.Marshal(txs)
+	require.NoError(t, err)
+	var decoded []apiclient.Transaction
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	return decoded
+}
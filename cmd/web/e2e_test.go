@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/apiclient"
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/fakenode"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// e2eFixture - the parser reading a fake node through the JSON-RPC client, served by the router with auth,
+// and a client of the API with the admin key. The node has 10 blocks and the parser runs until the test ends.
+func e2eFixture(t *testing.T) (*fakenode.Node, *apiclient.Client, http.Handler) {
+	t.Helper()
+	ctx := t.Context()
+	node := fakenode.New(t)
+	node.Mine(10)
+	p := parser.NewParserRuntime(ctx, client.NewClient(node.URL()), data_store.NewDataStore(),
+		parser.ParserConfig{TxFetchInterval: 5 * time.Millisecond, Workers: 2})
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("admin", "", adminKey, []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err)
+	router := NewRouter(p, WithAuth(keyring), WithParserControl(p))
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	api, err := apiclient.New(srv.URL, apiclient.WithAPIKey(adminKey))
+	require.NoError(t, err)
+
+	require.NoError(t, api.Subscribe(ctx, apiclient.Address(watched)))
+	// Parse stops with the test context
+	go p.Parse()
+	waitForBlock(t, api, 10)
+
+	return node, api, router
+}
+
+// waitForBlock - waits until the parser reached block
+func waitForBlock(t *testing.T, api *apiclient.Client, block int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		current, err := api.CurrentBlock(t.Context())
+		return err == nil && current >= block
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+// waitForTransactions - waits until the watched address has exactly the transactions of hashes, in any order
+func waitForTransactions(t *testing.T, api *apiclient.Client, hashes ...string) {
+	t.Helper()
+	slices.Sort(hashes)
+	var got []string
+	assert.Eventually(t, func() bool {
+		txs, err := api.Transactions(t.Context(), apiclient.Address(watched))
+		if err != nil {
+			return false
+		}
+		got = got[:0]
+		for _, tx := range txs {
+			got = append(got, tx.Hash)
+		}
+		slices.Sort(got)
+		return slices.Equal(hashes, got)
+	}, 2*time.Second, 5*time.Millisecond)
+	require.Equal(t, hashes, got)
+}
+
+func TestEndToEnd_Parsing(t *testing.T) {
+	node, api, _ := e2eFixture(t)
+
+	node.AddBlock(fakenode.Tx{From: watched, To: unwatched, Value: "0xde0b6b3a7640000"})
+	node.AddBlock(
+		fakenode.Tx{From: unwatched, To: unwatched},
+		fakenode.Tx{From: unwatched, To: watched},
+	)
+	// a slow node slows the parser down, nothing is missed
+	node.SetLatency(15 * time.Millisecond)
+	node.Mine(2)
+	node.AddBlock(fakenode.Tx{From: unwatched, To: watched})
+	node.SetLatency(0)
+
+	waitForTransactions(t, api, node.Transactions(11)[0].Hash, node.Transactions(12)[1].Hash, node.Transactions(15)[0].Hash)
+	txs, err := api.Transactions(t.Context(), apiclient.Address(watched))
+	require.NoError(t, err)
+	require.Contains(t, txs, apiTxs(t, node.Transactions(11))[0])
+
+	status, err := api.Status(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 15, status.RemoteHead)
+	assert.Equal(t, node.URL(), status.Endpoint)
+	assert.Empty(t, status.LastError)
+}
+
+func TestEndToEnd_NodeFailures(t *testing.T) {
+	node, api, _ := e2eFixture(t)
+	ctx := t.Context()
+
+	// a block the node fails to serve is fetched again, not skipped
+	node.FailCalls("eth_getBlockByNumber", 3, client.RPCError{Code: fakenode.CodeServerError, Message: "header not found"})
+	node.FailRequests(2, http.StatusServiceUnavailable)
+	first := node.AddBlock(fakenode.Tx{From: watched, To: unwatched})
+	waitForTransactions(t, api, node.Transactions(first)[0].Hash)
+	status, err := api.Status(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, status.LastError)
+	assert.False(t, status.LastErrorAt.IsZero())
+
+	// rate limited, the ticks that fit in the limit get through. A tick asks for the head and every block
+	// from the current one, one more block than the limit allows would stall the parser.
+	node.SetRateLimit(3, 30*time.Millisecond)
+	second := node.AddBlock(fakenode.Tx{From: unwatched, To: watched})
+	waitForTransactions(t, api, node.Transactions(first)[0].Hash, node.Transactions(second)[0].Hash)
+	waitForBlock(t, api, node.Head())
+	require.Eventually(t, func() bool {
+		status, err := api.Status(ctx)
+		return err == nil && strings.Contains(status.LastError, "429")
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestEndToEnd_Reorg(t *testing.T) {
+	node, api, router := e2eFixture(t)
+
+	orphaned := node.AddBlock(fakenode.Tx{From: watched, To: unwatched, Value: "0x1"})
+	orphanedTx := node.Transactions(orphaned)[0].Hash
+	waitForTransactions(t, api, orphanedTx)
+
+	// the block is replaced by one with another transaction of the address, the parser does not notice
+	// reorgs by itself so the operator rewinds it past the fork
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/pause", "", nil))
+	node.Reorg(1)
+	node.AddBlock(fakenode.Tx{From: watched, To: unwatched, Value: "0x2"})
+	node.Mine(2)
+	canonicalTx := node.Transactions(orphaned)[0].Hash
+
+	var rewind RewindResponse
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/rewind", fmt.Sprintf(`{"block":%d,"purge":true}`, orphaned-1), &rewind))
+	assert.Equal(t, 1, rewind.Purged)
+	require.Equal(t, http.StatusOK, postAdmin(t, router, "/admin/parser/resume", "", nil))
+	waitForBlock(t, api, node.Head())
+	waitForTransactions(t, api, canonicalTx)
+}
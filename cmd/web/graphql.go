@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/graph-gophers/graphql-go"
+	gqllog "github.com/graph-gophers/graphql-go/log"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// maxBlockSpan - blocks a blocks query may cover, its range is read whole before it is paginated
+	maxBlockSpan = 10_000
+	// graphqlMaxDepth - nesting allowed in a query, the schema itself is at most five levels deep
+	graphqlMaxDepth = 10
+	cursorPrefix    = "cursor:"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// newGraphQLSchema - the /graphql schema resolving through p, a panicking resolver is logged and fails only its field
+func newGraphQLSchema(p parser.Parser, logger *slog.Logger) *graphql.Schema {
+	return graphql.MustParseSchema(graphqlSchema, &queryResolver{parser: p},
+		graphql.MaxDepth(graphqlMaxDepth),
+		graphql.Logger(gqllog.LoggerFunc(func(ctx context.Context, value any) {
+			logging.FromContext(ctx, logger).Error("graphql resolver panic", slog.Any("panic", value))
+		})),
+	)
+}
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// GraphQL - runs a query of schema.graphql as the caller's tenant. Query errors are reported in the
+// response body next to the data that did resolve, as GraphQL clients expect, so the status stays 200.
+func (h *Router) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var request GraphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+	response := h.graphql.Exec(r.Context(), request.Query, request.OperationName, request.Variables)
+	if len(response.Errors) > 0 {
+		logging.FromContext(r.Context(), h.logger).Info("graphql query errors",
+			slog.Int("errors", len(response.Errors)),
+			slog.String("first", response.Errors[0].Message),
+		)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// batch - values of every key of one result list, loaded with a single call the first time any of them is resolved.
+// Resolvers of list items share their list's batch, so the store is read once per list instead of once per item.
+type batch[K comparable, V any] struct {
+	keys   []K
+	load   func(ctx context.Context, keys []K) map[K]V
+	once   sync.Once
+	values map[K]V
+}
+
+func newBatch[K comparable, V any](keys []K, load func(ctx context.Context, keys []K) map[K]V) *batch[K, V] {
+	return &batch[K, V]{keys: keys, load: load}
+}
+
+func (b *batch[K, V]) get(ctx context.Context, key K) V {
+	b.once.Do(func() {
+		b.values = b.load(ctx, b.keys)
+	})
+	return b.values[key]
+}
+
+type queryResolver struct {
+	parser parser.Parser
+}
+
+func (q *queryResolver) CurrentBlock(ctx context.Context) int32 {
+	return int32(q.parser.GetCurrentBlock(ctx))
+}
+
+func (q *queryResolver) Address(ctx context.Context, args struct{ Address string }) (*addressResolver, error) {
+	subs, err := q.Addresses(ctx, struct{ Addresses []string }{Addresses: []string{args.Address}})
+	if err != nil {
+		return nil, err
+	}
+	return subs[0], nil
+}
+
+func (q *queryResolver) Addresses(ctx context.Context, args struct{ Addresses []string }) ([]*addressResolver, error) {
+	addrs := make([]models.Address, 0, len(args.Addresses))
+	for _, s := range args.Addresses {
+		if !models.Address(s).Valid() {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		// batched results come back keyed by the stored form
+		addrs = append(addrs, models.NormalizeAddress(s))
+	}
+	tenant := auth.Tenant(ctx)
+	txs := newBatch(addrs, func(ctx context.Context, addrs []models.Address) map[models.Address][]models.Transaction {
+		byAddr := make(map[models.Address][]models.Transaction, len(addrs))
+		for addr, tx := range q.parser.ScanTransactions(ctx, data_store.TxQuery{Tenant: tenant, Addresses: addrs}) {
+			byAddr[addr] = append(byAddr[addr], tx)
+		}
+		return byAddr
+	})
+	subs := make([]*addressResolver, 0, len(addrs))
+	for _, addr := range addrs {
+		subs = append(subs, &addressResolver{address: addr, txs: txs})
+	}
+
+	return subs, nil
+}
+
+func (q *queryResolver) RuleSubscriptions(ctx context.Context, args pageArgs) (*connection[*ruleSubscriptionResolver], error) {
+	tenant := auth.Tenant(ctx)
+	conn, err := paginate(q.parser.GetSubscriptions(ctx, tenant), args)
+	if err != nil {
+		return nil, err
+	}
+	// matches are read only for the subscriptions on the page
+	ids := make([]string, 0, len(conn.page))
+	for _, sub := range conn.page {
+		ids = append(ids, sub.ID)
+	}
+	matches := q.matchesBatch(tenant, ids)
+
+	return mapConnection(conn, func(sub models.Subscription) *ruleSubscriptionResolver {
+		return &ruleSubscriptionResolver{sub: sub, txs: matches}
+	}), nil
+}
+
+func (q *queryResolver) RuleSubscription(ctx context.Context, args struct{ ID graphql.ID }) *ruleSubscriptionResolver {
+	tenant := auth.Tenant(ctx)
+	for _, sub := range q.parser.GetSubscriptions(ctx, tenant) {
+		if sub.ID == string(args.ID) {
+			return &ruleSubscriptionResolver{sub: sub, txs: q.matchesBatch(tenant, []string{sub.ID})}
+		}
+	}
+	return nil
+}
+
+func (q *queryResolver) matchesBatch(tenant string, ids []string) *batch[string, []models.Transaction] {
+	return newBatch(ids, func(ctx context.Context, ids []string) map[string][]models.Transaction {
+		return q.parser.BatchGetSubscriptionTransactions(ctx, tenant, ids)
+	})
+}
+
+func (q *queryResolver) Block(ctx context.Context, args struct{ Number int32 }) (*blockResolver, error) {
+	if args.Number < 0 {
+		return nil, fmt.Errorf("%w: block %d", errInvalidBlockRange, args.Number)
+	}
+	blocks := q.scanBlocks(ctx, int(args.Number), int(args.Number))
+	if len(blocks) == 0 {
+		return &blockResolver{number: int(args.Number)}, nil
+	}
+	return blocks[0], nil
+}
+
+func (q *queryResolver) Blocks(ctx context.Context, args struct {
+	From  int32
+	To    int32
+	First *int32
+	After *string
+}) (*connection[*blockResolver], error) {
+	if args.From < 0 || args.To < args.From {
+		return nil, fmt.Errorf("%w: %d..%d", errInvalidBlockRange, args.From, args.To)
+	}
+	if int(args.To)-int(args.From) >= maxBlockSpan {
+		return nil, fmt.Errorf("%w: %d..%d spans more than %d blocks", errInvalidBlockRange, args.From, args.To, maxBlockSpan)
+	}
+	return paginate(q.scanBlocks(ctx, int(args.From), int(args.To)), pageArgs{First: args.First, After: args.After})
+}
+
+// scanBlocks - blocks of from..to holding transactions of the tenant's addresses, read with one scan.
+// A transaction between two watched addresses is listed once.
+func (q *queryResolver) scanBlocks(ctx context.Context, from, to int) []*blockResolver {
+	// a zero bound leaves the store's range open, the block numbers are checked again below
+	query := data_store.TxQuery{Tenant: auth.Tenant(ctx), FromBlock: max(from, 1), ToBlock: max(to, 1)}
+	byNumber := make(map[int]*blockResolver)
+	seen := make(map[models.TxKey]struct{})
+	for _, tx := range q.parser.ScanTransactions(ctx, query) {
+		if _, ok := seen[tx.Key()]; ok {
+			continue
+		}
+		seen[tx.Key()] = struct{}{}
+		number, err := helpers.ParseHexInt(tx.BlockNumber)
+		if err != nil || number < from || number > to {
+			continue
+		}
+		block, ok := byNumber[number]
+		if !ok {
+			block = &blockResolver{number: number}
+			byNumber[number] = block
+		}
+		block.txs = append(block.txs, tx)
+	}
+	blocks := make([]*blockResolver, 0, len(byNumber))
+	for _, block := range byNumber {
+		blocks = append(blocks, block)
+	}
+	slices.SortFunc(blocks, func(a, b *blockResolver) int {
+		return a.number - b.number
+	})
+
+	return blocks
+}
+
+type addressResolver struct {
+	address models.Address
+	txs     *batch[models.Address, []models.Transaction]
+}
+
+func (a *addressResolver) Address() string {
+	return string(a.address)
+}
+
+func (a *addressResolver) Transactions(ctx context.Context, args txArgs) (*connection[*txResolver], error) {
+	return transactions(a.txs.get(ctx, a.address), args)
+}
+
+type ruleSubscriptionResolver struct {
+	sub models.Subscription
+	txs *batch[string, []models.Transaction]
+}
+
+func (s *ruleSubscriptionResolver) ID() graphql.ID {
+	return graphql.ID(s.sub.ID)
+}
+
+func (s *ruleSubscriptionResolver) Rule() ruleResolver {
+	return ruleResolver{s.sub.Rule}
+}
+
+func (s *ruleSubscriptionResolver) Transactions(ctx context.Context, args txArgs) (*connection[*txResolver], error) {
+	return transactions(s.txs.get(ctx, s.sub.ID), args)
+}
+
+type ruleResolver struct {
+	rule models.Rule
+}
+
+func (r ruleResolver) Type() string {
+	return string(r.rule.Type)
+}
+
+func (r ruleResolver) Addresses() []string {
+	addrs := make([]string, 0, len(r.rule.Addresses))
+	for _, addr := range r.rule.Addresses {
+		addrs = append(addrs, string(addr))
+	}
+	return addrs
+}
+
+func (r ruleResolver) Value() *string {
+	return optional(r.rule.Value)
+}
+
+func (r ruleResolver) Selectors() []string {
+	return append(make([]string, 0, len(r.rule.Selectors)), r.rule.Selectors...)
+}
+
+func (r ruleResolver) Rules() []ruleResolver {
+	rules := make([]ruleResolver, 0, len(r.rule.Rules))
+	for _, rule := range r.rule.Rules {
+		rules = append(rules, ruleResolver{rule})
+	}
+	return rules
+}
+
+type blockResolver struct {
+	number int
+	txs    []models.Transaction
+}
+
+func (b *blockResolver) Number() int32 {
+	return int32(b.number)
+}
+
+func (b *blockResolver) Transactions(args txArgs) (*connection[*txResolver], error) {
+	return transactions(b.txs, args)
+}
+
+type txResolver struct {
+	tx models.Transaction
+}
+
+func (t *txResolver) BlockNumber() string      { return t.tx.BlockNumber }
+func (t *txResolver) Hash() string             { return t.tx.Hash }
+func (t *txResolver) From() string             { return string(t.tx.From) }
+func (t *txResolver) To() *string              { return optional(string(t.tx.To)) }
+func (t *txResolver) TransactionIndex() string { return t.tx.TransactionIndex }
+func (t *txResolver) Value() *string           { return optional(t.tx.Value) }
+func (t *txResolver) Input() *string           { return optional(t.tx.Input) }
+func (t *txResolver) LogIndex() *string        { return optional(t.tx.LogIndex) }
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+type txFilter struct {
+	FromBlock *int32
+	ToBlock   *int32
+	Party     *string
+}
+
+func (f *txFilter) keep(tx models.Transaction) bool {
+	if f == nil {
+		return true
+	}
+	if f.Party != nil && !tx.BelongsToAddr(models.NormalizeAddress(*f.Party)) {
+		return false
+	}
+	if f.FromBlock == nil && f.ToBlock == nil {
+		return true
+	}
+	block, err := helpers.ParseHexInt(tx.BlockNumber)
+	if err != nil {
+		return false
+	}
+	return (f.FromBlock == nil || block >= int(*f.FromBlock)) && (f.ToBlock == nil || block <= int(*f.ToBlock))
+}
+
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+type txArgs struct {
+	Filter *txFilter
+	First  *int32
+	After  *string
+}
+
+func transactions(txs []models.Transaction, args txArgs) (*connection[*txResolver], error) {
+	kept := make([]models.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if args.Filter.keep(tx) {
+			kept = append(kept, tx)
+		}
+	}
+	conn, err := paginate(kept, pageArgs{First: args.First, After: args.After})
+	if err != nil {
+		return nil, err
+	}
+	return mapConnection(conn, func(tx models.Transaction) *txResolver {
+		return &txResolver{tx}
+	}), nil
+}
+
+// connection - a page of a list, the cursor of an item is its opaque offset in the list
+type connection[T any] struct {
+	page   []T
+	offset int
+	total  int
+}
+
+// paginate - first items after the after cursor
+func paginate[T any](items []T, args pageArgs) (*connection[T], error) {
+	first := defaultPageSize
+	if args.First != nil {
+		first = int(*args.First)
+	}
+	if first < 0 || first > maxPageSize {
+		return nil, fmt.Errorf("first must be between 0 and %d", maxPageSize)
+	}
+	offset := 0
+	if args.After != nil {
+		after, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		offset = min(after+1, len(items))
+	}
+	end := min(offset+first, len(items))
+
+	return &connection[T]{page: items[offset:end], offset: offset, total: len(items)}, nil
+}
+
+func mapConnection[T, U any](c *connection[T], fn func(T) U) *connection[U] {
+	page := make([]U, 0, len(c.page))
+	for _, item := range c.page {
+		page = append(page, fn(item))
+	}
+	return &connection[U]{page: page, offset: c.offset, total: c.total}
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(b), cursorPrefix) {
+		return 0, fmt.Errorf("%w %q", errInvalidCursor, cursor)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(b), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%w %q", errInvalidCursor, cursor)
+	}
+	return offset, nil
+}
+
+func (c *connection[T]) TotalCount() int32 {
+	return int32(c.total)
+}
+
+func (c *connection[T]) Nodes() []T {
+	return c.page
+}
+
+func (c *connection[T]) Edges() []edge[T] {
+	edges := make([]edge[T], 0, len(c.page))
+	for i, node := range c.page {
+		edges = append(edges, edge[T]{cursor: encodeCursor(c.offset + i), node: node})
+	}
+	return edges
+}
+
+func (c *connection[T]) PageInfo() pageInfo {
+	info := pageInfo{hasNextPage: c.offset+len(c.page) < c.total}
+	if len(c.page) > 0 {
+		cursor := encodeCursor(c.offset + len(c.page) - 1)
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+type edge[T any] struct {
+	cursor string
+	node   T
+}
+
+func (e edge[T]) Cursor() string {
+	return e.cursor
+}
+
+func (e edge[T]) Node() T {
+	return e.node
+}
+
+type pageInfo struct {
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (p pageInfo) EndCursor() *string {
+	return p.endCursor
+}
+
+func (p pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// countingParser - counts the store reads resolvers make
+type countingParser struct {
+	parser.Parser
+	scans   atomic.Int32
+	batches atomic.Int32
+	single  atomic.Int32
+}
+
+func (c *countingParser) ScanTransactions(ctx context.Context, q data_store.TxQuery) iter.Seq2[models.Address, models.Transaction] {
+	c.scans.Add(1)
+	return c.Parser.ScanTransactions(ctx, q)
+}
+
+func (c *countingParser) BatchGetSubscriptionTransactions(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction {
+	c.batches.Add(1)
+	return c.Parser.BatchGetSubscriptionTransactions(ctx, tenant, ids)
+}
+
+func (c *countingParser) GetSubscriptionTransactions(ctx context.Context, tenant, id string) ([]models.Transaction, error) {
+	c.single.Add(1)
+	return c.Parser.GetSubscriptionTransactions(ctx, tenant, id)
+}
+
+func newGraphQLFixture(t *testing.T, opts ...RouterOption) (*Router, *countingParser, data_store.DataStore) {
+	t.Helper()
+	store := data_store.NewDataStore()
+	p := &countingParser{Parser: parser.NewParserRuntime(t.Context(), stubClient{}, store, parser.ParserConfig{})}
+	return NewRouter(p, opts...), p, store
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func queryGraphQL(t *testing.T, router http.Handler, key, query string, variables map[string]any) graphqlResponse {
+	t.Helper()
+	body, err := json.Marshal(GraphQLRequest{Query: query, Variables: variables})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(auth.Header, key)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp graphqlResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func graphqlTx(block int, from models.Address, logIndex string) models.Transaction {
+	tx := grpcTx(block, from)
+	tx.LogIndex = logIndex
+	return tx
+}
+
+func TestGraphQL_RuleSubscriptionsBatched(t *testing.T) {
+	ctx := t.Context()
+	router, p, store := newGraphQLFixture(t)
+	for i := range 3 {
+		sub, err := p.CreateSubscription(ctx, models.DefaultTenant, models.Rule{Type: models.RuleContractCreation})
+		require.NoError(t, err)
+		require.True(t, store.AddMatch(ctx, sub.ID, graphqlTx(i+1, watched, "")))
+		require.True(t, store.AddMatch(ctx, sub.ID, graphqlTx(i+1, watched, "0x2")))
+	}
+
+	resp := queryGraphQL(t, router, "", `{
+		ruleSubscriptions {
+			totalCount
+			nodes {
+				id
+				rule { type }
+				transactions { totalCount nodes { logIndex } }
+			}
+		}
+	}`, nil)
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		RuleSubscriptions struct {
+			TotalCount int
+			Nodes      []struct {
+				ID           string
+				Rule         struct{ Type string }
+				Transactions struct {
+					TotalCount int
+					Nodes      []struct{ LogIndex *string }
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Equal(t, 3, data.RuleSubscriptions.TotalCount)
+	require.Len(t, data.RuleSubscriptions.Nodes, 3)
+	for _, node := range data.RuleSubscriptions.Nodes {
+		require.Equal(t, string(models.RuleContractCreation), node.Rule.Type)
+		require.Equal(t, 2, node.Transactions.TotalCount)
+		require.Len(t, node.Transactions.Nodes, 2)
+		require.Nil(t, node.Transactions.Nodes[0].LogIndex)
+		require.Equal(t, "0x2", *node.Transactions.Nodes[1].LogIndex)
+	}
+
+	// the matches of the whole page are read once
+	require.EqualValues(t, 1, p.batches.Load())
+	require.Zero(t, p.single.Load())
+
+	resp = queryGraphQL(t, router, "", `query($id: ID!) { ruleSubscription(id: $id) { id } }`, map[string]any{"id": "missing"})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"ruleSubscription":null}`, string(resp.Data))
+}
+
+func TestGraphQL_AddressesBatched(t *testing.T) {
+	ctx := t.Context()
+	router, p, store := newGraphQLFixture(t)
+	other := models.Address("0x00000000000000000000000000000000000000aa")
+	for _, addr := range []models.Address{watched, other} {
+		require.True(t, p.Subscribe(ctx, models.DefaultTenant, addr))
+	}
+	for i := 1; i <= 3; i++ {
+		require.True(t, store.AddTx(ctx, watched, graphqlTx(i, watched, "")))
+	}
+	require.True(t, store.AddTx(ctx, other, graphqlTx(5, other, "0x0")))
+
+	const query = `query($addrs: [String!]!) {
+		addresses(addresses: $addrs) {
+			address
+			transactions(filter: {fromBlock: 2}) { totalCount nodes { blockNumber } }
+		}
+	}`
+	resp := queryGraphQL(t, router, "", query, map[string]any{"addrs": []string{string(watched), "0x12"}})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "invalid address")
+
+	// addresses are matched lower cased, as the node returns them
+	resp = queryGraphQL(t, router, "", query, map[string]any{"addrs": []string{"0x" + strings.ToUpper(string(watched[2:])), string(other), string(unwatched)}})
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, fmt.Sprintf(`{"addresses":[
+		{"address":%q,"transactions":{"totalCount":2,"nodes":[{"blockNumber":"0x2"},{"blockNumber":"0x3"}]}},
+		{"address":%q,"transactions":{"totalCount":1,"nodes":[{"blockNumber":"0x5"}]}},
+		{"address":%q,"transactions":{"totalCount":0,"nodes":[]}}
+	]}`, watched, other, unwatched), string(resp.Data))
+
+	// every address of the list is read with one scan
+	require.EqualValues(t, 1, p.scans.Load())
+}
+
+func TestGraphQL_Pagination(t *testing.T) {
+	ctx := t.Context()
+	router, p, store := newGraphQLFixture(t)
+	require.True(t, p.Subscribe(ctx, models.DefaultTenant, watched))
+	for i := 1; i <= 5; i++ {
+		require.True(t, store.AddTx(ctx, watched, graphqlTx(i, watched, "")))
+	}
+
+	const query = `query($after: String) {
+		address(address: "` + string(watched) + `") {
+			transactions(first: 2, after: $after) {
+				totalCount
+				edges { cursor node { blockNumber } }
+				pageInfo { endCursor hasNextPage }
+			}
+		}
+	}`
+	type page struct {
+		Address struct {
+			Transactions struct {
+				TotalCount int
+				Edges      []struct {
+					Cursor string
+					Node   struct{ BlockNumber string }
+				}
+				PageInfo struct {
+					EndCursor   *string
+					HasNextPage bool
+				}
+			}
+		}
+	}
+
+	var (
+		blocks []string
+		after  *string
+	)
+	for range 3 {
+		resp := queryGraphQL(t, router, "", query, map[string]any{"after": after})
+		require.Empty(t, resp.Errors)
+		var data page
+		require.NoError(t, json.Unmarshal(resp.Data, &data))
+		conn := data.Address.Transactions
+		require.Equal(t, 5, conn.TotalCount)
+		for _, e := range conn.Edges {
+			blocks = append(blocks, e.Node.BlockNumber)
+		}
+		require.Equal(t, conn.Edges[len(conn.Edges)-1].Cursor, *conn.PageInfo.EndCursor)
+		after = conn.PageInfo.EndCursor
+		require.Equal(t, len(blocks) < 5, conn.PageInfo.HasNextPage)
+	}
+	require.Equal(t, []string{"0x1", "0x2", "0x3", "0x4", "0x5"}, blocks)
+
+	// past the end
+	resp := queryGraphQL(t, router, "", query, map[string]any{"after": after})
+	require.Empty(t, resp.Errors)
+	var data page
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Empty(t, data.Address.Transactions.Edges)
+	require.Nil(t, data.Address.Transactions.PageInfo.EndCursor)
+	require.False(t, data.Address.Transactions.PageInfo.HasNextPage)
+
+	resp = queryGraphQL(t, router, "", query, map[string]any{"after": "bogus"})
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "invalid cursor")
+
+	resp = queryGraphQL(t, router, "", `{ ruleSubscriptions(first: 1000) { totalCount } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "first must be between 0 and 100")
+}
+
+func TestGraphQL_Blocks(t *testing.T) {
+	ctx := t.Context()
+	router, p, store := newGraphQLFixture(t)
+	require.True(t, p.Subscribe(ctx, models.DefaultTenant, watched))
+	require.True(t, p.Subscribe(ctx, models.DefaultTenant, unwatched))
+	// a transaction between two watched addresses is stored for both
+	require.True(t, store.AddTx(ctx, watched, graphqlTx(2, watched, "")))
+	require.True(t, store.AddTx(ctx, unwatched, graphqlTx(2, watched, "")))
+	require.True(t, store.AddTx(ctx, watched, graphqlTx(4, watched, "")))
+	require.True(t, store.AddTx(ctx, watched, graphqlTx(9, watched, "")))
+
+	resp := queryGraphQL(t, router, "", `{
+		blocks(from: 1, to: 5) { totalCount nodes { number transactions { totalCount } } }
+		block(number: 3) { number transactions { totalCount } }
+	}`, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{
+		"blocks":{"totalCount":2,"nodes":[
+			{"number":2,"transactions":{"totalCount":1}},
+			{"number":4,"transactions":{"totalCount":1}}
+		]},
+		"block":{"number":3,"transactions":{"totalCount":0}}
+	}`, string(resp.Data))
+
+	resp = queryGraphQL(t, router, "", `{ blocks(from: 5, to: 1) { totalCount } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "invalid block range")
+
+	resp = queryGraphQL(t, router, "", fmt.Sprintf(`{ blocks(from: 1, to: %d) { totalCount } }`, maxBlockSpan), nil)
+	require.Empty(t, resp.Errors)
+	resp = queryGraphQL(t, router, "", `{ blocks(from: 0, to: 2147483647) { totalCount } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	require.Contains(t, resp.Errors[0].Message, "spans more than")
+}
+
+func TestGraphQL_Tenant(t *testing.T) {
+	ctx := t.Context()
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("reader", "acme", readKey, []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+	router, p, store := newGraphQLFixture(t, WithAuth(keyring))
+
+	require.True(t, p.Subscribe(ctx, models.DefaultTenant, watched))
+	require.True(t, store.AddTx(ctx, watched, graphqlTx(1, watched, "")))
+	_, err = p.CreateSubscription(ctx, models.DefaultTenant, models.Rule{Type: models.RuleContractCreation})
+	require.NoError(t, err)
+
+	// another tenant's subscriptions and transactions are not visible
+	resp := queryGraphQL(t, router, readKey, `{
+		ruleSubscriptions { totalCount }
+		address(address: "`+string(watched)+`") { transactions { totalCount } }
+	}`, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"ruleSubscriptions":{"totalCount":0},"address":{"transactions":{"totalCount":0}}}`, string(resp.Data))
+}
+
+func TestGraphQL_NotAudited(t *testing.T) {
+	var audit bytes.Buffer
+	router, _, _ := newGraphQLFixture(t, WithAuditLog(slog.New(slog.NewTextHandler(&audit, nil))))
+
+	resp := queryGraphQL(t, router, "", `{ currentBlock }`, nil)
+	require.Empty(t, resp.Errors)
+	// queries are reads, though they are posted
+	require.Empty(t, audit.String())
+
+	req := httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(`{"address":"`+string(watched)+`"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	require.Contains(t, audit.String(), "POST /subscribe")
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	ethparserv1 "github.com/galecic/ethereum_parser/api/ethparser/v1"
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/ratelimit"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// grpcScopes - scope each ParserService method needs, methods without one (health, reflection) are open
+var grpcScopes = map[string]auth.Scope{
+	ethparserv1.ParserService_GetCurrentBlock_FullMethodName:   auth.ScopeRead,
+	ethparserv1.ParserService_Subscribe_FullMethodName:         auth.ScopeSubscriptions,
+	ethparserv1.ParserService_Unsubscribe_FullMethodName:       auth.ScopeSubscriptions,
+	ethparserv1.ParserService_GetTransactions_FullMethodName:   auth.ScopeRead,
+	ethparserv1.ParserService_WatchTransactions_FullMethodName: auth.ScopeRead,
+}
+
+// grpcMutating - methods written to the audit log
+var grpcMutating = map[string]bool{
+	ethparserv1.ParserService_Subscribe_FullMethodName:   true,
+	ethparserv1.ParserService_Unsubscribe_FullMethodName: true,
+}
+
+// grpcShutdownTimeout - time running calls get to finish on shutdown, watch streams never do on their own
+const grpcShutdownTimeout = 5 * time.Second
+
+// grpcService - ParserService over the router's parser
+type grpcService struct {
+	ethparserv1.UnimplementedParserServiceServer
+	h *Router
+}
+
+// NewGRPCServer - ParserService over the parser of h, behind the same keys, rate limit and audit log.
+// The standard health service and server reflection are registered as well.
+func NewGRPCServer(h *Router, opts ...grpc.ServerOption) *grpc.Server {
+	s := &grpcService{h: h}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	srv := grpc.NewServer(opts...)
+	ethparserv1.RegisterParserServiceServer(srv, s)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+
+	return srv
+}
+
+// stopGRPC - graceful stop, calls still running after timeout are cancelled
+func stopGRPC(srv *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		srv.Stop()
+	}
+}
+
+func (s *grpcService) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var resp any
+	err := s.call(ctx, info.FullMethod, func(ctx context.Context) error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (s *grpcService) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return s.call(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	})
+}
+
+// serverStream - a stream carrying the context the interceptor derived
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier - propagation.TextMapCarrier over incoming gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// call - the gRPC counterpart of instrument and handle: tags the call with a request ID, traces it,
+// authorizes and rate limits it, then runs it and writes the access and audit logs
+func (s *grpcService) call(ctx context.Context, method string, run func(ctx context.Context) error) error {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := metadataCarrier(md).Get(requestIDHeader)
+	if requestID == "" {
+		requestID = rand.Text()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID))
+	logger := s.h.logger.With(slog.String(logging.KeyRequestID, requestID))
+
+	ctx = s.h.propagator.Extract(ctx, metadataCarrier(md))
+	ctx, span := s.h.tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+			attribute.String(logging.KeyRequestID, requestID),
+		),
+	)
+	defer span.End()
+	ctx = logging.WithContext(ctx, logger)
+
+	ctx, err := s.authorize(ctx, method, md)
+	if err == nil {
+		err = run(ctx)
+	}
+
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+	if grpcMutating[method] {
+		s.auditCall(ctx, method, code)
+	}
+	logger.Info("grpc request",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return err
+}
+
+// authorize - checks the key in md against the scope of method and takes a rate limit token,
+// the returned context carries the key
+func (s *grpcService) authorize(ctx context.Context, method string, md metadata.MD) (context.Context, error) {
+	scope, ok := grpcScopes[method]
+	if !ok {
+		return ctx, nil
+	}
+	var (
+		key auth.Key
+		err error
+	)
+	if s.h.keyring != nil {
+		key, err = s.h.keyring.AuthorizeSecret(auth.CredentialFrom(metadataCarrier(md).Get), scope)
+		if key.ID != "" {
+			ctx = auth.WithKey(ctx, key)
+		}
+	}
+	// failed attempts count too, as over HTTP
+	if limitErr := s.allow(ctx, key); limitErr != nil {
+		return ctx, limitErr
+	}
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		return ctx, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return ctx, nil
+}
+
+// allow - takes a token of the key's bucket, or the peer IP's without a key
+func (s *grpcService) allow(ctx context.Context, key auth.Key) error {
+	if s.h.limiter == nil {
+		return nil
+	}
+	client := "key:" + key.ID
+	if key.ID == "" {
+		client = "ip:" + peerIP(ctx)
+	}
+	ok, wait := s.h.limiter.Allow(client)
+	if ok {
+		return nil
+	}
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(wait.Seconds())))))
+	logging.FromContext(ctx, s.h.logger).Warn("rate limited", slog.String("client", client))
+
+	return status.Error(codes.ResourceExhausted, ratelimit.ErrLimited.Error())
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (s *grpcService) auditCall(ctx context.Context, method string, code codes.Code) {
+	keyID := "anonymous"
+	if key, ok := auth.FromContext(ctx); ok {
+		keyID = key.ID
+	}
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		requestID = metadataCarrier(md).Get(requestIDHeader)
+	}
+	s.h.audit.InfoContext(ctx, "audit",
+		slog.String("key_id", keyID),
+		slog.String(logging.KeyTenant, auth.Tenant(ctx)),
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.String("remote_addr", peerIP(ctx)),
+		slog.String(logging.KeyRequestID, requestID),
+	)
+}
+
+// grpcAddress - validated address of a request
+func grpcAddress(s string) (models.Address, error) {
+	addr := models.Address(strings.TrimSpace(s))
+	if !addr.Valid() {
+		return "", status.Errorf(codes.InvalidArgument, "invalid address %q", s)
+	}
+	return addr, nil
+}
+
+func (s *grpcService) GetCurrentBlock(ctx context.Context, _ *ethparserv1.GetCurrentBlockRequest) (*ethparserv1.GetCurrentBlockResponse, error) {
+	return &ethparserv1.GetCurrentBlockResponse{Block: int64(s.h.parser.GetCurrentBlock(ctx))}, nil
+}
+
+func (s *grpcService) Subscribe(ctx context.Context, req *ethparserv1.SubscribeRequest) (*ethparserv1.SubscribeResponse, error) {
+	addr, err := grpcAddress(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	created := s.h.parser.Subscribe(ctx, auth.Tenant(ctx), addr)
+
+	return &ethparserv1.SubscribeResponse{Created: created}, nil
+}
+
+func (s *grpcService) Unsubscribe(ctx context.Context, req *ethparserv1.UnsubscribeRequest) (*ethparserv1.UnsubscribeResponse, error) {
+	addr, err := grpcAddress(req.GetAddress())
+	if err != nil {
+		return nil, err
+	}
+	s.h.parser.Unsubscribe(ctx, auth.Tenant(ctx), addr)
+
+	return &ethparserv1.UnsubscribeResponse{}, nil
+}
+
+// GetTransactions - streams the history as it is read from the store, it is never held in memory whole
+func (s *grpcService) GetTransactions(req *ethparserv1.GetTransactionsRequest, stream grpc.ServerStreamingServer[ethparserv1.Transaction]) error {
+	ctx := stream.Context()
+	addr, err := grpcAddress(req.GetAddress())
+	if err != nil {
+		return err
+	}
+	from, to := req.GetFromBlock(), req.GetToBlock()
+	if from < 0 || to < 0 || (to > 0 && to < from) {
+		return status.Errorf(codes.InvalidArgument, "%s: from_block %d, to_block %d", errInvalidBlockRange, from, to)
+	}
+
+	q := data_store.TxQuery{
+		Tenant:    auth.Tenant(ctx),
+		Addresses: []models.Address{addr},
+		FromBlock: int(from),
+		ToBlock:   int(to),
+	}
+	for _, tx := range s.h.parser.ScanTransactions(ctx, q) {
+		if err := stream.Send(txMessage(tx)); err != nil {
+			return err
+		}
+	}
+
+	return status.FromContextError(ctx.Err()).Err()
+}
+
+// WatchTransactions - live matches until the client goes away, a client that cannot keep up is dropped
+func (s *grpcService) WatchTransactions(req *ethparserv1.WatchTransactionsRequest, stream grpc.ServerStreamingServer[ethparserv1.TransactionMatch]) error {
+	ctx := stream.Context()
+	if len(req.GetAddresses()) == 0 {
+		return status.Error(codes.InvalidArgument, "no addresses to watch")
+	}
+	addrs := make([]models.Address, 0, len(req.GetAddresses()))
+	for _, a := range req.GetAddresses() {
+		addr, err := grpcAddress(a)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	w, err := s.h.parser.Watch(ctx, auth.Tenant(ctx), addrs)
+	if errors.Is(err, parser.ErrNotSubscribed) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer w.Close()
+	// headers go out now, so the client knows the watch is live before the first match
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-w.Done():
+			logging.FromContext(ctx, s.h.logger).Warn("watch ended", logging.Err(w.Err()))
+			return status.Error(codes.ResourceExhausted, w.Err().Error())
+		case m := <-w.Matches():
+			err := stream.Send(&ethparserv1.TransactionMatch{
+				Address:     string(m.Address),
+				Transaction: txMessage(m.Tx),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func txMessage(tx models.Transaction) *ethparserv1.Transaction {
+	return &ethparserv1.Transaction{
+		BlockNumber:      tx.BlockNumber,
+		Hash:             tx.Hash,
+		From:             string(tx.From),
+		To:               string(tx.To),
+		TransactionIndex: tx.TransactionIndex,
+		Value:            tx.Value,
+		Input:            tx.Input,
+		LogIndex:         tx.LogIndex,
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	ethparserv1 "github.com/galecic/ethereum_parser/api/ethparser/v1"
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/ratelimit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// chainClient - a node whose head block holds txs
+type chainClient struct {
+	head int
+	txs  []models.Transaction
+}
+
+func (c chainClient) GetBlockNumber(context.Context) (int, error) { return c.head, nil }
+
+func (c chainClient) GetTxsFromBlock(_ context.Context, number int) ([]models.Transaction, error) {
+	if number != c.head {
+		return nil, nil
+	}
+	return c.txs, nil
+}
+
+func (chainClient) Endpoint() string { return "http://node.test" }
+
+type grpcFixture struct {
+	client ethparserv1.ParserServiceClient
+	conn   *grpc.ClientConn
+	parser *parser.ParserRuntime
+	store  data_store.DataStore
+}
+
+// newGRPCFixture - the gRPC server of a router over an in-memory listener
+func newGRPCFixture(t *testing.T, node chainClient, opts ...RouterOption) grpcFixture {
+	t.Helper()
+	store := data_store.NewDataStore()
+	p := parser.NewParserRuntime(t.Context(), node, store, parser.ParserConfig{
+		TxFetchInterval: 10 * time.Millisecond,
+		Workers:         2,
+	})
+	srv := NewGRPCServer(NewRouter(p, opts...))
+
+	lis := bufconn.Listen(1 << 20)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return grpcFixture{
+		client: ethparserv1.NewParserServiceClient(conn),
+		conn:   conn,
+		parser: p,
+		store:  store,
+	}
+}
+
+func grpcTx(i int, from models.Address) models.Transaction {
+	return models.Transaction{
+		BlockNumber:      fmt.Sprintf("0x%x", i),
+		Hash:             fmt.Sprintf("0x%064x", i),
+		From:             from,
+		To:               unwatched,
+		TransactionIndex: "0x0",
+		Value:            "0x1",
+	}
+}
+
+// receiveAll - messages of a server stream until it ends
+func receiveAll[T any](t *testing.T, stream grpc.ServerStreamingClient[T]) ([]*T, error) {
+	t.Helper()
+	var msgs []*T
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestGRPC_Subscribe(t *testing.T) {
+	ctx := t.Context()
+	f := newGRPCFixture(t, chainClient{})
+
+	resp, err := f.client.Subscribe(ctx, &ethparserv1.SubscribeRequest{Address: string(watched)})
+	require.NoError(t, err)
+	require.True(t, resp.GetCreated())
+	resp, err = f.client.Subscribe(ctx, &ethparserv1.SubscribeRequest{Address: string(watched)})
+	require.NoError(t, err)
+	require.False(t, resp.GetCreated())
+
+	_, err = f.client.Subscribe(ctx, &ethparserv1.SubscribeRequest{Address: "0x12"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = f.client.Unsubscribe(ctx, &ethparserv1.UnsubscribeRequest{Address: string(watched)})
+	require.NoError(t, err)
+	require.False(t, f.store.AddressExists(ctx, watched))
+
+	block, err := f.client.GetCurrentBlock(ctx, &ethparserv1.GetCurrentBlockRequest{})
+	require.NoError(t, err)
+	require.Zero(t, block.GetBlock())
+}
+
+func TestGRPC_GetTransactions(t *testing.T) {
+	ctx := t.Context()
+	f := newGRPCFixture(t, chainClient{})
+
+	// addresses are matched lower cased, as the node returns them
+	_, err := f.client.Subscribe(ctx, &ethparserv1.SubscribeRequest{Address: "0xB0BC44CA9EF6EB6F4EAAC6807C9F6307F8136497"})
+	require.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		require.True(t, f.store.AddTx(ctx, watched, grpcTx(i, watched)))
+	}
+
+	stream, err := f.client.GetTransactions(ctx, &ethparserv1.GetTransactionsRequest{Address: string(watched)})
+	require.NoError(t, err)
+	txs, err := receiveAll(t, stream)
+	require.NoError(t, err)
+	require.Len(t, txs, 3)
+	require.Equal(t, grpcTx(1, watched).Hash, txs[0].GetHash())
+	require.Equal(t, string(watched), txs[0].GetFrom())
+
+	stream, err = f.client.GetTransactions(ctx, &ethparserv1.GetTransactionsRequest{Address: string(watched), FromBlock: 2, ToBlock: 2})
+	require.NoError(t, err)
+	txs, err = receiveAll(t, stream)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, "0x2", txs[0].GetBlockNumber())
+
+	stream, err = f.client.GetTransactions(ctx, &ethparserv1.GetTransactionsRequest{Address: string(unwatched)})
+	require.NoError(t, err)
+	txs, err = receiveAll(t, stream)
+	require.NoError(t, err)
+	require.Empty(t, txs)
+
+	stream, err = f.client.GetTransactions(ctx, &ethparserv1.GetTransactionsRequest{Address: string(watched), FromBlock: 3, ToBlock: 1})
+	require.NoError(t, err)
+	_, err = receiveAll(t, stream)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPC_WatchTransactions(t *testing.T) {
+	ctx := t.Context()
+	f := newGRPCFixture(t, chainClient{head: 7, txs: []models.Transaction{grpcTx(7, watched), grpcTx(7, unwatched)}})
+
+	stream, err := f.client.WatchTransactions(ctx, &ethparserv1.WatchTransactionsRequest{Addresses: []string{string(watched)}})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	stream, err = f.client.WatchTransactions(ctx, &ethparserv1.WatchTransactionsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = f.client.Subscribe(ctx, &ethparserv1.SubscribeRequest{Address: string(watched)})
+	require.NoError(t, err)
+	stream, err = f.client.WatchTransactions(ctx, &ethparserv1.WatchTransactionsRequest{Addresses: []string{string(watched)}})
+	require.NoError(t, err)
+	// the watch is registered once headers arrive
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// Parse stops with the test context
+	go f.parser.Parse()
+
+	match, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, string(watched), match.GetAddress())
+	require.Equal(t, grpcTx(7, watched).Hash, match.GetTransaction().GetHash())
+	require.Equal(t, "0x7", match.GetTransaction().GetBlockNumber())
+}
+
+func TestGRPC_Auth(t *testing.T) {
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("reader", "acme", readKey, []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+	_, err = keyring.Add("admin", "acme", adminKey, []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err)
+	f := newGRPCFixture(t, chainClient{}, WithAuth(keyring))
+	ctx := t.Context()
+	req := &ethparserv1.SubscribeRequest{Address: string(watched)}
+
+	_, err = f.client.Subscribe(ctx, req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = f.client.Subscribe(metadata.AppendToOutgoingContext(ctx, "x-api-key", readKey), req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	var header metadata.MD
+	_, err = f.client.Subscribe(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminKey), req, grpc.Header(&header))
+	require.NoError(t, err)
+	require.NotEmpty(t, header.Get(requestIDHeader))
+
+	// the subscription belongs to the key's tenant
+	require.True(t, f.store.IsSubscriber(ctx, "acme", watched))
+	require.False(t, f.store.IsSubscriber(ctx, models.DefaultTenant, watched))
+
+	// health checks need no key
+	health, err := healthpb.NewHealthClient(f.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+}
+
+func TestGRPC_RateLimit(t *testing.T) {
+	f := newGRPCFixture(t, chainClient{}, WithRateLimit(ratelimit.New(0.001, 1)))
+	ctx := t.Context()
+
+	_, err := f.client.GetCurrentBlock(ctx, &ethparserv1.GetCurrentBlockRequest{})
+	require.NoError(t, err)
+
+	var trailer metadata.MD
+	_, err = f.client.GetCurrentBlock(ctx, &ethparserv1.GetCurrentBlockRequest{}, grpc.Trailer(&trailer))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NotEmpty(t, trailer.Get("retry-after"))
+}
@@ -4,45 +4,141 @@ import (
 	"context"
 	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/galecic/ethereum_parser/internal/config"
 	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/metrics"
 	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/ratelimit"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 )
 
+// grpcKeepalive - pings idle connections so watch streams of vanished clients are closed
+var grpcKeepalive = keepalive.ServerParameters{Time: time.Minute, Timeout: 20 * time.Second}
+
 func main() {
-	serverAddr := flag.String("serverAddr", "localhost:8000", "server address")
-	publicNode := flag.String("eth_publich_node", "https://ethereum-rpc.publicnode.com", "public node address")
-	fetchTxsPeriod := flag.Duration("period", 5*time.Second, "fetch transactions period")
-	workers := flag.Int("threads", 10, "number of coroutines for parsing transactions")
-	flag.Parse()
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger.Info("effective config", slog.Any("config", cfg))
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	client := client.NewClient(*publicNode)
-	db := data_store.NewDataStore()
+	tp, shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		ServiceName:  "ethereum_parser",
+	}, os.Stdout)
+	if err != nil {
+		logger.Error("tracing setup", logging.Err(err))
+		os.Exit(2)
+	}
+
+	registry := metrics.NewRegistry()
+	collector := metrics.NewCollector(registry)
 
-	cfg := parser.ParserConfig{
-		TxFetchInterval: *fetchTxsPeriod,
-		Workers:         *workers,
+	newClient := func(endpoint string) client.Client {
+		return client.NewClient(endpoint,
+			client.WithMetrics(collector),
+			client.WithLogger(logger.With(slog.String("component", "client"))),
+			client.WithTracerProvider(tp),
+		)
+	}
+	storeOpts := []data_store.Option{
+		data_store.WithMetrics(collector),
+		data_store.WithLogger(logger.With(slog.String("component", "store"))),
+	}
+	db := data_store.NewDataStore(storeOpts...)
+	if cfg.Store.Shards > 0 {
+		db = data_store.NewShardedDataStore(cfg.Store.Shards, storeOpts...)
+	}
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		db = data_store.NewTracedDataStore(db, tp)
 	}
 
-	parser := parser.NewParserRuntime(ctx, client, db, cfg)
+	parser := parser.NewParserRuntime(ctx, newClient(cfg.Node.Endpoint), db, parserConfig(cfg),
+		parser.WithMetrics(collector),
+		parser.WithLogger(logger.With(slog.String("component", "parser"))),
+		parser.WithTracerProvider(tp),
+	)
 
-	router := NewRouter(parser)
+	auditLogger := logger.With(slog.String("component", "audit"))
+	if cfg.Auth.AuditLog != "" {
+		auditFile, err := os.OpenFile(cfg.Auth.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			logger.Error("audit log", logging.Err(err))
+			os.Exit(2)
+		}
+		defer auditFile.Close()
+		auditLogger = slog.New(slog.NewJSONHandler(auditFile, nil))
+	}
+	routerOpts := []RouterOption{
+		WithMetrics(collector, registry),
+		WithMaxLag(cfg.Server.ReadyMaxLag),
+		WithMaxStaleTicks(cfg.Server.ReadyMaxStaleTicks),
+		WithLogger(logger.With(slog.String("component", "router"))),
+		WithTracerProvider(tp),
+		WithAuditLog(auditLogger),
+		WithMaxBodyBytes(cfg.Server.MaxBodyBytes),
+		WithWebSocketOrigins(cfg.Server.WSOrigins...),
+	}
+	if cfg.Server.RateLimit > 0 {
+		routerOpts = append(routerOpts, WithRateLimit(ratelimit.New(cfg.Server.RateLimit, cfg.Server.RateBurst)))
+	}
+	if cfg.Auth.Enabled {
+		// validated by config.Load
+		keyring, _ := cfg.Auth.Keyring()
+		routerOpts = append(routerOpts, WithAuth(keyring), WithParserControl(parser))
+	}
+	router := NewRouter(parser, routerOpts...)
 
 	httpServer := &http.Server{
-		Addr:    *serverAddr,
-		Handler: router,
+		Addr:         cfg.Server.Addr,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	httpServer.RegisterOnShutdown(router.CloseWebSockets)
+
+	var grpcServer *grpc.Server
+	if cfg.Server.GRPCAddr != "" {
+		grpcServer = NewGRPCServer(router, grpc.KeepaliveParams(grpcKeepalive))
 	}
 
+	reloader := newReloader(cfg, os.Args, parser, newClient, logger.With(slog.String("component", "reload")))
+	reloader.syncWatchlist(ctx)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	wg := sync.WaitGroup{}
 
 	wg.Add(1)
@@ -51,22 +147,50 @@ func main() {
 		parser.Parse()
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reloader.run(ctx, hup)
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Println("ListenAndServe error", err)
+			logger.Error("ListenAndServe error", logging.Err(err))
 			cancel()
 		}
 	}()
-	log.Println("Server started", *serverAddr)
+	logger.Info("server started", slog.String("addr", cfg.Server.Addr))
+
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lis, err := net.Listen("tcp", cfg.Server.GRPCAddr)
+			if err == nil {
+				err = grpcServer.Serve(lis)
+			}
+			if err != nil {
+				logger.Error("gRPC serve error", logging.Err(err))
+				cancel()
+			}
+		}()
+		logger.Info("gRPC server started", slog.String("addr", cfg.Server.GRPCAddr))
+	}
 
 	<-ctx.Done()
+	if grpcServer != nil {
+		stopGRPC(grpcServer, grpcShutdownTimeout)
+	}
 	ctx = context.Background()
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Println("Server shutdown")
+		logger.Error("server shutdown", logging.Err(err))
 	}
 
 	wg.Wait()
-	log.Println("Server stopped")
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("tracing shutdown", logging.Err(err))
+	}
+	logger.Info("server stopped")
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// apiSpec - the embedded spec, every route of NewRouter is described in it
+var apiSpec = mustLoadSpec(openAPISpec)
+
+var errInvalidRequest = errors.New("invalid request")
+
+func mustLoadSpec(data []byte) *openapi3.T {
+	spec, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		panic(fmt.Sprintf("load openapi spec: %v", err))
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		panic(fmt.Sprintf("invalid openapi spec: %v", err))
+	}
+	return spec
+}
+
+// specRoute - operation of a mux pattern such as "GET /subscriptions/{id}", the spec uses the same path templates
+func specRoute(spec *openapi3.T, pattern string) *routers.Route {
+	method, path, _ := strings.Cut(pattern, " ")
+	pathItem := spec.Paths.Value(path)
+	if pathItem == nil || pathItem.GetOperation(method) == nil {
+		panic(fmt.Sprintf("route %q is missing from the openapi spec", pattern))
+	}
+	// keys are checked by the keyring before validation, a copy without security requirements
+	// keeps the validator from reading the body once per security scheme
+	operation := *pathItem.GetOperation(method)
+	operation.Security = &openapi3.SecurityRequirements{}
+
+	return &routers.Route{
+		Spec:      spec,
+		Path:      path,
+		PathItem:  pathItem,
+		Method:    method,
+		Operation: &operation,
+	}
+}
+
+var (
+	validationOptions = newValidationOptions(false)
+	// csvValidationOptions - CSV uploads are described as a plain string. The validator's CSV decoder rejects
+	// the comment lines and rows of uneven width the bulk subscribe handler skips, and decoders can only be
+	// replaced for the whole process, so a CSV body is left to the handler.
+	csvValidationOptions = newValidationOptions(true)
+)
+
+func newValidationOptions(excludeBody bool) *openapi3filter.Options {
+	opts := &openapi3filter.Options{
+		SkipSettingDefaults: true,
+		ExcludeRequestBody:  excludeBody,
+	}
+	// the schema of the failing value would be dumped into the error otherwise
+	opts.WithCustomSchemaErrorFunc(func(err *openapi3.SchemaError) string {
+		if pointer := err.JSONPointer(); len(pointer) > 0 {
+			return fmt.Sprintf("/%s: %s", strings.Join(pointer, "/"), err.Reason)
+		}
+		return err.Reason
+	})
+	return opts
+}
+
+// validate - checks path and query parameters and the body of r against route, the body is buffered for the handler.
+// A body without a Content-Type is taken as JSON.
+func validate(r *http.Request, route *routers.Route) error {
+	params := make(map[string]string)
+	for _, param := range route.Operation.Parameters {
+		if param.Value != nil && param.Value.In == openapi3.ParameterInPath {
+			params[param.Value.Name] = r.PathValue(param.Value.Name)
+		}
+	}
+	if r.Header.Get("Content-Type") == "" && r.ContentLength != 0 {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	options := validationOptions
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" && acceptsBody(route, mediaType) {
+		options = csvValidationOptions
+	}
+	err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: params,
+		Route:      route,
+		Options:    options,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidRequest, err)
+	}
+	return nil
+}
+
+// acceptsBody - the operation of route takes a body of mediaType
+func acceptsBody(route *routers.Route, mediaType string) bool {
+	body := route.Operation.RequestBody
+	return body != nil && body.Value != nil && body.Value.Content.Get(mediaType) != nil
+}
+
+// OpenAPI - the spec as JSON
+func (h *Router) OpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, apiSpec)
+}
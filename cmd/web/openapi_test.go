@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/metrics"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/ratelimit"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.PlainBodyDecoder)
+}
+
+type stubClient struct{}
+
+func (stubClient) GetBlockNumber(context.Context) (int, error) { return 0, nil }
+
+func (stubClient) GetTxsFromBlock(context.Context, int) ([]models.Transaction, error) {
+	return nil, nil
+}
+
+func (stubClient) Endpoint() string { return "http://node.test" }
+
+const (
+	watched   = models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
+	unwatched = models.Address("0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5")
+	adminKey  = "admin-secret-0123456789"
+	readKey   = "read-secret-0123456789"
+	scrapeKey = "scrape-secret-0123456789"
+)
+
+type contractCase struct {
+	name        string
+	route       string
+	method      string
+	target      string
+	contentType string
+	accept      string
+	body        string
+	key         string
+	status      int
+}
+
+// checkContract - serves c and checks both the status and that the response matches the spec of c.route
+func checkContract(t *testing.T, h http.Handler, c contractCase) {
+	t.Helper()
+	req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+	if c.contentType != "" {
+		req.Header.Set("Content-Type", c.contentType)
+	}
+	if c.accept != "" {
+		req.Header.Set("Accept", c.accept)
+	}
+	if c.key != "" {
+		req.Header.Set(auth.Header, c.key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, c.status, rec.Code, rec.Body.String())
+
+	err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request: req,
+			Route:   specRoute(apiSpec, c.route),
+		},
+		Status:  rec.Code,
+		Header:  rec.Header(),
+		Body:    io.NopCloser(rec.Body),
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+	require.NoError(t, err)
+}
+
+func newContractRouter(t *testing.T, opts ...RouterOption) (*Router, *parser.ParserRuntime, data_store.DataStore) {
+	t.Helper()
+	ctx := context.Background()
+	store := data_store.NewDataStore()
+	p := parser.NewParserRuntime(ctx, stubClient{}, store, parser.ParserConfig{})
+
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("admin", "", adminKey, []auth.Scope{auth.ScopeAdmin})
+	require.NoError(t, err)
+	_, err = keyring.Add("reader", "", readKey, []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+	_, err = keyring.Add("prometheus", "", scrapeKey, []auth.Scope{auth.ScopeMetrics})
+	require.NoError(t, err)
+	registry := metrics.NewRegistry()
+
+	opts = append([]RouterOption{
+		WithAuth(keyring),
+		WithParserControl(p),
+		WithMetrics(metrics.NewCollector(registry), registry),
+		WithMaxBodyBytes(1024),
+	}, opts...)
+
+	return NewRouter(p, opts...), p, store
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	router, _, _ := newContractRouter(t)
+
+	var documented []string
+	for path, item := range apiSpec.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+	require.ElementsMatch(t, documented, router.routes)
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	ctx := context.Background()
+	router, p, store := newContractRouter(t)
+
+	p.Subscribe(ctx, models.DefaultTenant, watched)
+	store.AddTx(ctx, watched, models.Transaction{
+		BlockNumber:      "0x1",
+		From:             watched,
+		To:               unwatched,
+		Hash:             "0xabc",
+		TransactionIndex: "0x0",
+		Value:            "0xde0b6b3a7640000",
+	})
+	sub, err := p.CreateSubscription(ctx, models.DefaultTenant, models.Rule{Type: models.RuleType("contract_creation")})
+	require.NoError(t, err)
+	store.SetCurrentBlock(ctx, 5)
+
+	cases := []contractCase{
+		{name: "healthz", route: "GET /healthz", method: http.MethodGet, target: "/healthz", status: http.StatusOK},
+		{name: "readyz before the first block", route: "GET /readyz", method: http.MethodGet, target: "/readyz", status: http.StatusServiceUnavailable},
+		{name: "spec", route: "GET /openapi.json", method: http.MethodGet, target: "/openapi.json", status: http.StatusOK},
+		{name: "status", route: "GET /status", method: http.MethodGet, target: "/status", key: readKey, status: http.StatusOK},
+		{name: "status without a key", route: "GET /status", method: http.MethodGet, target: "/status", status: http.StatusUnauthorized},
+		{name: "current block", route: "GET /current-block", method: http.MethodGet, target: "/current-block", key: readKey, status: http.StatusOK},
+		{
+			name: "subscribe", route: "POST /subscribe", method: http.MethodPost, target: "/subscribe", key: adminKey,
+			contentType: "application/json", body: `{"address":"` + string(unwatched) + `"}`, status: http.StatusOK,
+		},
+		{
+			name: "subscribe without a content type", route: "POST /subscribe", method: http.MethodPost, target: "/subscribe", key: adminKey,
+			body: `{"address":"` + string(unwatched) + `"}`, status: http.StatusOK,
+		},
+		{
+			name: "subscribe invalid address", route: "POST /subscribe", method: http.MethodPost, target: "/subscribe", key: adminKey,
+			contentType: "application/json", body: `{"address":"0x12"}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "subscribe without address", route: "POST /subscribe", method: http.MethodPost, target: "/subscribe", key: adminKey,
+			contentType: "application/json", body: `{}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "subscribe with a read key", route: "POST /subscribe", method: http.MethodPost, target: "/subscribe", key: readKey,
+			contentType: "application/json", body: `{"address":"` + string(unwatched) + `"}`, status: http.StatusForbidden,
+		},
+		{
+			name: "subscribe body too large", route: "POST /subscribe", method: http.MethodPost, target: "/subscribe", key: adminKey,
+			contentType: "application/json", body: `{"address":"` + strings.Repeat("0", 2048) + `"}`, status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "unsubscribe", route: "POST /unsubscribe", method: http.MethodPost, target: "/unsubscribe", key: adminKey,
+			contentType: "application/json", body: `{"address":"` + string(unwatched) + `"}`, status: http.StatusOK,
+		},
+		{
+			name: "transactions", route: "GET /transactions/{address}", method: http.MethodGet,
+			target: "/transactions/" + string(watched), key: readKey, status: http.StatusOK,
+		},
+		{
+			name: "transactions of an unwatched address", route: "GET /transactions/{address}", method: http.MethodGet,
+			target: "/transactions/" + string(unwatched), key: readKey, status: http.StatusOK,
+		},
+		{
+			name: "transactions as csv", route: "GET /transactions/{address}", method: http.MethodGet,
+			target: "/transactions/" + string(watched) + "?format=csv&columns=block,hash,value", key: readKey, status: http.StatusOK,
+		},
+		{
+			name: "transactions as ndjson", route: "GET /transactions/{address}", method: http.MethodGet,
+			target: "/transactions/" + string(watched), accept: "application/x-ndjson", key: readKey, status: http.StatusOK,
+		},
+		{
+			name: "transactions invalid address", route: "GET /transactions/{address}", method: http.MethodGet,
+			target: "/transactions/0x1", key: readKey, status: http.StatusBadRequest,
+		},
+		{
+			name: "transactions invalid column", route: "GET /transactions/{address}", method: http.MethodGet,
+			target: "/transactions/" + string(watched) + "?format=csv&columns=nonce", key: readKey, status: http.StatusBadRequest,
+		},
+		{name: "export", route: "GET /export", method: http.MethodGet, target: "/export", key: readKey, status: http.StatusOK},
+		{
+			name: "export csv range", route: "GET /export", method: http.MethodGet,
+			target: "/export?format=csv&from_block=0x1&to_block=2&address=" + string(watched), key: readKey, status: http.StatusOK,
+		},
+		{
+			name: "export reversed range", route: "GET /export", method: http.MethodGet,
+			target: "/export?from_block=5&to_block=1", key: readKey, status: http.StatusBadRequest,
+		},
+		{name: "export json", route: "GET /export", method: http.MethodGet, target: "/export?format=json", key: readKey, status: http.StatusBadRequest},
+		{
+			name: "create subscription", route: "POST /subscriptions", method: http.MethodPost, target: "/subscriptions", key: adminKey,
+			contentType: "application/json",
+			body:        `{"rule":{"type":"or","rules":[{"type":"address","addresses":["` + string(watched) + `"]},{"type":"value_above","value":"1000"}]}}`,
+			status:      http.StatusCreated,
+		},
+		{
+			name: "create subscription unknown rule type", route: "POST /subscriptions", method: http.MethodPost, target: "/subscriptions", key: adminKey,
+			contentType: "application/json", body: `{"rule":{"type":"nonce"}}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "create subscription invalid rule", route: "POST /subscriptions", method: http.MethodPost, target: "/subscriptions", key: adminKey,
+			contentType: "application/json", body: `{"rule":{"type":"value_above","value":"lots"}}`, status: http.StatusBadRequest,
+		},
+		{name: "subscriptions", route: "GET /subscriptions", method: http.MethodGet, target: "/subscriptions", key: readKey, status: http.StatusOK},
+		{name: "subscribers", route: "GET /subscribers", method: http.MethodGet, target: "/subscribers", key: readKey, status: http.StatusOK},
+		{
+			name: "bulk subscribe json", route: "POST /subscriptions/bulk", method: http.MethodPost, target: "/subscriptions/bulk", key: adminKey,
+			contentType: "application/json", body: `["` + string(watched) + `","` + string(unwatched) + `","0x12"]`, status: http.StatusOK,
+		},
+		{
+			name: "bulk subscribe csv", route: "POST /subscriptions/bulk", method: http.MethodPost, target: "/subscriptions/bulk", key: adminKey,
+			contentType: "text/csv", body: "address,label\n" + string(watched) + ",hot wallet\n", status: http.StatusOK,
+		},
+		{
+			name: "bulk subscribe plain text", route: "POST /subscriptions/bulk", method: http.MethodPost, target: "/subscriptions/bulk", key: adminKey,
+			contentType: "text/plain", body: string(unwatched) + "\n", status: http.StatusOK,
+		},
+		{
+			name: "bulk subscribe json object", route: "POST /subscriptions/bulk", method: http.MethodPost, target: "/subscriptions/bulk", key: adminKey,
+			contentType: "application/json", body: `{"addresses":[]}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "subscription transactions", route: "GET /subscriptions/{id}/transactions", method: http.MethodGet,
+			target: "/subscriptions/" + sub.ID + "/transactions", key: readKey, status: http.StatusOK,
+		},
+		{
+			name: "subscription transactions not found", route: "GET /subscriptions/{id}/transactions", method: http.MethodGet,
+			target: "/subscriptions/missing/transactions", key: readKey, status: http.StatusNotFound,
+		},
+		{
+			name: "graphql", route: "POST /graphql", method: http.MethodPost, target: "/graphql", key: readKey,
+			contentType: "application/json",
+			body:        `{"query":"{ currentBlock address(address: \"` + string(watched) + `\") { transactions { totalCount nodes { hash } } } }"}`,
+			status:      http.StatusOK,
+		},
+		{
+			name: "graphql query error", route: "POST /graphql", method: http.MethodPost, target: "/graphql", key: readKey,
+			contentType: "application/json", body: `{"query":"{ nonce }"}`, status: http.StatusOK,
+		},
+		{
+			name: "graphql without a query", route: "POST /graphql", method: http.MethodPost, target: "/graphql", key: readKey,
+			contentType: "application/json", body: `{"variables":{}}`, status: http.StatusBadRequest,
+		},
+		{name: "websocket without an upgrade", route: "GET /ws", method: http.MethodGet, target: "/ws", key: readKey, status: http.StatusBadRequest},
+		{name: "websocket without a key", route: "GET /ws", method: http.MethodGet, target: "/ws", status: http.StatusUnauthorized},
+		{
+			name: "delete subscription", route: "DELETE /subscriptions/{id}", method: http.MethodDelete,
+			target: "/subscriptions/" + sub.ID, key: adminKey, status: http.StatusNoContent,
+		},
+		{
+			name: "delete subscription not found", route: "DELETE /subscriptions/{id}", method: http.MethodDelete,
+			target: "/subscriptions/" + sub.ID, key: adminKey, status: http.StatusNotFound,
+		},
+		{name: "metrics", route: "GET /metrics", method: http.MethodGet, target: "/metrics", key: adminKey, status: http.StatusOK},
+		{name: "metrics with a read key", route: "GET /metrics", method: http.MethodGet, target: "/metrics", key: readKey, status: http.StatusForbidden},
+		{name: "metrics with a metrics key", route: "GET /metrics", method: http.MethodGet, target: "/metrics", key: scrapeKey, status: http.StatusOK},
+		{name: "status with a metrics key", route: "GET /status", method: http.MethodGet, target: "/status", key: scrapeKey, status: http.StatusForbidden},
+		{
+			name: "create key", route: "POST /admin/keys", method: http.MethodPost, target: "/admin/keys", key: adminKey,
+			contentType: "application/json", body: `{"id":"dashboard","tenant":"acme","scopes":["transactions:read"]}`, status: http.StatusCreated,
+		},
+		{
+			name: "create existing key", route: "POST /admin/keys", method: http.MethodPost, target: "/admin/keys", key: adminKey,
+			contentType: "application/json", body: `{"id":"dashboard","scopes":["transactions:read"]}`, status: http.StatusConflict,
+		},
+		{
+			name: "create key unknown scope", route: "POST /admin/keys", method: http.MethodPost, target: "/admin/keys", key: adminKey,
+			contentType: "application/json", body: `{"scopes":["root"]}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "create key as form", route: "POST /admin/keys", method: http.MethodPost, target: "/admin/keys", key: adminKey,
+			contentType: "application/x-www-form-urlencoded", body: `{"scopes":["admin"]}`, status: http.StatusBadRequest,
+		},
+		{name: "keys", route: "GET /admin/keys", method: http.MethodGet, target: "/admin/keys", key: adminKey, status: http.StatusOK},
+		{name: "delete key", route: "DELETE /admin/keys/{id}", method: http.MethodDelete, target: "/admin/keys/dashboard", key: adminKey, status: http.StatusNoContent},
+		{name: "delete key not found", route: "DELETE /admin/keys/{id}", method: http.MethodDelete, target: "/admin/keys/dashboard", key: adminKey, status: http.StatusNotFound},
+		{name: "pause", route: "POST /admin/parser/pause", method: http.MethodPost, target: "/admin/parser/pause", key: adminKey, status: http.StatusOK},
+		{name: "pause with a read key", route: "POST /admin/parser/pause", method: http.MethodPost, target: "/admin/parser/pause", key: readKey, status: http.StatusForbidden},
+		{name: "resume", route: "POST /admin/parser/resume", method: http.MethodPost, target: "/admin/parser/resume", key: adminKey, status: http.StatusOK},
+		{
+			name: "rewind", route: "POST /admin/parser/rewind", method: http.MethodPost, target: "/admin/parser/rewind", key: adminKey,
+			contentType: "application/json", body: `{"block":3,"purge":true}`, status: http.StatusOK,
+		},
+		{
+			name: "rewind after the current block", route: "POST /admin/parser/rewind", method: http.MethodPost, target: "/admin/parser/rewind", key: adminKey,
+			contentType: "application/json", body: `{"block":4}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "rewind without a block", route: "POST /admin/parser/rewind", method: http.MethodPost, target: "/admin/parser/rewind", key: adminKey,
+			contentType: "application/json", body: `{"purge":true}`, status: http.StatusBadRequest,
+		},
+		{
+			name: "rescan", route: "POST /admin/parser/rescan", method: http.MethodPost, target: "/admin/parser/rescan", key: adminKey,
+			contentType: "application/json", body: `{"fromBlock":1,"toBlock":3}`, status: http.StatusOK,
+		},
+		{
+			name: "rescan unparsed blocks", route: "POST /admin/parser/rescan", method: http.MethodPost, target: "/admin/parser/rescan", key: adminKey,
+			contentType: "application/json", body: `{"fromBlock":1,"toBlock":4}`, status: http.StatusBadRequest,
+		},
+	}
+
+	covered := make(map[string]bool)
+	for _, c := range cases {
+		covered[c.route] = true
+		t.Run(c.name, func(t *testing.T) {
+			checkContract(t, router, c)
+		})
+	}
+	for _, route := range router.routes {
+		require.True(t, covered[route], "no contract case for %s", route)
+	}
+}
+
+func TestOpenAPIContract_RateLimited(t *testing.T) {
+	router, _, _ := newContractRouter(t, WithRateLimit(ratelimit.New(0.001, 1)))
+
+	c := contractCase{route: "GET /status", method: http.MethodGet, target: "/status", key: readKey, status: http.StatusOK}
+	checkContract(t, router, c)
+	c.status = http.StatusTooManyRequests
+	checkContract(t, router, c)
+}
+
+func TestSpecRoute(t *testing.T) {
+	route := specRoute(apiSpec, "DELETE /subscriptions/{id}")
+	require.Equal(t, "deleteSubscription", route.Operation.OperationID)
+	require.Empty(t, *route.Operation.Security)
+	// the shared spec keeps its security requirements
+	require.NotEmpty(t, apiSpec.Security)
+
+	require.Panics(t, func() { specRoute(apiSpec, "PUT /subscriptions/{id}") })
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/galecic/ethereum_parser/internal/config"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/watchlist"
+)
+
+const filePollInterval = time.Second
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// reloader - re-reads the config on SIGHUP or when the config or watchlist file changes,
+// applies the live settings and reports the changes that need a restart
+type reloader struct {
+	args []string
+	// cfg - running config, settings that need a restart keep their startup values
+	cfg config.Config
+	// loaded - config read by the last reload, a restart is reported for the settings that changed since
+	loaded    config.Config
+	parser    *parser.ParserRuntime
+	newClient func(endpoint string) client.Client
+	logger    *slog.Logger
+
+	// watchlist - addresses listed in the watchlist file at the last sync
+	watchlist []models.Address
+	// owned - listed addresses the watchlist subscribed, removed lines unsubscribe only these,
+	// an address subscribed through the API before it was listed stays subscribed
+	owned  map[models.Address]bool
+	stamps map[string]fileStamp
+}
+
+func newReloader(cfg config.Config, args []string, p *parser.ParserRuntime, newClient func(string) client.Client, logger *slog.Logger) *reloader {
+	r := &reloader{
+		args:      args,
+		cfg:       cfg,
+		loaded:    cfg,
+		parser:    p,
+		newClient: newClient,
+		logger:    logger,
+		owned:     make(map[models.Address]bool),
+		stamps:    make(map[string]fileStamp),
+	}
+	r.filesChanged()
+
+	return r
+}
+
+func (r *reloader) run(ctx context.Context, hup <-chan os.Signal) {
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("reloading config", slog.String("trigger", "SIGHUP"))
+			r.filesChanged()
+			r.reload(ctx)
+		case <-ticker.C:
+			if r.filesChanged() {
+				r.logger.Info("reloading config", slog.String("trigger", "file change"))
+				r.reload(ctx)
+			}
+		}
+	}
+}
+
+// filesChanged - reports whether the config or watchlist file changed since the last call
+func (r *reloader) filesChanged() bool {
+	changed := false
+	for _, path := range []string{r.cfg.File, r.cfg.Subscriptions.Watchlist} {
+		if path == "" {
+			continue
+		}
+		var stamp fileStamp
+		if info, err := os.Stat(path); err == nil {
+			stamp = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+		if prev, ok := r.stamps[path]; ok && prev != stamp {
+			changed = true
+		}
+		r.stamps[path] = stamp
+	}
+
+	return changed
+}
+
+func (r *reloader) reload(ctx context.Context) {
+	next, err := config.Load(r.args[0], r.args[1:], os.LookupEnv)
+	if err != nil {
+		r.logger.Error("reload failed, keeping the current config", logging.Err(err))
+		return
+	}
+
+	// pending restarts were reported by the reload that read them
+	changed := config.Diff(r.loaded, next)
+	r.loaded = next
+	var restart []string
+	for _, key := range config.Diff(r.cfg, next) {
+		if !config.Live(key) && slices.Contains(changed, key) {
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		r.logger.Warn("config changes need a restart to apply", slog.Any("settings", restart))
+	}
+
+	if next.Node != r.cfg.Node || next.Parser.Interval != r.cfg.Parser.Interval || next.Parser.Workers != r.cfg.Parser.Workers {
+		var c client.Client
+		if next.Node.Endpoint != r.cfg.Node.Endpoint {
+			c = r.newClient(next.Node.Endpoint)
+		}
+		r.cfg.Node = next.Node
+		r.cfg.Parser.Interval = next.Parser.Interval
+		r.cfg.Parser.Workers = next.Parser.Workers
+		r.parser.Reconfigure(c, parserConfig(r.cfg))
+	}
+	r.cfg.File = next.File
+	r.cfg.Subscriptions = next.Subscriptions
+	r.syncWatchlist(ctx)
+}
+
+// syncWatchlist - subscribes the addresses added to the watchlist file and unsubscribes the removed ones
+func (r *reloader) syncWatchlist(ctx context.Context) {
+	var addrs []models.Address
+	if path := r.cfg.Subscriptions.Watchlist; path != "" {
+		var err error
+		addrs, err = watchlist.Read(path)
+		if err != nil {
+			r.logger.Error("watchlist not applied", logging.Err(err))
+			return
+		}
+	}
+
+	added, removed := watchlist.Diff(r.watchlist, addrs)
+	subscribed, unsubscribed := 0, 0
+	for _, addr := range added {
+		if r.parser.Subscribe(ctx, models.DefaultTenant, addr) {
+			r.owned[addr] = true
+			subscribed++
+		}
+	}
+	for _, addr := range removed {
+		if !r.owned[addr] {
+			continue
+		}
+		delete(r.owned, addr)
+		r.parser.Unsubscribe(ctx, models.DefaultTenant, addr)
+		unsubscribed++
+	}
+	r.watchlist = addrs
+	if subscribed > 0 || unsubscribed > 0 {
+		r.logger.Info("applied watchlist", slog.Int("subscribed", subscribed), slog.Int("unsubscribed", unsubscribed))
+	}
+}
+
+func parserConfig(cfg config.Config) parser.ParserConfig {
+	return parser.ParserConfig{
+		TxFetchInterval:     cfg.Parser.Interval,
+		Workers:             cfg.Parser.Workers,
+		ExpectedSubscribers: cfg.Parser.ExpectedSubscribers,
+	}
+}
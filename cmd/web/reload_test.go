@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/config"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncWatchlist_KeepsAPISubscriptions(t *testing.T) {
+	ctx := t.Context()
+	listed := models.Address("0x1f9840a85d5af5bf1d1762f925bdaddc4201f984")
+	path := filepath.Join(t.TempDir(), "watchlist.txt")
+	write := func(lines string) {
+		require.NoError(t, os.WriteFile(path, []byte(lines), 0o600))
+	}
+	store := data_store.NewDataStore()
+	p := parser.NewParserRuntime(ctx, stubClient{}, store, parser.ParserConfig{})
+	cfg := config.Default()
+	cfg.Subscriptions.Watchlist = path
+	r := newReloader(cfg, []string{"web"}, p, nil, logging.Discard())
+
+	// watched was subscribed through the API before it was listed
+	require.True(t, p.Subscribe(ctx, models.DefaultTenant, watched))
+	write(string(watched) + "\n" + string(listed) + "\n")
+	r.syncWatchlist(ctx)
+	require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, watched))
+	require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, listed))
+
+	// removing both lines drops only the subscription the watchlist made
+	write("# empty\n")
+	r.syncWatchlist(ctx)
+	require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, watched))
+	require.False(t, store.IsSubscriber(ctx, models.DefaultTenant, listed))
+
+	// listed again, the watchlist owns it once more
+	write(string(listed) + "\n")
+	r.syncWatchlist(ctx)
+	write("")
+	r.syncWatchlist(ctx)
+	require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, watched))
+	require.False(t, store.IsSubscriber(ctx, models.DefaultTenant, listed))
+}
+
+func TestReload_ReportsRestartOnce(t *testing.T) {
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("server:\n  addr: localhost:8000\n")
+	cfg, err := config.Load("web", []string{"-config", path}, os.LookupEnv)
+	require.NoError(t, err)
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, logging.FormatText, "info")
+	require.NoError(t, err)
+	p := parser.NewParserRuntime(ctx, stubClient{}, data_store.NewDataStore(), parserConfig(cfg))
+	r := newReloader(cfg, []string{"web", "-config", path}, p, nil, logger)
+	warnings := func(reload func(context.Context)) int {
+		logs.Reset()
+		reload(ctx)
+		return strings.Count(logs.String(), "need a restart")
+	}
+
+	write("server:\n  addr: localhost:8001\n")
+	require.Equal(t, 1, warnings(r.reload))
+	// the address is still pending, it was reported already
+	require.Equal(t, 0, warnings(r.reload))
+	write("server:\n  addr: localhost:8001\nparser:\n  workers: 4\n")
+	require.Equal(t, 0, warnings(r.reload))
+
+	// back to the running value nothing is pending, changed again it is reported again
+	write("server:\n  addr: localhost:8000\n")
+	require.Equal(t, 0, warnings(r.reload))
+	write("server:\n  addr: localhost:8002\n")
+	require.Equal(t, 1, warnings(r.reload))
+}
@@ -1,43 +1,460 @@
 package main
 
 import (
+	"bufio"
+	"crypto/rand"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"iter"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/export"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/matcher"
 	"github.com/galecic/ethereum_parser/internal/models"
 	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/galecic/ethereum_parser/internal/ratelimit"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+	"github.com/galecic/ethereum_parser/internal/watchlist"
+	"github.com/graph-gophers/graphql-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Router struct {
-	parser parser.Parser
+	parser      parser.Parser
+	metrics     Metrics
+	metricsPage http.Handler
+	maxLag      int
+	maxStale    int
+	logger      *slog.Logger
+	tracer      trace.Tracer
+	propagator  propagation.TextMapPropagator
+	keyring     *auth.Keyring
+	control     parser.Controller
+	audit       *slog.Logger
+	limiter     *ratelimit.Limiter
+	maxBody     int64
+	graphql     *graphql.Schema
+	wsPing      time.Duration
+	wsOrigins   map[string]bool
+	wsClosing   chan struct{}
+	wsCloseOnce sync.Once
+	// routes - registered mux patterns, each is described in the openapi spec
+	routes []string
 	http.Handler
 }
 
+const (
+	defaultMaxLag       = 10
+	defaultMaxStale     = 3
+	defaultMaxBodyBytes = 1 << 20
+	tracerName          = "github.com/galecic/ethereum_parser/cmd/web"
+)
+
+// Metrics - instrumentation of HTTP handlers
+type Metrics interface {
+	ObserveRequest(route string, code int, duration time.Duration)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveRequest(string, int, time.Duration) {}
+
+type RouterOption func(r *Router)
+
+func WithLogger(l *slog.Logger) RouterOption {
+	return func(r *Router) {
+		r.logger = l
+	}
+}
+
+// WithMaxLag - blocks the parser may be behind the node and still report ready
+func WithMaxLag(blocks int) RouterOption {
+	return func(r *Router) {
+		r.maxLag = blocks
+	}
+}
+
+// WithMaxStaleTicks - fetch intervals without a successful tick after which readiness fails, 0 disables the check
+func WithMaxStaleTicks(n int) RouterOption {
+	return func(r *Router) {
+		r.maxStale = n
+	}
+}
+
+// WithMetrics - observe every request and serve page at /metrics
+func WithMetrics(m Metrics, page http.Handler) RouterOption {
+	return func(r *Router) {
+		r.metrics = m
+		r.metricsPage = page
+	}
+}
+
+// WithTracerProvider - a server span per request, continuing the caller's trace context
+func WithTracerProvider(tp trace.TracerProvider) RouterOption {
+	return func(r *Router) {
+		r.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithAuth - require a key from keyring on every route but the health checks, serve key management under /admin/keys
+func WithAuth(keyring *auth.Keyring) RouterOption {
+	return func(r *Router) {
+		r.keyring = keyring
+	}
+}
+
+// WithAuditLog - record every mutating call with the key that made it
+func WithAuditLog(l *slog.Logger) RouterOption {
+	return func(r *Router) {
+		r.audit = l
+	}
+}
+
+// WithRateLimit - throttle each API key, or client IP without one, to the limiter's rate
+func WithRateLimit(l *ratelimit.Limiter) RouterOption {
+	return func(r *Router) {
+		r.limiter = l
+	}
+}
+
+// WithMaxBodyBytes - reject request bodies larger than n bytes, 0 lifts the limit
+func WithMaxBodyBytes(n int64) RouterOption {
+	return func(r *Router) {
+		r.maxBody = n
+	}
+}
+
 const (
 	addressParam = "address"
+	idParam      = "id"
 )
 
-func NewRouter(parser parser.Parser) *Router {
+func NewRouter(parser parser.Parser, opts ...RouterOption) *Router {
 	r := &Router{
-		parser: parser,
+		parser:     parser,
+		metrics:    noopMetrics{},
+		maxLag:     defaultMaxLag,
+		maxStale:   defaultMaxStale,
+		logger:     logging.Discard(),
+		tracer:     tracing.Noop().Tracer(tracerName),
+		propagator: tracing.Propagator(),
+		audit:      logging.Discard(),
+		maxBody:    defaultMaxBodyBytes,
+		wsPing:     defaultWSPingInterval,
+		wsClosing:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	r.graphql = newGraphQLSchema(parser, r.logger)
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /current-block", r.GetCurrentBlock)
-	mux.HandleFunc("POST /subscribe", r.Subscribe)
-	mux.HandleFunc(fmt.Sprintf("GET /transactions/{%s}", addressParam), r.GetTransactions)
+	r.open(mux, "GET /healthz", r.Healthz)
+	r.open(mux, "GET /readyz", r.Readyz)
+	r.open(mux, "GET /openapi.json", r.OpenAPI)
+	r.handle(mux, "GET /status", auth.ScopeRead, r.GetStatus)
+	r.handle(mux, "GET /current-block", auth.ScopeRead, r.GetCurrentBlock)
+	r.handle(mux, "POST /subscribe", auth.ScopeSubscriptions, r.Subscribe)
+	r.handle(mux, "POST /unsubscribe", auth.ScopeSubscriptions, r.Unsubscribe)
+	r.handle(mux, "GET /subscribers", auth.ScopeRead, r.GetSubscribers)
+	r.handle(mux, fmt.Sprintf("GET /transactions/{%s}", addressParam), auth.ScopeRead, r.GetTransactions)
+	r.handle(mux, "GET /export", auth.ScopeRead, r.Export)
+	r.handle(mux, "POST /subscriptions", auth.ScopeSubscriptions, r.CreateSubscription)
+	r.handle(mux, "POST /subscriptions/bulk", auth.ScopeSubscriptions, r.BulkSubscribe)
+	r.handle(mux, "GET /subscriptions", auth.ScopeRead, r.GetSubscriptions)
+	r.handle(mux, fmt.Sprintf("DELETE /subscriptions/{%s}", idParam), auth.ScopeSubscriptions, r.DeleteSubscription)
+	r.handle(mux, fmt.Sprintf("GET /subscriptions/{%s}/transactions", idParam), auth.ScopeRead, r.GetSubscriptionTransactions)
+	r.handle(mux, "POST /graphql", auth.ScopeRead, r.GraphQL)
+	r.handle(mux, "GET /ws", auth.ScopeRead, r.WebSocket)
 
-	r.Handler = mux
+	if r.metricsPage != nil {
+		r.handle(mux, "GET /metrics", auth.ScopeMetrics, r.metricsPage.ServeHTTP)
+	}
+	if r.keyring != nil {
+		r.handle(mux, "POST /admin/keys", auth.ScopeAdmin, r.CreateKey)
+		r.handle(mux, "GET /admin/keys", auth.ScopeAdmin, r.GetKeys)
+		r.handle(mux, fmt.Sprintf("DELETE /admin/keys/{%s}", idParam), auth.ScopeAdmin, r.DeleteKey)
+		if r.control != nil {
+			r.handle(mux, "POST /admin/parser/pause", auth.ScopeAdmin, r.PauseParser)
+			r.handle(mux, "POST /admin/parser/resume", auth.ScopeAdmin, r.ResumeParser)
+			r.handle(mux, "POST /admin/parser/rewind", auth.ScopeAdmin, r.RewindParser)
+			r.handle(mux, "POST /admin/parser/rescan", auth.ScopeAdmin, r.RescanParser)
+		}
+	}
+
+	r.Handler = r.instrument(mux)
 
 	return r
 }
 
-func (h *Router) GetCurrentBlock(w http.ResponseWriter, _ *http.Request) {
+const (
+	unmatchedRoute  = "unmatched"
+	requestIDHeader = "X-Request-ID"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Hijack - hands the connection over to a websocket, the response counts as switching protocols
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(s.ResponseWriter).Hijack()
+	if err == nil && s.code == 0 {
+		s.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// instrument - tags the request with an ID and a logger carrying it, traces it, records metrics and an access log line
+func (h *Router) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = rand.Text()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		logger := h.logger.With(slog.String(logging.KeyRequestID, requestID))
+
+		ctx := h.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := h.tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String(logging.KeyRequestID, requestID),
+			),
+		)
+		defer span.End()
+		r = r.WithContext(logging.WithContext(ctx, logger))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// the mux fills in the matched pattern while routing
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		span.SetName(route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", rec.code),
+		)
+		if rec.code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.code))
+		}
+		duration := time.Since(start)
+		h.metrics.ObserveRequest(route, rec.code, duration)
+		logger.Info("http request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", rec.code),
+			slog.Duration("duration", duration),
+		)
+	})
+}
+
+// open - registers fn without auth, rate limiting or validation
+func (h *Router) open(mux *http.ServeMux, pattern string, fn http.HandlerFunc) {
+	specRoute(apiSpec, pattern)
+	h.routes = append(h.routes, pattern)
+	mux.HandleFunc(pattern, fn)
+}
+
+// handle - registers fn behind the scope check, the rate limit and validation against the openapi spec,
+// mutating calls are written to the audit log. Read scoped routes never mutate, whatever their method.
+func (h *Router) handle(mux *http.ServeMux, pattern string, scope auth.Scope, fn http.HandlerFunc) {
+	route := specRoute(apiSpec, pattern)
+	h.routes = append(h.routes, pattern)
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && scope != auth.ScopeRead {
+			rec := &statusRecorder{ResponseWriter: w}
+			w = rec
+			// r is read when the call returns, after the key was attached to it
+			defer func() {
+				h.auditCall(rec, r)
+			}()
+		}
+		if h.maxBody > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+		}
+
+		var (
+			key auth.Key
+			err error
+		)
+		secret, r := credential(r)
+		if h.keyring != nil {
+			key, err = h.keyring.AuthorizeSecret(secret, scope)
+			if key.ID != "" {
+				r = r.WithContext(auth.WithKey(r.Context(), key))
+			}
+		}
+		// failed attempts count too, so keys cannot be guessed faster than the limit
+		if !h.allow(w, r, key) {
+			return
+		}
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`ApiKey header="%s"`, auth.Header))
+			}
+			handleError(w, err)
+			return
+		}
+		if err := validate(r, route); err != nil {
+			handleError(w, err)
+			logging.FromContext(r.Context(), h.logger).Warn("invalid request", logging.Err(err))
+			return
+		}
+		fn(w, r)
+	})
+}
+
+// allow - takes a token of the key's bucket, or the client IP's without a key, answers 429 when it is empty
+func (h *Router) allow(w http.ResponseWriter, r *http.Request, key auth.Key) bool {
+	if h.limiter == nil {
+		return true
+	}
+	client := "key:" + key.ID
+	if key.ID == "" {
+		client = "ip:" + clientIP(r)
+	}
+	ok, wait := h.limiter.Allow(client)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	handleError(w, ratelimit.ErrLimited)
+	logging.FromContext(r.Context(), h.logger).Warn("rate limited", slog.String("client", client))
+
+	return false
+}
+
+// clientIP - peer address of the connection, forwarding headers are not trusted
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (h *Router) auditCall(rec *statusRecorder, r *http.Request) {
+	keyID := "anonymous"
+	if key, ok := auth.FromContext(r.Context()); ok {
+		keyID = key.ID
+	}
+	code := rec.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	h.audit.InfoContext(r.Context(), "audit",
+		slog.String("key_id", keyID),
+		slog.String(logging.KeyTenant, auth.Tenant(r.Context())),
+		slog.String("method", r.Method),
+		slog.String("route", r.Pattern),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", code),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String(logging.KeyRequestID, rec.Header().Get(requestIDHeader)),
+	)
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+const (
+	healthOK       = "ok"
+	healthNotReady = "not ready"
+)
+
+// Healthz - liveness, the process is up and serving
+func (h *Router) Healthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{Status: healthOK})
+}
+
+// Readyz - fails until the first block is processed, while the parser lags too far behind,
+// and while the node fails it: after a failed tick, or with no successful tick for too many intervals.
+// The remote head only moves on successful ticks, so the lag alone misses a node that went down.
+// A paused parser does not tick and is judged on its lag only.
+func (h *Router) Readyz(w http.ResponseWriter, _ *http.Request) {
+	status := h.parser.Status()
+	maxAge := time.Duration(h.maxStale) * status.Interval
+	switch {
+	case status.LastSuccessfulTick.IsZero():
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: healthNotReady,
+			Reason: "no block processed yet",
+		})
+	case !status.Paused && status.LastErrorAt.After(status.LastSuccessfulTick):
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: healthNotReady,
+			Reason: "last tick failed: " + status.LastError,
+		})
+	case !status.Paused && maxAge > 0 && time.Since(status.LastSuccessfulTick) > maxAge:
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: healthNotReady,
+			Reason: fmt.Sprintf("no successful tick for %s, max %s", time.Since(status.LastSuccessfulTick).Round(time.Millisecond), maxAge),
+		})
+	case status.Lag() > h.maxLag:
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status: healthNotReady,
+			Reason: fmt.Sprintf("parser is %d blocks behind, max %d", status.Lag(), h.maxLag),
+		})
+	default:
+		writeJSON(w, http.StatusOK, HealthResponse{Status: healthOK})
+	}
+}
+
+func (h *Router) GetStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.parser.Status())
+}
+
+func (h *Router) GetCurrentBlock(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, CurrentBlock{
-		CurrentBlockHeight: h.parser.GetCurrentBlock(),
+		CurrentBlockHeight: h.parser.GetCurrentBlock(r.Context()),
 	})
 }
 
@@ -49,21 +466,333 @@ func (h *Router) Subscribe(w http.ResponseWriter, r *http.Request) {
 	var request SubscribeRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		handleError(w, err)
-		log.Println("error decoding request", err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+	addr := models.Address(request.Address)
+	h.parser.Subscribe(r.Context(), auth.Tenant(r.Context()), addr)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Router) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	var request SubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
 		return
 	}
 	addr := models.Address(request.Address)
-	h.parser.Subscribe(r.Context(), addr)
+	h.parser.Unsubscribe(r.Context(), auth.Tenant(r.Context()), addr)
 
 	w.WriteHeader(http.StatusOK)
 }
 
+const (
+	BulkCreated = "created"
+	BulkExists  = "exists"
+	BulkInvalid = "invalid"
+)
+
+type BulkSubscribeResult struct {
+	Address string `json:"address"`
+	Status  string `json:"status"`
+	// Line - line of a CSV or plain text upload the address was read from
+	Line int `json:"line,omitempty"`
+}
+
+type BulkSubscribeResponse struct {
+	Created int                   `json:"created"`
+	Exists  int                   `json:"exists"`
+	Invalid int                   `json:"invalid"`
+	Results []BulkSubscribeResult `json:"results"`
+}
+
+// BulkSubscribe - subscribes a JSON array of addresses, or an address per line of a CSV or plain text body
+func (h *Router) BulkSubscribe(w http.ResponseWriter, r *http.Request) {
+	items, err := readBulkItems(r.Body)
+	if err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+
+	tenant := auth.Tenant(r.Context())
+	response := BulkSubscribeResponse{Results: make([]BulkSubscribeResult, 0, len(items))}
+	for _, item := range items {
+		result := BulkSubscribeResult{Address: item.Input, Line: item.Line}
+		switch {
+		case !item.Address.Valid():
+			result.Status = BulkInvalid
+			response.Invalid++
+		case h.parser.Subscribe(r.Context(), tenant, item.Address):
+			result.Status = BulkCreated
+			response.Created++
+		default:
+			result.Status = BulkExists
+			response.Exists++
+		}
+		response.Results = append(response.Results, result)
+	}
+	logging.FromContext(r.Context(), h.logger).Info("bulk subscribe",
+		slog.String(logging.KeyTenant, tenant),
+		slog.Int(BulkCreated, response.Created),
+		slog.Int(BulkExists, response.Exists),
+		slog.Int(BulkInvalid, response.Invalid),
+	)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// readBulkItems - a body starting with [ is a JSON array of addresses, anything else a watchlist
+func readBulkItems(body io.Reader) ([]watchlist.Item, error) {
+	br := bufio.NewReader(body)
+	for {
+		b, err := br.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == '[' {
+			break
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return watchlist.Items(br)
+		}
+		_, _ = br.ReadByte()
+	}
+
+	var addrs []string
+	if err := json.NewDecoder(br).Decode(&addrs); err != nil {
+		return nil, err
+	}
+	items := make([]watchlist.Item, 0, len(addrs))
+	for _, addr := range addrs {
+		items = append(items, watchlist.Item{
+			Input:   addr,
+			Address: models.Address(strings.TrimSpace(addr)),
+		})
+	}
+
+	return items, nil
+}
+
+// GetTransactions - JSON by default, CSV or NDJSON by format= or the Accept header
 func (h *Router) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	addr := models.Address(r.PathValue(addressParam))
-	txs := h.parser.GetTransactions(r.Context(), addr)
+	format, err := exportFormat(r, export.FormatJSON)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if format != export.FormatJSON {
+		h.stream(w, r, format, data_store.TxQuery{
+			Tenant:    auth.Tenant(r.Context()),
+			Addresses: []models.Address{addr},
+		})
+		return
+	}
+	txs := h.parser.GetTransactions(r.Context(), auth.Tenant(r.Context()), addr)
 	writeJSON(w, http.StatusOK, txs)
 }
 
+var errInvalidBlockRange = errors.New("invalid block range")
+
+// Export - streams the transactions of every address the tenant watches, or of the address= ones,
+// in the from_block..to_block range as NDJSON or CSV
+func (h *Router) Export(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := data_store.TxQuery{Tenant: auth.Tenant(r.Context())}
+	for _, addr := range query["address"] {
+		q.Addresses = append(q.Addresses, models.Address(addr))
+	}
+	var err error
+	if q.FromBlock, err = blockParam(query.Get("from_block")); err != nil {
+		handleError(w, err)
+		return
+	}
+	if q.ToBlock, err = blockParam(query.Get("to_block")); err != nil {
+		handleError(w, err)
+		return
+	}
+	if q.ToBlock > 0 && q.ToBlock < q.FromBlock {
+		handleError(w, fmt.Errorf("%w: to_block %d is before from_block %d", errInvalidBlockRange, q.ToBlock, q.FromBlock))
+		return
+	}
+	format, err := exportFormat(r, export.FormatNDJSON)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if format == export.FormatJSON {
+		handleError(w, fmt.Errorf("%w: exports are %s or %s", export.ErrInvalidFormat, export.FormatNDJSON, export.FormatCSV))
+		return
+	}
+	h.stream(w, r, format, q)
+}
+
+// blockParam - decimal or 0x prefixed hex block number, 0 when empty
+func blockParam(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	block, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || block < 0 {
+		return 0, fmt.Errorf("%w: block %q", errInvalidBlockRange, s)
+	}
+	return int(block), nil
+}
+
+// exportFormat - format= wins over the Accept header, fallback without either
+func exportFormat(r *http.Request, fallback string) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return export.ParseFormat(format)
+	}
+	if format := export.Negotiate(r.Header.Get("Accept")); format != "" {
+		return format, nil
+	}
+	return fallback, nil
+}
+
+const (
+	// exportFlushRows - rows buffered before they are sent to the client
+	exportFlushRows = 500
+	// exportWriteTimeout - time the client gets to read each flushed batch, extends the server write timeout
+	exportWriteTimeout = 30 * time.Second
+)
+
+// stream - writes the rows of q as they are read from the store, memory stays flat whatever the size of the export
+func (h *Router) stream(w http.ResponseWriter, r *http.Request, format string, q data_store.TxQuery) {
+	columns, err := export.ParseColumns(r.URL.Query().Get("columns"))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	if format == export.FormatCSV {
+		w.Header().Set("Content-Disposition", `attachment; filename="transactions.csv"`)
+	}
+	rows, err := writeRows(w, format, columns, h.parser.ScanTransactions(r.Context(), q))
+	logger := logging.FromContext(r.Context(), h.logger)
+	if err != nil {
+		// the status line is gone already, the client sees a truncated body
+		logger.Warn("export aborted", slog.Int("rows", rows), logging.Err(err))
+		return
+	}
+	logger.Info("export", slog.String("format", format), slog.Int("rows", rows))
+}
+
+func writeRows(w http.ResponseWriter, format string, columns []export.Column, rows iter.Seq2[models.Address, models.Transaction]) (int, error) {
+	ew, err := export.NewWriter(w, format, columns)
+	if err != nil {
+		return 0, err
+	}
+	rc := http.NewResponseController(w)
+	n := 0
+	for addr, tx := range rows {
+		if err := ew.Write(addr, tx); err != nil {
+			return n, err
+		}
+		n++
+		if n%exportFlushRows != 0 {
+			continue
+		}
+		if err := ew.Flush(); err != nil {
+			return n, err
+		}
+		// not every writer supports deadlines or flushing, the rows are sent at the end then
+		_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		_ = rc.Flush()
+	}
+
+	return n, ew.Flush()
+}
+
+type CreateSubscriptionRequest struct {
+	Rule models.Rule `json:"rule"`
+}
+
+func (h *Router) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var request CreateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+	sub, err := h.parser.CreateSubscription(r.Context(), auth.Tenant(r.Context()), request.Rule)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+// GetSubscribers - addresses the caller's tenant is subscribed to
+func (h *Router) GetSubscribers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.parser.GetSubscribers(r.Context(), auth.Tenant(r.Context())))
+}
+
+func (h *Router) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.parser.GetSubscriptions(r.Context(), auth.Tenant(r.Context())))
+}
+
+func (h *Router) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	if err := h.parser.DeleteSubscription(r.Context(), auth.Tenant(r.Context()), r.PathValue(idParam)); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Router) GetSubscriptionTransactions(w http.ResponseWriter, r *http.Request) {
+	txs, err := h.parser.GetSubscriptionTransactions(r.Context(), auth.Tenant(r.Context()), r.PathValue(idParam))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, txs)
+}
+
+type CreateKeyRequest struct {
+	ID     string       `json:"id"`
+	Tenant string       `json:"tenant"`
+	Scopes []auth.Scope `json:"scopes"`
+}
+
+type CreateKeyResponse struct {
+	auth.Key
+	// Secret - shown only once
+	Secret string `json:"key"`
+}
+
+func (h *Router) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var request CreateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		handleError(w, err)
+		logging.FromContext(r.Context(), h.logger).Warn("error decoding request", logging.Err(err))
+		return
+	}
+	key, secret, err := h.keyring.Generate(request.ID, request.Tenant, request.Scopes)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, CreateKeyResponse{Key: key, Secret: secret})
+}
+
+func (h *Router) GetKeys(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.keyring.List())
+}
+
+func (h *Router) DeleteKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.keyring.Remove(r.PathValue(idParam)); err != nil {
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 type errorStatus = struct {
 	err        error
 	statusCode int
@@ -91,6 +820,76 @@ var errorsList = []errorStatus{
 		statusCode: http.StatusBadRequest,
 		msg:        "invalid address",
 	},
+	{
+		err:        data_store.ErrSubscriptionNotFound,
+		statusCode: http.StatusNotFound,
+		msg:        "not found subscription",
+	},
+	{
+		err:        matcher.ErrInvalidRule,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid rule",
+	},
+	{
+		err:        auth.ErrUnauthenticated,
+		statusCode: http.StatusUnauthorized,
+		msg:        "unauthenticated",
+	},
+	{
+		err:        auth.ErrForbidden,
+		statusCode: http.StatusForbidden,
+		msg:        "forbidden",
+	},
+	{
+		err:        auth.ErrInvalidKey,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid api key",
+	},
+	{
+		err:        auth.ErrKeyExists,
+		statusCode: http.StatusConflict,
+		msg:        "api key already exists",
+	},
+	{
+		err:        auth.ErrKeyNotFound,
+		statusCode: http.StatusNotFound,
+		msg:        "not found api key",
+	},
+	{
+		err:        export.ErrInvalidFormat,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid format",
+	},
+	{
+		err:        export.ErrInvalidColumn,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid column",
+	},
+	{
+		err:        errInvalidBlockRange,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid block range",
+	},
+	{
+		err:        errInvalidRequest,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid request",
+	},
+	{
+		err:        parser.ErrInvalidBlock,
+		statusCode: http.StatusBadRequest,
+		msg:        "invalid block",
+	},
+	{
+		err:        parser.ErrFetchFailed,
+		statusCode: http.StatusBadGateway,
+		msg:        "node request failed",
+	},
+	{
+		err:        ratelimit.ErrLimited,
+		statusCode: http.StatusTooManyRequests,
+		msg:        "too many requests",
+	},
 }
 
 type ErrorResponse struct {
@@ -104,13 +903,23 @@ func handleError(w http.ResponseWriter, err error) {
 		statusCode: http.StatusBadRequest,
 		msg:        "UNKNOWN ERROR",
 	}
-	for _, e := range errorsList {
-		if errors.Is(err, e.err) {
-			errStatus = e
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		// also when the body was read while validating the request
+		errStatus = errorStatus{
+			statusCode: http.StatusRequestEntityTooLarge,
+			msg:        "request body too large",
+		}
+	} else {
+		for _, e := range errorsList {
+			if errors.Is(err, e.err) {
+				errStatus = e
 
-			break
+				break
+			}
 		}
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(errStatus.statusCode)
 	err = json.NewEncoder(w).Encode(ErrorResponse{
 		Msg: errStatus.msg,
@@ -128,6 +937,7 @@ type CurrentBlock struct {
 }
 
 func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	err := json.NewEncoder(w).Encode(body)
 	if err != nil {
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// downNode - a node that answers until it is taken down, then fails its calls or stops answering
+type downNode struct {
+	mu   sync.Mutex
+	err  error
+	hang bool
+}
+
+func (n *downNode) GetBlockNumber(ctx context.Context) (int, error) {
+	n.mu.Lock()
+	err, hang := n.err, n.hang
+	n.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return 10, err
+}
+
+func (*downNode) GetTxsFromBlock(context.Context, int) ([]models.Transaction, error) {
+	return nil, nil
+}
+
+func (*downNode) Endpoint() string { return "http://node.test" }
+
+func (n *downNode) down(err error, hang bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err, n.hang = err, hang
+}
+
+func readyz(t *testing.T, router http.Handler) (int, HealthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var health HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health), rec.Body.String())
+	return rec.Code, health
+}
+
+func TestReadyz_NodeDown(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		hang   bool
+		reason string
+	}{
+		{name: "failing calls", err: errors.New("connection refused"), reason: "last tick failed: connection refused"},
+		{name: "no answers", hang: true, reason: "no successful tick for"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &downNode{}
+			p := parser.NewParserRuntime(t.Context(), node, data_store.NewDataStore(), parser.ParserConfig{TxFetchInterval: 5 * time.Millisecond, Workers: 1})
+			router := NewRouter(p, WithMaxStaleTicks(3))
+			// Parse stops with the test context
+			go p.Parse()
+
+			require.Eventually(t, func() bool {
+				code, _ := readyz(t, router)
+				return code == http.StatusOK
+			}, time.Second, 5*time.Millisecond)
+
+			// the remote head stays at the last good answer, so the lag alone still reads as ready
+			node.down(tt.err, tt.hang)
+			require.Eventually(t, func() bool {
+				code, _ := readyz(t, router)
+				return code == http.StatusServiceUnavailable
+			}, time.Second, 5*time.Millisecond)
+			_, health := readyz(t, router)
+			require.Equal(t, healthNotReady, health.Status)
+			require.Contains(t, health.Reason, tt.reason)
+			require.Zero(t, p.Status().Lag())
+		})
+	}
+}
+
+func TestBulkSubscribe(t *testing.T) {
+	router, _, store := newContractRouter(t)
+	ctx := t.Context()
+	bulk := func(t *testing.T, contentType, body string) (int, BulkSubscribeResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/subscriptions/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set(auth.Header, adminKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var response BulkSubscribeResponse
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+		}
+		return rec.Code, response
+	}
+	// 0x and 40 characters, but not all of them hex digits
+	notHex := "0x" + strings.Repeat("g", 40)
+	// 42 characters holding 0x, but not as a prefix
+	notPrefixed := strings.Repeat("a", 40) + "0x"
+
+	t.Run("json array", func(t *testing.T) {
+		code, response := bulk(t, "application/json", `["`+string(watched)+`", "`+notHex+`", "`+strings.ToUpper(string(watched))[:2]+`"]`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, 1, response.Created)
+		require.Equal(t, 2, response.Invalid)
+		require.Equal(t, []BulkSubscribeResult{
+			{Address: string(watched), Status: BulkCreated},
+			{Address: notHex, Status: BulkInvalid},
+			{Address: "0X", Status: BulkInvalid},
+		}, response.Results)
+		require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, watched))
+	})
+
+	t.Run("csv with a header", func(t *testing.T) {
+		body := "address,label\n" +
+			"\n" +
+			"# cold wallets\n" +
+			string(watched) + ",hot wallet\n" +
+			strings.ToUpper(string(unwatched[2:])) + ",no prefix\n" +
+			"0x" + strings.ToUpper(string(unwatched[2:])) + ",cold wallet\n" +
+			notPrefixed + ",misplaced prefix\n"
+		code, response := bulk(t, "text/csv", body)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, BulkSubscribeResponse{Created: 1, Exists: 1, Invalid: 2, Results: []BulkSubscribeResult{
+			{Address: string(watched), Status: BulkExists, Line: 4},
+			{Address: strings.ToUpper(string(unwatched[2:])), Status: BulkInvalid, Line: 5},
+			{Address: "0x" + strings.ToUpper(string(unwatched[2:])), Status: BulkCreated, Line: 6},
+			{Address: notPrefixed, Status: BulkInvalid, Line: 7},
+		}}, response)
+		require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, unwatched))
+	})
+
+	t.Run("oversize body", func(t *testing.T) {
+		// the contract router caps bodies at 1 KiB
+		code, _ := bulk(t, "text/plain", strings.Repeat(string(watched)+"\n", 30))
+		require.Equal(t, http.StatusRequestEntityTooLarge, code)
+	})
+}
+
+func TestSubscribe_MixedCase(t *testing.T) {
+	router, _, store := newContractRouter(t)
+	ctx := t.Context()
+	call := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set(auth.Header, adminKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec
+	}
+	// a checksummed address, as wallets display it
+	checksummed := "0x" + strings.ToUpper(string(unwatched[2:3])) + string(unwatched[3:])
+
+	call(t, http.MethodPost, "/subscribe", `{"address":"`+checksummed+`"}`)
+	require.True(t, store.IsSubscriber(ctx, models.DefaultTenant, unwatched))
+
+	tx := models.Transaction{BlockNumber: "0x1", Hash: "0x01", From: unwatched, To: watched, TransactionIndex: "0x0"}
+	require.True(t, store.AddTx(ctx, unwatched, tx))
+	var txs []models.Transaction
+	rec := call(t, http.MethodGet, "/transactions/"+checksummed, "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &txs))
+	require.Equal(t, []models.Transaction{tx}, txs)
+
+	call(t, http.MethodPost, "/unsubscribe", `{"address":"0x`+strings.ToUpper(checksummed[2:])+`"}`)
+	require.False(t, store.IsSubscriber(ctx, models.DefaultTenant, unwatched))
+}
+
+func TestValidate_CSVBody(t *testing.T) {
+	router, _, _ := newContractRouter(t)
+	post := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set(auth.Header, adminKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// taken as written by an operation with a CSV body
+	rec := post("/subscriptions/bulk", "address\n# comment\n"+string(watched)+",a,b\n")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	// still checked against the spec by one without
+	rec = post("/subscribe", string(watched)+"\n")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "invalid request")
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultWSPingInterval - heartbeat period, a client that answers no ping for two periods is disconnected
+	defaultWSPingInterval = 30 * time.Second
+	// wsWriteWait - time a client gets to take each message, a client that does not read is dropped
+	wsWriteWait = 10 * time.Second
+	// wsMaxMessageBytes - largest client message, room for a subscribe of about a thousand addresses
+	wsMaxMessageBytes = 64 << 10
+	// wsReplyBuffer - replies queued while the writer is busy, reading stops while it is full
+	wsReplyBuffer = 16
+	// wsKeyParam - query parameter carrying the API key of browsers, which cannot set headers on the upgrade
+	wsKeyParam = "api_key"
+)
+
+// websocket message types
+const (
+	WSSubscribe    = "subscribe"
+	WSUnsubscribe  = "unsubscribe"
+	WSSubscribed   = "subscribed"
+	WSUnsubscribed = "unsubscribed"
+	WSMatch        = "match"
+	WSError        = "error"
+)
+
+// WSRequest - a client message, subscribe or unsubscribe
+type WSRequest struct {
+	Type string `json:"type"`
+	// ID - echoed on the reply so the client can pair them
+	ID        string   `json:"id,omitempty"`
+	Addresses []string `json:"addresses"`
+}
+
+// WSMessage - a server message, a reply to a request or a match
+type WSMessage struct {
+	Type        string              `json:"type"`
+	ID          string              `json:"id,omitempty"`
+	Addresses   []string            `json:"addresses,omitempty"`
+	Address     string              `json:"address,omitempty"`
+	Transaction *models.Transaction `json:"transaction,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+var errInvalidWSRequest = errors.New("invalid websocket request")
+
+// WithWebSocketPing - heartbeat period of /ws connections
+func WithWebSocketPing(interval time.Duration) RouterOption {
+	return func(r *Router) {
+		r.wsPing = interval
+	}
+}
+
+// WithWebSocketOrigins - origins, as scheme://host[:port], of the pages besides the API's own that may open a /ws connection
+func WithWebSocketOrigins(origins ...string) RouterOption {
+	return func(r *Router) {
+		r.wsOrigins = make(map[string]bool, len(origins))
+		for _, origin := range origins {
+			r.wsOrigins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+}
+
+func (h *Router) wsUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: h.checkOrigin,
+		Error: func(w http.ResponseWriter, _ *http.Request, status int, reason error) {
+			writeJSON(w, status, ErrorResponse{Err: reason.Error(), Msg: "websocket upgrade failed"})
+		},
+	}
+}
+
+// checkOrigin - a page may open a socket when it is served by the API's host or from an allowed origin, so a
+// page elsewhere cannot use a key it got hold of. Clients without an Origin header are not browsers.
+func (h *Router) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || h.wsOrigins[strings.ToLower(origin)]
+}
+
+// CloseWebSockets - tells every open /ws client the server is going away, http.Server.Shutdown does not wait for hijacked connections
+func (h *Router) CloseWebSockets() {
+	h.wsCloseOnce.Do(func() {
+		close(h.wsClosing)
+	})
+}
+
+// credential - API key of the request, websocket upgrades may carry it as ?api_key= instead of a header.
+// The request is returned without the query parameter, so the key reaches no log, span or handler.
+func credential(r *http.Request) (string, *http.Request) {
+	secret := auth.Credential(r)
+	query := r.URL.Query()
+	if !query.Has(wsKeyParam) {
+		return secret, r
+	}
+	if secret == "" && websocket.IsWebSocketUpgrade(r) {
+		secret = query.Get(wsKeyParam)
+	}
+	query.Del(wsKeyParam)
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	r.RequestURI = r.URL.RequestURI()
+
+	return secret, r
+}
+
+// WebSocket - a bidirectional feed of live matches: the client subscribes and unsubscribes addresses
+// of its tenant and receives their matches as the parser stores them. A client that falls behind is
+// disconnected rather than holding up the parser.
+func (h *Router) WebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx, h.logger)
+	watcher, err := h.parser.Watch(ctx, auth.Tenant(ctx), nil)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	defer watcher.Close()
+
+	conn, err := h.wsUpgrader().Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader answered the client already
+		logger.Warn("websocket upgrade failed", logging.Err(err))
+		return
+	}
+	defer conn.Close()
+	logger.Info("websocket connected")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	replies := make(chan WSMessage, wsReplyBuffer)
+	go func() {
+		defer cancel()
+		err := h.readWS(ctx, conn, watcher, replies)
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) && ctx.Err() == nil {
+			logger.Info("websocket read ended", logging.Err(err))
+		}
+	}()
+
+	err = h.writeWS(ctx, conn, watcher, replies)
+	logger.Info("websocket disconnected", slog.Int("addresses", watcher.Addresses()), logging.Err(err))
+}
+
+// readWS - applies client requests to the watcher until the client goes away or stops answering pings
+func (h *Router) readWS(ctx context.Context, conn *websocket.Conn, watcher *parser.Watcher, replies chan<- WSMessage) error {
+	pongWait := 2 * h.wsPing
+	conn.SetReadLimit(wsMaxMessageBytes)
+	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		return err
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		reply := h.applyWS(ctx, watcher, data)
+		select {
+		case replies <- reply:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// applyWS - adds or removes the addresses of a request, all or none of them. A bad request is answered
+// with an error message, the connection stays up.
+func (h *Router) applyWS(ctx context.Context, watcher *parser.Watcher, data []byte) WSMessage {
+	var request WSRequest
+	fail := func(err error) WSMessage {
+		logging.FromContext(ctx, h.logger).Info("websocket request failed", slog.String("type", request.Type), logging.Err(err))
+		return WSMessage{Type: WSError, ID: request.ID, Error: err.Error()}
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return fail(fmt.Errorf("%w: %w", errInvalidWSRequest, err))
+	}
+	if request.Type != WSSubscribe && request.Type != WSUnsubscribe {
+		return fail(fmt.Errorf("%w: type %q, want %s or %s", errInvalidWSRequest, request.Type, WSSubscribe, WSUnsubscribe))
+	}
+	if len(request.Addresses) == 0 {
+		return fail(fmt.Errorf("%w: no addresses", errInvalidWSRequest))
+	}
+	addrs := make([]models.Address, 0, len(request.Addresses))
+	for _, a := range request.Addresses {
+		if !models.Address(a).Valid() {
+			return fail(fmt.Errorf("%w: invalid address %q", errInvalidWSRequest, a))
+		}
+		// replies list the addresses in the form matches carry them
+		addrs = append(addrs, models.NormalizeAddress(a))
+	}
+
+	reply := WSMessage{Type: WSUnsubscribed, ID: request.ID}
+	if request.Type == WSSubscribe {
+		reply.Type = WSSubscribed
+		var added []models.Address
+		for _, addr := range addrs {
+			if watcher.Watches(addr) {
+				continue
+			}
+			if err := watcher.Add(ctx, addr); err != nil {
+				for _, addr := range added {
+					watcher.Remove(addr)
+				}
+				return fail(err)
+			}
+			added = append(added, addr)
+		}
+	} else {
+		for _, addr := range addrs {
+			watcher.Remove(addr)
+		}
+	}
+	for _, addr := range addrs {
+		reply.Addresses = append(reply.Addresses, string(addr))
+	}
+
+	return reply
+}
+
+// writeWS - the only writer of conn: replies, matches and pings, until the client, the watcher or the server goes away
+func (h *Router) writeWS(ctx context.Context, conn *websocket.Conn, watcher *parser.Watcher, replies <-chan WSMessage) error {
+	ping := time.NewTicker(h.wsPing)
+	defer ping.Stop()
+	write := func(msg WSMessage) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(wsWriteWait)); err != nil {
+			return err
+		}
+		return conn.WriteJSON(msg)
+	}
+	closeWith := func(code int, reason string) {
+		msg := websocket.FormatCloseMessage(code, reason)
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			closeWith(websocket.CloseNormalClosure, "")
+			return nil
+		case <-h.wsClosing:
+			closeWith(websocket.CloseGoingAway, "server shutting down")
+			return nil
+		case <-watcher.Done():
+			// the watcher only ends on its own when the client fell too far behind
+			closeWith(websocket.CloseTryAgainLater, watcher.Err().Error())
+			return watcher.Err()
+		case reply := <-replies:
+			if err := write(reply); err != nil {
+				return err
+			}
+		case m := <-watcher.Matches():
+			err := write(WSMessage{Type: WSMatch, Address: string(m.Address), Transaction: &m.Tx})
+			if err != nil {
+				return err
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/parser"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+type wsFixture struct {
+	url    string
+	router *Router
+	parser *parser.ParserRuntime
+}
+
+func newWSFixture(t *testing.T, node chainClient, parserOpts []parser.Option, opts ...RouterOption) wsFixture {
+	t.Helper()
+	p := parser.NewParserRuntime(t.Context(), node, data_store.NewDataStore(), parser.ParserConfig{
+		TxFetchInterval: 10 * time.Millisecond,
+		Workers:         2,
+	}, parserOpts...)
+	router := NewRouter(p, opts...)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	return wsFixture{url: "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws", router: router, parser: p}
+}
+
+func dialWS(t *testing.T, url string, header http.Header) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.DialContext(t.Context(), url, header)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+// roundTrip - sends request and reads the reply to it
+func roundTrip(t *testing.T, conn *websocket.Conn, request WSRequest) WSMessage {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(request))
+	var reply WSMessage
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	require.NoError(t, conn.ReadJSON(&reply))
+	return reply
+}
+
+// closeCode - code of the close frame ending conn, read past any queued messages
+func closeCode(t *testing.T, conn *websocket.Conn) int {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		require.True(t, errors.As(err, &closeErr), "read ended with %v", err)
+		return closeErr.Code
+	}
+}
+
+func TestWebSocket_SubscribeAndMatch(t *testing.T) {
+	ctx := t.Context()
+	f := newWSFixture(t, chainClient{head: 7, txs: []models.Transaction{grpcTx(7, watched), grpcTx(7, unwatched)}}, nil)
+	require.True(t, f.parser.Subscribe(ctx, models.DefaultTenant, watched))
+	conn := dialWS(t, f.url, nil)
+
+	reply := roundTrip(t, conn, WSRequest{Type: WSSubscribe, ID: "1", Addresses: []string{"0x" + strings.ToUpper(string(watched[2:]))}})
+	require.Equal(t, WSMessage{Type: WSSubscribed, ID: "1", Addresses: []string{string(watched)}}, reply)
+
+	// Parse stops with the test context
+	go f.parser.Parse()
+
+	var match WSMessage
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(t, conn.ReadJSON(&match))
+	require.Equal(t, WSMatch, match.Type)
+	require.Equal(t, string(watched), match.Address)
+	require.Equal(t, grpcTx(7, watched).Hash, match.Transaction.Hash)
+
+	reply = roundTrip(t, conn, WSRequest{Type: WSUnsubscribe, ID: "2", Addresses: []string{string(watched)}})
+	require.Equal(t, WSMessage{Type: WSUnsubscribed, ID: "2", Addresses: []string{string(watched)}}, reply)
+}
+
+func TestWebSocket_BadRequests(t *testing.T) {
+	ctx := t.Context()
+	f := newWSFixture(t, chainClient{}, nil)
+	require.True(t, f.parser.Subscribe(ctx, models.DefaultTenant, watched))
+	conn := dialWS(t, f.url, nil)
+
+	for _, c := range []struct {
+		name    string
+		request WSRequest
+		err     string
+	}{
+		{name: "not subscribed", request: WSRequest{Type: WSSubscribe, Addresses: []string{string(watched), string(unwatched)}}, err: parser.ErrNotSubscribed.Error()},
+		{name: "invalid address", request: WSRequest{Type: WSSubscribe, Addresses: []string{"0x12"}}, err: "invalid address"},
+		{name: "no addresses", request: WSRequest{Type: WSUnsubscribe}, err: "no addresses"},
+		{name: "unknown type", request: WSRequest{Type: "watch", Addresses: []string{string(watched)}}, err: `type "watch"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.request.ID = c.name
+			reply := roundTrip(t, conn, c.request)
+			require.Equal(t, WSError, reply.Type)
+			require.Equal(t, c.name, reply.ID)
+			require.Contains(t, reply.Error, c.err)
+		})
+	}
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+	var reply WSMessage
+	require.NoError(t, conn.ReadJSON(&reply))
+	require.Equal(t, WSError, reply.Type)
+	require.Contains(t, reply.Error, errInvalidWSRequest.Error())
+
+	// a failed subscribe adds none of its addresses, the connection carries on
+	reply = roundTrip(t, conn, WSRequest{Type: WSSubscribe, Addresses: []string{string(watched)}})
+	require.Equal(t, WSSubscribed, reply.Type)
+}
+
+func TestWebSocket_Auth(t *testing.T) {
+	keyring := auth.NewKeyring()
+	_, err := keyring.Add("reader", "acme", readKey, []auth.Scope{auth.ScopeRead})
+	require.NoError(t, err)
+	f := newWSFixture(t, chainClient{}, nil, WithAuth(keyring))
+	require.True(t, f.parser.Subscribe(t.Context(), "acme", watched))
+	require.True(t, f.parser.Subscribe(t.Context(), models.DefaultTenant, unwatched))
+
+	_, resp, err := websocket.DefaultDialer.DialContext(t.Context(), f.url, nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	conn := dialWS(t, f.url, http.Header{auth.Header: {readKey}})
+	require.Equal(t, WSSubscribed, roundTrip(t, conn, WSRequest{Type: WSSubscribe, Addresses: []string{string(watched)}}).Type)
+	// the socket acts as the key's tenant
+	require.Equal(t, WSError, roundTrip(t, conn, WSRequest{Type: WSSubscribe, Addresses: []string{string(unwatched)}}).Type)
+
+	// browsers cannot set headers on the upgrade
+	conn = dialWS(t, f.url+"?api_key="+readKey, nil)
+	require.Equal(t, WSSubscribed, roundTrip(t, conn, WSRequest{Type: WSSubscribe, Addresses: []string{string(watched)}}).Type)
+}
+
+func TestWebSocket_Origin(t *testing.T) {
+	f := newWSFixture(t, chainClient{}, nil, WithWebSocketOrigins("https://App.example.com/"))
+	host := strings.TrimPrefix(f.url, "ws://")
+	host = host[:strings.Index(host, "/")]
+
+	// clients other than browsers send no origin
+	dialWS(t, f.url, nil)
+	dialWS(t, f.url, http.Header{"Origin": {"http://" + host}})
+	dialWS(t, f.url, http.Header{"Origin": {"https://app.example.com"}})
+
+	_, resp, err := websocket.DefaultDialer.DialContext(t.Context(), f.url, http.Header{"Origin": {"https://evil.example.com"}})
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestCredential_DropsQueryKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ws?api_key=secret&x=1", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+
+	secret, stripped := credential(r)
+	require.Equal(t, "secret", secret)
+	require.Equal(t, "x=1", stripped.URL.RawQuery)
+	require.Equal(t, "/ws?x=1", stripped.RequestURI)
+	require.Equal(t, "api_key=secret&x=1", r.URL.RawQuery, "the caller's request is not modified")
+
+	// only upgrades take the key from the query, others lose it all the same
+	r = httptest.NewRequest(http.MethodGet, "/status?api_key=secret", nil)
+	secret, stripped = credential(r)
+	require.Empty(t, secret)
+	require.Empty(t, stripped.URL.RawQuery)
+}
+
+func TestWebSocket_Heartbeat(t *testing.T) {
+	f := newWSFixture(t, chainClient{}, nil, WithWebSocketPing(100*time.Millisecond))
+
+	conn := dialWS(t, f.url, nil)
+	var pings atomic.Int32
+	conn.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	// control frames are handled while reading
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(450*time.Millisecond)))
+	_, _, err := conn.ReadMessage()
+	var netErr interface{ Timeout() bool }
+	require.True(t, errors.As(err, &netErr) && netErr.Timeout(), "read ended with %v", err)
+	require.GreaterOrEqual(t, pings.Load(), int32(3))
+
+	// a client that does not answer pings is disconnected
+	silent := dialWS(t, f.url, nil)
+	silent.SetPingHandler(func(string) error {
+		return nil
+	})
+	require.NoError(t, silent.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, _, err = silent.ReadMessage()
+	require.Error(t, err)
+	require.False(t, errors.As(err, &netErr) && netErr.Timeout(), "the server should hang up first")
+}
+
+func TestWebSocket_SlowClientDropped(t *testing.T) {
+	const txs = 2000
+	block := make([]models.Transaction, 0, txs)
+	for i := range txs {
+		tx := grpcTx(7, watched)
+		tx.Hash = fmt.Sprintf("0x%064x", i)
+		block = append(block, tx)
+	}
+	f := newWSFixture(t, chainClient{head: 7, txs: block}, []parser.Option{parser.WithWatchBuffer(1)})
+	require.True(t, f.parser.Subscribe(t.Context(), models.DefaultTenant, watched))
+	conn := dialWS(t, f.url, nil)
+	require.Equal(t, WSSubscribed, roundTrip(t, conn, WSRequest{Type: WSSubscribe, Addresses: []string{string(watched)}}).Type)
+
+	go f.parser.Parse()
+
+	// the matcher outpaces the socket, the client is cut off instead of slowing the parser down
+	require.Equal(t, websocket.CloseTryAgainLater, closeCode(t, conn))
+	require.Eventually(t, func() bool {
+		return len(f.parser.GetTransactions(t.Context(), models.DefaultTenant, watched)) == txs
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocket_Shutdown(t *testing.T) {
+	f := newWSFixture(t, chainClient{}, nil)
+	conn := dialWS(t, f.url, nil)
+
+	f.router.CloseWebSockets()
+	f.router.CloseWebSockets()
+	require.Equal(t, websocket.CloseGoingAway, closeCode(t, conn))
+}
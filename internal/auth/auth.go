@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+type Scope string
+
+const (
+	// ScopeRead - read blocks, transactions and subscriptions
+	ScopeRead Scope = "transactions:read"
+	// ScopeSubscriptions - create and delete subscriptions
+	ScopeSubscriptions Scope = "subscriptions:write"
+	// ScopeMetrics - scrape /metrics, for a Prometheus server that should not read tenant data
+	ScopeMetrics Scope = "metrics:read"
+	// ScopeAdmin - manage keys and the service, implies every other scope
+	ScopeAdmin Scope = "admin"
+)
+
+const (
+	// Header - carries the API key, Authorization: Bearer <key> is accepted as well
+	Header = "X-API-Key"
+
+	secretPrefix = "ethp_"
+	minSecretLen = 16
+)
+
+var (
+	ErrUnauthenticated = errors.New("missing or invalid api key")
+	ErrForbidden       = errors.New("api key lacks the required scope")
+	ErrInvalidKey      = errors.New("invalid api key")
+	ErrKeyExists       = errors.New("api key already exists")
+	ErrKeyNotFound     = errors.New("api key not found")
+)
+
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case ScopeRead, ScopeSubscriptions, ScopeMetrics, ScopeAdmin:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("%w: unknown scope %q, want %s, %s, %s or %s", ErrInvalidKey, s, ScopeRead, ScopeSubscriptions, ScopeMetrics, ScopeAdmin)
+	}
+}
+
+// Key - an API key without its secret, only the SHA-256 of the secret is kept.
+// Subscriptions made with the key belong to its tenant.
+type Key struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	hash      [sha256.Size]byte
+}
+
+// Allows - admin keys are allowed every scope
+func (k Key) Allows(scope Scope) bool {
+	return slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope)
+}
+
+// Keyring - API keys looked up by secret
+type Keyring struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[string]Key),
+	}
+}
+
+// Add - registers secret under id, keys without a tenant belong to models.DefaultTenant.
+// A secret belongs to one key only, so that it authenticates as the same key every time.
+func (k *Keyring) Add(id, tenant, secret string, scopes []Scope) (Key, error) {
+	if id == "" {
+		return Key{}, fmt.Errorf("%w: empty id", ErrInvalidKey)
+	}
+	if len(secret) < minSecretLen {
+		return Key{}, fmt.Errorf("%w: %s secret shorter than %d characters", ErrInvalidKey, id, minSecretLen)
+	}
+	if len(scopes) == 0 {
+		return Key{}, fmt.Errorf("%w: %s has no scopes", ErrInvalidKey, id)
+	}
+	for _, scope := range scopes {
+		if _, err := ParseScope(string(scope)); err != nil {
+			return Key{}, err
+		}
+	}
+	if tenant == "" {
+		tenant = models.DefaultTenant
+	}
+	key := Key{
+		ID:        id,
+		Tenant:    tenant,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now(),
+		hash:      sha256.Sum256([]byte(secret)),
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; ok {
+		return Key{}, fmt.Errorf("%w: %s", ErrKeyExists, id)
+	}
+	for _, other := range k.keys {
+		if other.hash == key.hash {
+			return Key{}, fmt.Errorf("%w: the secret of %s is registered already", ErrKeyExists, id)
+		}
+	}
+	k.keys[id] = key
+
+	return key, nil
+}
+
+// Generate - registers a random secret under id, a random id when empty. The secret is returned once.
+func (k *Keyring) Generate(id, tenant string, scopes []Scope) (Key, string, error) {
+	if id == "" {
+		id = strings.ToLower(rand.Text()[:10])
+	}
+	secret := secretPrefix + rand.Text()
+	key, err := k.Add(id, tenant, secret, scopes)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	delete(k.keys, id)
+
+	return nil
+}
+
+// List - keys sorted by ID
+func (k *Keyring) List() []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		key.Scopes = slices.Clone(key.Scopes)
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b Key) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return keys
+}
+
+// Authenticate - key of secret. Every stored hash is compared in constant time, so the
+// response time does not depend on which key, if any, matched.
+func (k *Keyring) Authenticate(secret string) (Key, bool) {
+	if secret == "" {
+		return Key{}, false
+	}
+	hash := sha256.Sum256([]byte(secret))
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var (
+		found Key
+		ok    bool
+	)
+	for _, key := range k.keys {
+		if subtle.ConstantTimeCompare(hash[:], key.hash[:]) == 1 {
+			found, ok = key, true
+		}
+	}
+
+	return found, ok
+}
+
+// Authorize - key of the request credential if it is allowed scope
+func (k *Keyring) Authorize(r *http.Request, scope Scope) (Key, error) {
+	return k.AuthorizeSecret(Credential(r), scope)
+}
+
+// AuthorizeSecret - key of secret if it is allowed scope
+func (k *Keyring) AuthorizeSecret(secret string, scope Scope) (Key, error) {
+	key, ok := k.Authenticate(secret)
+	if !ok {
+		return Key{}, ErrUnauthenticated
+	}
+	if !key.Allows(scope) {
+		return key, fmt.Errorf("%w: %s needs %s", ErrForbidden, key.ID, scope)
+	}
+
+	return key, nil
+}
+
+// Credential - API key sent in the X-API-Key or Authorization header
+func Credential(r *http.Request) string {
+	return CredentialFrom(r.Header.Get)
+}
+
+// CredentialFrom - API key of the X-API-Key or Authorization header read by get, such as gRPC metadata
+func CredentialFrom(get func(name string) string) string {
+	if secret := get(Header); secret != "" {
+		return secret
+	}
+	if bearer, ok := strings.CutPrefix(get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+
+	return ""
+}
+
+type keyCtx struct{}
+
+// WithKey - attaches the authenticated key to ctx
+func WithKey(ctx context.Context, key Key) context.Context {
+	return context.WithValue(ctx, keyCtx{}, key)
+}
+
+// FromContext - authenticated key of the request, false when auth is disabled
+func FromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyCtx{}).(Key)
+	return key, ok
+}
+
+// Tenant - tenant of the authenticated key, models.DefaultTenant when auth is disabled
+func Tenant(ctx context.Context) string {
+	if key, ok := FromContext(ctx); ok && key.Tenant != "" {
+		return key.Tenant
+	}
+
+	return models.DefaultTenant
+}
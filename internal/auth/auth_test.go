@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "0123456789abcdef-secret"
+
+func TestKeyringAuthenticate(t *testing.T) {
+	k := NewKeyring()
+	_, err := k.Add("reader", "", secret, []Scope{ScopeRead})
+	require.NoError(t, err)
+
+	key, ok := k.Authenticate(secret)
+	require.True(t, ok)
+	require.Equal(t, "reader", key.ID)
+	require.Equal(t, models.DefaultTenant, key.Tenant)
+
+	_, ok = k.Authenticate(secret + "x")
+	require.False(t, ok)
+	_, ok = k.Authenticate("")
+	require.False(t, ok)
+}
+
+func TestKeyringAdd(t *testing.T) {
+	k := NewKeyring()
+	_, err := k.Add("a", "", secret, []Scope{ScopeAdmin})
+	require.NoError(t, err)
+
+	_, err = k.Add("a", "", secret+"2", []Scope{ScopeRead})
+	require.ErrorIs(t, err, ErrKeyExists)
+	// a shared secret would authenticate as either key
+	_, err = k.Add("b", "acme", secret, []Scope{ScopeRead})
+	require.ErrorIs(t, err, ErrKeyExists)
+	require.Len(t, k.List(), 1)
+	_, err = k.Add("b", "", "short", []Scope{ScopeRead})
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = k.Add("b", "", secret, nil)
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = k.Add("b", "", secret, []Scope{"root"})
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = k.Add("", "", secret, []Scope{ScopeRead})
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestKeyringGenerateAndRemove(t *testing.T) {
+	k := NewKeyring()
+	key, generated, err := k.Generate("", "acme", []Scope{ScopeSubscriptions})
+	require.NoError(t, err)
+	require.NotEmpty(t, key.ID)
+	require.Equal(t, "acme", key.Tenant)
+	require.True(t, strings.HasPrefix(generated, secretPrefix))
+
+	found, ok := k.Authenticate(generated)
+	require.True(t, ok)
+	require.Equal(t, key.ID, found.ID)
+	require.Len(t, k.List(), 1)
+
+	require.NoError(t, k.Remove(key.ID))
+	require.ErrorIs(t, k.Remove(key.ID), ErrKeyNotFound)
+	_, ok = k.Authenticate(generated)
+	require.False(t, ok)
+}
+
+func TestAllows(t *testing.T) {
+	reader := Key{Scopes: []Scope{ScopeRead}}
+	require.True(t, reader.Allows(ScopeRead))
+	require.False(t, reader.Allows(ScopeSubscriptions))
+	require.False(t, reader.Allows(ScopeAdmin))
+
+	admin := Key{Scopes: []Scope{ScopeAdmin}}
+	require.True(t, admin.Allows(ScopeRead))
+	require.True(t, admin.Allows(ScopeSubscriptions))
+	require.True(t, admin.Allows(ScopeMetrics))
+
+	scraper := Key{Scopes: []Scope{ScopeMetrics}}
+	require.True(t, scraper.Allows(ScopeMetrics))
+	require.False(t, scraper.Allows(ScopeRead))
+}
+
+func TestAuthorize(t *testing.T) {
+	k := NewKeyring()
+	_, err := k.Add("reader", "", secret, []Scope{ScopeRead})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	_, err = k.Authorize(r, ScopeRead)
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	r.Header.Set(Header, secret)
+	key, err := k.Authorize(r, ScopeRead)
+	require.NoError(t, err)
+	require.Equal(t, "reader", key.ID)
+
+	_, err = k.Authorize(r, ScopeSubscriptions)
+	require.ErrorIs(t, err, ErrForbidden)
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+secret)
+	_, err = k.Authorize(r, ScopeRead)
+	require.NoError(t, err)
+}
+
+func TestCredentialFrom(t *testing.T) {
+	// gRPC metadata keys are lower case
+	md := map[string]string{"x-api-key": secret}
+	get := func(name string) string { return md[strings.ToLower(name)] }
+	require.Equal(t, secret, CredentialFrom(get))
+
+	md = map[string]string{"authorization": "Bearer " + secret}
+	require.Equal(t, secret, CredentialFrom(get))
+
+	md = map[string]string{"authorization": "Basic " + secret}
+	require.Empty(t, CredentialFrom(get))
+}
+
+func TestTenant(t *testing.T) {
+	ctx := t.Context()
+	require.Equal(t, models.DefaultTenant, Tenant(ctx))
+	require.Equal(t, "acme", Tenant(WithKey(ctx, Key{ID: "a", Tenant: "acme"})))
+}
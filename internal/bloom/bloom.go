@@ -0,0 +1,71 @@
+package bloom
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// Filter - probabilistic set membership: Test never misses an added key but may report keys that were never added.
+// Add and Test are safe for concurrent use and lock free.
+type Filter struct {
+	bits  []atomic.Uint64
+	m     uint64
+	k     uint64
+	seed  maphash.Seed
+	count atomic.Int64
+}
+
+const minBits = 64
+
+// New - filter sized for n keys at false positive rate p
+func New(n int, p float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max(m, minBits)
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	k = max(k, 1)
+
+	return &Filter{
+		bits: make([]atomic.Uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+		seed: maphash.MakeSeed(),
+	}
+}
+
+func (f *Filter) Add(key string) {
+	h1, h2 := f.hash(key)
+	for i := range f.k {
+		pos := (h1 + i*h2) % f.m
+		f.bits[pos/64].Or(1 << (pos % 64))
+	}
+	f.count.Add(1)
+}
+
+func (f *Filter) Test(key string) bool {
+	h1, h2 := f.hash(key)
+	for i := range f.k {
+		pos := (h1 + i*h2) % f.m
+		if f.bits[pos/64].Load()&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count - number of Add calls, duplicates included
+func (f *Filter) Count() int {
+	return int(f.count.Load())
+}
+
+// hash - two independent hashes combined as h1 + i*h2 (Kirsch-Mitzenmacher)
+func (f *Filter) hash(key string) (uint64, uint64) {
+	h := maphash.String(f.seed, key)
+	return h & math.MaxUint32, h>>32 | 1
+}
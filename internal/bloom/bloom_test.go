@@ -0,0 +1,36 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterNoFalseNegatives(t *testing.T) {
+	f := New(10_000, 0.01)
+	for i := range 10_000 {
+		f.Add(strconv.Itoa(i))
+	}
+	for i := range 10_000 {
+		require.True(t, f.Test(strconv.Itoa(i)))
+	}
+	require.Equal(t, 10_000, f.Count())
+}
+
+func TestFilterFalsePositiveRate(t *testing.T) {
+	const n = 10_000
+	f := New(n, 0.01)
+	for i := range n {
+		f.Add(strconv.Itoa(i))
+	}
+
+	falsePositives := 0
+	for i := n; i < 2*n; i++ {
+		if f.Test(strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	// generous bound so the test is not flaky across hash seeds
+	require.Less(t, float64(falsePositives)/n, 0.03)
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+// blockFileExts - files of a directory source holding blocks, others are ignored
+var blockFileExts = []string{".json", ".jsonl", ".ndjson"}
+
+var ErrBlockNotFound = errors.New("block not found")
+
+// FileClient - Client over archived eth_getBlockByNumber results, read from disk instead of a node.
+// Only the position of each block is kept in memory, a block is read when it is fetched.
+type FileClient struct {
+	source string
+	blocks map[int]blockLocation
+	first  int
+	last   int
+}
+
+type blockLocation struct {
+	path   string
+	offset int64
+}
+
+// fileBlock - a block as returned by eth_getBlockByNumber with full transactions
+type fileBlock struct {
+	Number       string               `json:"number"`
+	Transactions []models.Transaction `json:"transactions"`
+}
+
+// blockValue - a JSON-RPC response carrying the block as its result, or the bare block
+type blockValue struct {
+	Result *fileBlock `json:"result"`
+	fileBlock
+}
+
+func (v *blockValue) block() *fileBlock {
+	if v.Result != nil {
+		return v.Result
+	}
+	return &v.fileBlock
+}
+
+// blockHeader, headerValue - the number of a block, indexing skips its transactions
+type blockHeader struct {
+	Number *string `json:"number"`
+}
+
+type headerValue struct {
+	Result *blockHeader `json:"result"`
+	blockHeader
+}
+
+func (v *headerValue) block() *blockHeader {
+	if v.Result != nil {
+		return v.Result
+	}
+	return &v.blockHeader
+}
+
+// NewFileClient - indexes the blocks of path, a file or a directory of .json, .jsonl and .ndjson files.
+// A file holds one or more blocks, pretty printed or one per line, each either an eth_getBlockByNumber
+// JSON-RPC response or its result. Responses without a block are skipped, a block found twice is an error.
+func NewFileClient(path string) (*FileClient, error) {
+	files, err := blockFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &FileClient{
+		source: path,
+		blocks: make(map[int]blockLocation),
+	}
+	for _, file := range files {
+		if err := c.index(file); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.blocks) == 0 {
+		return nil, fmt.Errorf("%s: no blocks", path)
+	}
+
+	return c, nil
+}
+
+func blockFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && slices.Contains(blockFileExts, strings.ToLower(filepath.Ext(entry.Name()))) {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// index - records where each block of file starts, without decoding its transactions
+func (c *FileClient) index(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		offset := dec.InputOffset()
+		var value headerValue
+		err := dec.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s at byte %d: %w", file, offset, err)
+		}
+		if value.block().Number == nil {
+			// a response for a block the node did not have
+			continue
+		}
+		number, err := helpers.ParseHexInt(*value.block().Number)
+		if err != nil {
+			return fmt.Errorf("%s at byte %d: block number: %w", file, offset, err)
+		}
+		if prev, ok := c.blocks[number]; ok {
+			return fmt.Errorf("%s at byte %d: block %d is in %s already", file, offset, number, prev.path)
+		}
+		c.blocks[number] = blockLocation{path: file, offset: offset}
+		if len(c.blocks) == 1 || number < c.first {
+			c.first = number
+		}
+		c.last = max(c.last, number)
+	}
+}
+
+// Blocks - lowest and highest block in the files, there may be gaps between them
+func (c *FileClient) Blocks() (first, last int) {
+	return c.first, c.last
+}
+
+// GetBlockNumber - the highest block in the files
+func (c *FileClient) GetBlockNumber(ctx context.Context) (int, error) {
+	return c.last, nil
+}
+
+func (c *FileClient) GetTxsFromBlock(ctx context.Context, number int) ([]models.Transaction, error) {
+	location, ok := c.blocks[number]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d in %s", ErrBlockNotFound, number, c.source)
+	}
+	f, err := os.Open(location.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(location.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var value blockValue
+	if err := json.NewDecoder(f).Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s block %d: %w", location.path, number, err)
+	}
+
+	return value.block().Transactions, nil
+}
+
+func (c *FileClient) Endpoint() string {
+	return "file://" + c.source
+}
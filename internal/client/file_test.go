@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestFileClient(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// JSON-RPC responses one per line, a bare block pretty printed, a response without a block
+	writeFile(t, filepath.Join(dir, "a.jsonl"), `{"jsonrpc":"2.0","id":1,"result":{"number":"0xa","transactions":[{"hash":"0x1","blockNumber":"0xa","from":"0xf","to":"0xt","transactionIndex":"0x0"}]}}
+{"jsonrpc":"2.0","id":2,"result":null}
+{"jsonrpc":"2.0","id":3,"result":{"number":"0xc","transactions":[]}}
+`)
+	writeFile(t, filepath.Join(dir, "b.json"), `{
+  "number": "0xb",
+  "transactions": [
+    {"hash": "0x2", "blockNumber": "0xb", "from": "0xf", "to": "0xt", "transactionIndex": "0x0"},
+    {"hash": "0x3", "blockNumber": "0xb", "from": "0xf", "to": "0xt", "transactionIndex": "0x1"}
+  ]
+}`)
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not blocks")
+
+	c, err := NewFileClient(dir)
+	require.NoError(t, err)
+	first, last := c.Blocks()
+	assert.Equal(t, 10, first)
+	assert.Equal(t, 12, last)
+	head, err := c.GetBlockNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 12, head)
+	assert.Equal(t, "file://"+dir, c.Endpoint())
+
+	txs, err := c.GetTxsFromBlock(ctx, 11)
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, "0x3", txs[1].Hash)
+	txs, err = c.GetTxsFromBlock(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, "0x1", txs[0].Hash)
+	txs, err = c.GetTxsFromBlock(ctx, 12)
+	require.NoError(t, err)
+	assert.Empty(t, txs)
+
+	_, err = c.GetTxsFromBlock(ctx, 13)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+}
+
+func TestNewFileClient_Errors(t *testing.T) {
+	dir := t.TempDir()
+	block := `{"number":"0x1","transactions":[]}` + "\n"
+	for name, files := range map[string]map[string]string{
+		"no blocks":    {"a.jsonl": `{"result":null}`},
+		"duplicate":    {"a.jsonl": block, "b.jsonl": block},
+		"bad json":     {"a.jsonl": block + `{"number":`},
+		"bad number":   {"a.jsonl": `{"number":"12"}`},
+		"empty folder": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.Mkdir(path, 0o700))
+			for file, content := range files {
+				writeFile(t, filepath.Join(path, file), content)
+			}
+			_, err := NewFileClient(path)
+			require.Error(t, err)
+		})
+	}
+
+	_, err := NewFileClient(filepath.Join(dir, "missing.jsonl"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -5,20 +5,30 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/logging"
 	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client interface {
 	GetBlockNumber(ctx context.Context) (int, error)
 	GetTxsFromBlock(ctx context.Context, blockNumber int) ([]models.Transaction, error)
+	// Endpoint - address of the node, for diagnostics
+	Endpoint() string
 }
 
 const (
 	jsonRpcVersion = "2.0"
+	tracerName     = "github.com/galecic/ethereum_parser/internal/client"
 )
 
 type RPCRequest struct {
@@ -68,31 +78,86 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// Metrics - instrumentation of rpc calls
+type Metrics interface {
+	ObserveCall(method string, duration time.Duration, err error)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveCall(string, time.Duration, error) {}
+
 type JsonRpcClient struct {
 	endpoint           string
 	httpClient         HTTPClient
 	customHeaders      map[string]string
 	allowUnknownFields bool
 	defaultRequestID   int
+	metrics            Metrics
+	logger             *slog.Logger
+	tracer             trace.Tracer
+	propagator         propagation.TextMapPropagator
 }
 
 type RPCRequests []*RPCRequest
 
-func NewClient(endpoint string) Client {
+type Option func(c *JsonRpcClient)
+
+func WithLogger(l *slog.Logger) Option {
+	return func(c *JsonRpcClient) {
+		c.logger = l
+	}
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(c *JsonRpcClient) {
+		c.metrics = m
+	}
+}
+
+// WithHTTPClient - client the calls are sent with, a plain http.Client by default
+func WithHTTPClient(hc HTTPClient) Option {
+	return func(c *JsonRpcClient) {
+		c.httpClient = hc
+	}
+}
+
+// WithTracerProvider - spans for every rpc call, trace context is propagated to the node
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *JsonRpcClient) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+func NewClient(endpoint string, opts ...Option) Client {
 	JsonRpcClient := &JsonRpcClient{
 		endpoint:      endpoint,
 		httpClient:    &http.Client{},
 		customHeaders: make(map[string]string),
+		metrics:       noopMetrics{},
+		logger:        logging.Discard(),
+		tracer:        tracing.Noop().Tracer(tracerName),
+		propagator:    tracing.Propagator(),
+	}
+	for _, opt := range opts {
+		opt(JsonRpcClient)
 	}
 	return JsonRpcClient
 }
 
+func (c *JsonRpcClient) Endpoint() string {
+	return c.endpoint
+}
+
 func (c *JsonRpcClient) GetBlockNumber(ctx context.Context) (int, error) {
 	var numberHex string
 	rpcResponse, err := c.Call(ctx, "eth_blockNumber", &numberHex)
 	if err != nil {
 		return 0, err
 	}
+	if rpcResponse.Error != nil {
+		return 0, fmt.Errorf("eth_blockNumber: %w", rpcResponse.Error)
+	}
 	resultStr, ok := rpcResponse.Result.(string)
 	if !ok {
 		return 0, fmt.Errorf("failed converting rpcResponse to string")
@@ -120,6 +185,10 @@ func (c *JsonRpcClient) GetTxsFromBlock(ctx context.Context, number int) ([]mode
 	if err != nil {
 		return nil, err
 	}
+	// an error answer has no result, it must not read as an empty block
+	if rpcResponse.Error != nil {
+		return nil, fmt.Errorf("eth_getBlockByNumber %d: %w", number, rpcResponse.Error)
+	}
 
 	resultBytes, err := json.Marshal(rpcResponse.Result)
 	if err != nil {
@@ -146,19 +215,48 @@ func (client *JsonRpcClient) Call(ctx context.Context, method string, params ...
 	return client.doCall(ctx, request)
 }
 
-func (client *JsonRpcClient) doCall(ctx context.Context, RPCRequest *RPCRequest) (*RPCResponse, error) {
+func (client *JsonRpcClient) doCall(ctx context.Context, RPCRequest *RPCRequest) (rpcResponse *RPCResponse, err error) {
+	start := time.Now()
+	ctx, span := client.tracer.Start(ctx, "rpc "+RPCRequest.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			tracing.KeyRPCMethod.String(RPCRequest.Method),
+		),
+	)
+	defer func() {
+		callErr := err
+		if callErr == nil && rpcResponse != nil && rpcResponse.Error != nil {
+			callErr = rpcResponse.Error
+		}
+		duration := time.Since(start)
+		client.metrics.ObserveCall(RPCRequest.Method, duration, callErr)
+
+		tracing.End(span, callErr)
+
+		logger := client.logger.With(
+			slog.String(logging.KeyRPCMethod, RPCRequest.Method),
+			slog.Duration("duration", duration),
+		)
+		if callErr != nil {
+			logger.WarnContext(ctx, "rpc call failed", logging.Err(callErr))
+			return
+		}
+		logger.DebugContext(ctx, "rpc call")
+	}()
 
 	httpRequest, err := client.newRequest(ctx, RPCRequest)
 	if err != nil {
 		return nil, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, client.endpoint, err)
 	}
+	span.SetAttributes(attribute.String("url.full", httpRequest.URL.Redacted()))
 	httpResponse, err := client.httpClient.Do(httpRequest)
 	if err != nil {
 		return nil, fmt.Errorf("rpc call %v() on %v: %w", RPCRequest.Method, httpRequest.URL.Redacted(), err)
 	}
 	defer httpResponse.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", httpResponse.StatusCode))
 
-	var rpcResponse *RPCResponse
 	decoder := json.NewDecoder(httpResponse.Body)
 	if !client.allowUnknownFields {
 		decoder.DisallowUnknownFields()
@@ -216,6 +314,7 @@ func (client *JsonRpcClient) newRequest(ctx context.Context, req interface{}) (*
 
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Accept", "application/json")
+	client.propagator.Inject(ctx, propagation.HeaderCarrier(request.Header))
 
 	for k, v := range client.customHeaders {
 		if k == "Host" {
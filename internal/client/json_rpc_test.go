@@ -2,18 +2,59 @@ package client
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	ethAddr = "https://ethereum-rpc.publicnode.com"
 )
 
+var record = flag.Bool("record", false, "call "+ethAddr+" and save the calls as the fixtures of testdata")
+
+// fixtureClient - a client answered from testdata/<test name>.json, with -record a client of the node that saves its calls there
+func fixtureClient(t *testing.T) Client {
+	t.Helper()
+	path := filepath.Join("testdata", t.Name()+".json")
+	if *record {
+		recorder := NewRecorder(&http.Client{}, path)
+		t.Cleanup(func() {
+			// a failed run keeps the fixture it would have replaced
+			if !t.Failed() {
+				require.NoError(t, recorder.Save())
+			}
+		})
+		return NewClient(ethAddr, WithHTTPClient(recorder))
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("no fixture %s, record it with go test -run '^%s$' -record", path, t.Name())
+	}
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.Zero(t, replayer.Unused(), "recorded calls not made, re-record the fixture")
+	})
+	return NewClient(ethAddr, WithHTTPClient(replayer))
+}
+
 func TestGetBlockNumber(t *testing.T) {
 	ctx := context.Background()
-	client := NewClient(ethAddr)
+	client := fixtureClient(t)
 
 	number, err := client.GetBlockNumber(ctx)
 	require.NoError(t, err)
@@ -24,13 +65,87 @@ func TestGetBlockNumber(t *testing.T) {
 
 func TestGetTxsFromBlock(t *testing.T) {
 	ctx := context.Background()
-	client := NewClient(ethAddr)
+	client := fixtureClient(t)
 
 	number, err := client.GetBlockNumber(ctx)
 	require.NoError(t, err)
 	txs, err := client.GetTxsFromBlock(ctx, number)
 	require.NoError(t, err)
 
-	require.NotNil(t, txs)
+	require.NotEmpty(t, txs)
+	for _, tx := range txs {
+		require.Equal(t, helpers.FormatHexInt(number), tx.BlockNumber)
+		require.NotEmpty(t, tx.Hash)
+	}
 	t.Log(len(txs))
 }
+
+func TestCallTracing(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":"0x10"}`))
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := NewClient(server.URL, WithTracerProvider(tp))
+
+	number, err := client.GetBlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 16, number)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "rpc eth_blockNumber", span.Name)
+	require.Equal(t, trace.SpanKindClient, span.SpanKind)
+	require.Equal(t, codes.Unset, span.Status.Code)
+	require.Contains(t, traceparent, span.SpanContext.TraceID().String())
+	require.Contains(t, traceparent, span.SpanContext.SpanID().String())
+}
+
+func TestCallTracingError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"error":{"code":-32000,"message":"header not found"}}`))
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := NewClient(server.URL, WithTracerProvider(tp))
+
+	_, err := client.GetBlockNumber(context.Background())
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "rpc eth_blockNumber", spans[0].Name)
+	require.Equal(t, codes.Error, spans[0].Status.Code)
+}
+
+func TestRPCErrorAnswers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"error":{"code":-32000,"message":"header not found"}}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client := NewClient(server.URL)
+
+	_, err := client.GetBlockNumber(ctx)
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -32000, rpcErr.Code)
+	assert.Contains(t, err.Error(), "eth_blockNumber")
+
+	// an error answer has no result, it must not read as a block without transactions
+	txs, err := client.GetTxsFromBlock(ctx, 20)
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, "header not found", rpcErr.Message)
+	assert.Contains(t, err.Error(), "eth_getBlockByNumber 20")
+	assert.Nil(t, txs)
+}
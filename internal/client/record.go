@@ -0,0 +1,219 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrNotRecorded = errors.New("no recorded response")
+
+// Interaction - a request and the response it got, as stored in a fixture file
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	// URL - scheme and host only, node URLs carry API keys in their path, query or userinfo.
+	// Requests are matched on method and body only.
+	URL  string  `json:"url"`
+	Body rawBody `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode  int     `json:"statusCode"`
+	ContentType string  `json:"contentType,omitempty"`
+	Body        rawBody `json:"body,omitempty"`
+}
+
+// rawBody - a body stored as JSON when it is JSON, so fixtures stay readable, as a string otherwise.
+// A body that is a bare JSON string comes back without its quotes, JSON-RPC bodies are objects or arrays.
+type rawBody []byte
+
+func (b rawBody) MarshalJSON() ([]byte, error) {
+	if json.Valid(b) {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, b); err != nil {
+			return nil, err
+		}
+		return compact.Bytes(), nil
+	}
+	return json.Marshal(string(b))
+}
+
+func (b *rawBody) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = rawBody(s)
+		return nil
+	}
+	*b = bytes.Clone(data)
+	return nil
+}
+
+// equal - same JSON values ignoring formatting, same bytes for anything else
+func (b rawBody) equal(other rawBody) bool {
+	var x, y bytes.Buffer
+	if json.Compact(&x, b) == nil && json.Compact(&y, other) == nil {
+		return bytes.Equal(x.Bytes(), y.Bytes())
+	}
+	return bytes.Equal(b, other)
+}
+
+// Recorder - HTTPClient passing calls to next and keeping them, Save writes them to a fixture file for a Replayer
+type Recorder struct {
+	next         HTTPClient
+	path         string
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+func NewRecorder(next HTTPClient, path string) *Recorder {
+	return &Recorder{
+		next: next,
+		path: path,
+	}
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    (&url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host}).String(),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        respBody,
+		},
+	})
+
+	return resp, nil
+}
+
+// Save - writes the calls recorded so far to the fixture file, replacing it
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// Replayer - HTTPClient answering from a fixture file saved by a Recorder, without a network.
+// A request gets the response of the first unused interaction with the same method and body,
+// so a request made twice gets the responses in the order they were recorded.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Replayer{
+		interactions: interactions,
+		used:         make([]bool, len(interactions)),
+	}, nil
+}
+
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || !interaction.Request.Body.equal(body) {
+			continue
+		}
+		r.used[i] = true
+		recorded := interaction.Response
+		header := http.Header{}
+		if recorded.ContentType != "" {
+			header.Set("Content-Type", recorded.ContentType)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w for %s %s", ErrNotRecorded, req.Method, body)
+}
+
+// Unused - number of recorded interactions no request asked for yet
+func (r *Replayer) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// readBody - reads *body and replaces it with a reader of the same bytes
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	_ = (*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+
+	return data, nil
+}
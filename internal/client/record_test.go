@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	var head atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Fail") != "" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("rate limited"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":"` + helpers.FormatHexInt(int(head.Add(1))) + `"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "fixtures", "calls.json")
+	recorder := NewRecorder(server.Client(), path)
+	client := NewClient(server.URL, WithHTTPClient(recorder))
+	for want := 1; want <= 2; want++ {
+		number, err := client.GetBlockNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, want, number)
+	}
+	failing := NewClient(server.URL, WithHTTPClient(recorder)).(*JsonRpcClient)
+	failing.customHeaders["X-Fail"] = "1"
+	_, err := failing.Call(ctx, "eth_chainId")
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.NoError(t, recorder.Save())
+
+	// served back in the recorded order without the server
+	server.Close()
+	replayer, err := NewReplayer(path)
+	require.NoError(t, err)
+	client = NewClient("http://node.invalid", WithHTTPClient(replayer))
+	for want := 1; want <= 2; want++ {
+		number, err := client.GetBlockNumber(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, number)
+	}
+	assert.Equal(t, 1, replayer.Unused())
+	_, err = client.(*JsonRpcClient).Call(ctx, "eth_chainId")
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.Code)
+	assert.Zero(t, replayer.Unused())
+
+	_, err = client.GetBlockNumber(ctx)
+	require.ErrorIs(t, err, ErrNotRecorded)
+	_, err = client.GetTxsFromBlock(ctx, 1)
+	require.ErrorIs(t, err, ErrNotRecorded)
+}
+
+func TestRecorderDropsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":"0x1"}`))
+	}))
+	defer server.Close()
+
+	// providers take the API key in the path, the query or the userinfo
+	endpoint, err := url.Parse(server.URL + "/v3/path-secret?apikey=query-secret")
+	require.NoError(t, err)
+	endpoint.User = url.UserPassword("user-secret", "password-secret")
+	path := filepath.Join(t.TempDir(), "calls.json")
+	recorder := NewRecorder(server.Client(), path)
+	_, err = NewClient(endpoint.String(), WithHTTPClient(recorder)).GetBlockNumber(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, recorder.Save())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"url": "`+server.URL+`"`)
+}
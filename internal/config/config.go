@@ -0,0 +1,418 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/auth"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix - environment overrides are EnvPrefix + the upper cased flag name
+	EnvPrefix  = "ETHPARSER_"
+	configFlag = "config"
+
+	minInterval = 100 * time.Millisecond
+	maxInterval = time.Hour
+	maxWorkers  = 1024
+)
+
+// Config - every setting of the service. Precedence, lowest first: defaults, config file,
+// ETHPARSER_* environment variables, command line flags. The packages it configures keep their
+// own settings types and options, main maps the sections onto them.
+type Config struct {
+	Server        Server        `yaml:"server"`
+	Node          Node          `yaml:"node"`
+	Parser        Parser        `yaml:"parser"`
+	Store         Store         `yaml:"store"`
+	Subscriptions Subscriptions `yaml:"subscriptions"`
+	Auth          Auth          `yaml:"auth"`
+	Log           Log           `yaml:"log"`
+	Tracing       Tracing       `yaml:"tracing"`
+
+	// File - config file the values were read from, empty without one
+	File string `yaml:"-"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr"`
+	// GRPCAddr - address of the gRPC API, empty disables it
+	GRPCAddr string `yaml:"grpc_addr"`
+	// ReadyMaxLag - blocks behind the node after which readiness fails
+	ReadyMaxLag int `yaml:"ready_max_lag"`
+	// ReadyMaxStaleTicks - fetch intervals without a successful tick after which readiness fails, 0 disables the check
+	ReadyMaxStaleTicks int           `yaml:"ready_max_stale_ticks"`
+	ReadTimeout        time.Duration `yaml:"read_timeout"`
+	WriteTimeout       time.Duration `yaml:"write_timeout"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout"`
+	// MaxBodyBytes - larger request bodies are rejected with 413
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// RateLimit - requests per second per API key, or client IP without one, 0 disables limiting
+	RateLimit float64 `yaml:"rate_limit"`
+	// RateBurst - requests a client may make at once before RateLimit applies
+	RateBurst int `yaml:"rate_burst"`
+	// WSOrigins - origins, as scheme://host[:port], of the pages besides the API's own allowed to open a WebSocket
+	WSOrigins []string `yaml:"ws_origins"`
+}
+
+type Node struct {
+	// Endpoint - JSON-RPC url of the Ethereum node
+	Endpoint string `yaml:"endpoint"`
+}
+
+type Parser struct {
+	Interval time.Duration `yaml:"interval"`
+	Workers  int           `yaml:"workers"`
+	// ExpectedSubscribers - initial capacity of the subscription index
+	ExpectedSubscribers int `yaml:"expected_subscribers"`
+}
+
+type Store struct {
+	// Shards - 0 keeps a single-lock store
+	Shards int `yaml:"shards"`
+}
+
+type Subscriptions struct {
+	// Watchlist - file of addresses to subscribe, one per line
+	Watchlist string `yaml:"watchlist"`
+}
+
+type Auth struct {
+	// Enabled - require an API key on every route but the health checks
+	Enabled bool     `yaml:"enabled"`
+	Keys    []APIKey `yaml:"keys"`
+	// AuditLog - file mutating calls are appended to, the service log when empty
+	AuditLog string `yaml:"audit_log"`
+}
+
+type APIKey struct {
+	ID string `yaml:"id"`
+	// Tenant - owner of the subscriptions made with the key, the default tenant when empty
+	Tenant string   `yaml:"tenant"`
+	Key    string   `yaml:"key"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// Keyring - keyring holding the configured keys
+func (a Auth) Keyring() (*auth.Keyring, error) {
+	keyring := auth.NewKeyring()
+	var errs []error
+	for i, k := range a.Keys {
+		scopes := make([]auth.Scope, 0, len(k.Scopes))
+		for _, s := range k.Scopes {
+			scopes = append(scopes, auth.Scope(s))
+		}
+		if _, err := keyring.Add(k.ID, k.Tenant, k.Key, scopes); err != nil {
+			errs = append(errs, fmt.Errorf("auth.keys[%d]: %w", i, err))
+		}
+	}
+
+	return keyring, errors.Join(errs...)
+}
+
+type Log struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+type Tracing struct {
+	Exporter     string `yaml:"exporter"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	OTLPInsecure bool   `yaml:"otlp_insecure"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:               "localhost:8000",
+			GRPCAddr:           "localhost:9000",
+			ReadyMaxLag:        10,
+			ReadyMaxStaleTicks: 3,
+			ReadTimeout:        10 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        2 * time.Minute,
+			MaxBodyBytes:       1 << 20,
+			RateLimit:          20,
+			RateBurst:          40,
+		},
+		Node: Node{
+			Endpoint: "https://ethereum-rpc.publicnode.com",
+		},
+		Parser: Parser{
+			Interval:            5 * time.Second,
+			Workers:             10,
+			ExpectedSubscribers: 1 << 16,
+		},
+		Log: Log{
+			Format: logging.FormatText,
+			Level:  "info",
+		},
+		Tracing: Tracing{
+			Exporter: tracing.ExporterNone,
+		},
+	}
+}
+
+// deprecatedFlags - old flag names kept working, mapped to their replacement
+var deprecatedFlags = map[string]string{
+	"serverAddr":       "server_addr",
+	"eth_publich_node": "eth_public_node",
+}
+
+// bind - registers every setting on fs, flag values are written to cfg
+func bind(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Server.Addr, "server_addr", cfg.Server.Addr, "server address")
+	fs.StringVar(&cfg.Server.GRPCAddr, "grpc_addr", cfg.Server.GRPCAddr, "gRPC server address, empty disables gRPC")
+	fs.IntVar(&cfg.Server.ReadyMaxLag, "ready_max_lag", cfg.Server.ReadyMaxLag, "blocks behind the node after which readiness fails")
+	fs.IntVar(&cfg.Server.ReadyMaxStaleTicks, "ready_max_stale_ticks", cfg.Server.ReadyMaxStaleTicks, "fetch intervals without a successful tick after which readiness fails, 0 disables the check")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "read_timeout", cfg.Server.ReadTimeout, "maximum duration for reading a request, 0 for none")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "write_timeout", cfg.Server.WriteTimeout, "maximum duration for writing a response, 0 for none")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "idle_timeout", cfg.Server.IdleTimeout, "how long idle keep-alive connections stay open, 0 for the read timeout")
+	fs.Int64Var(&cfg.Server.MaxBodyBytes, "max_body_bytes", cfg.Server.MaxBodyBytes, "maximum request body size")
+	fs.Float64Var(&cfg.Server.RateLimit, "rate_limit", cfg.Server.RateLimit, "requests per second per API key or client IP, 0 disables limiting")
+	fs.IntVar(&cfg.Server.RateBurst, "rate_burst", cfg.Server.RateBurst, "requests a client may burst above the rate limit")
+	fs.Var((*listValue)(&cfg.Server.WSOrigins), "ws_origins", "comma separated origins of the pages besides the API's own allowed to open a WebSocket")
+	fs.StringVar(&cfg.Node.Endpoint, "eth_public_node", cfg.Node.Endpoint, "JSON-RPC url of the Ethereum node")
+	fs.DurationVar(&cfg.Parser.Interval, "period", cfg.Parser.Interval, "fetch transactions period")
+	fs.IntVar(&cfg.Parser.Workers, "threads", cfg.Parser.Workers, "number of coroutines for parsing transactions")
+	fs.IntVar(&cfg.Parser.ExpectedSubscribers, "expected_subscribers", cfg.Parser.ExpectedSubscribers, "initial capacity of the subscription index")
+	fs.IntVar(&cfg.Store.Shards, "store_shards", cfg.Store.Shards, "number of data store shards, 0 keeps a single-lock store")
+	fs.StringVar(&cfg.Subscriptions.Watchlist, "watchlist", cfg.Subscriptions.Watchlist, "file of addresses to subscribe, one per line, reloaded on change")
+	fs.BoolVar(&cfg.Auth.Enabled, "auth_enabled", cfg.Auth.Enabled, "require API keys, keys are listed in the config file")
+	fs.StringVar(&cfg.Auth.AuditLog, "audit_log", cfg.Auth.AuditLog, "file mutating calls are appended to, the service log when empty")
+	fs.StringVar(&cfg.Log.Format, "log_format", cfg.Log.Format, "log format, text or json")
+	fs.StringVar(&cfg.Log.Level, "log_level", cfg.Log.Level, "log level, debug, info, warn or error")
+	fs.StringVar(&cfg.Tracing.Exporter, "trace_exporter", cfg.Tracing.Exporter, "trace exporter, none, stdout or otlp")
+	fs.StringVar(&cfg.Tracing.OTLPEndpoint, "otlp_endpoint", cfg.Tracing.OTLPEndpoint, "host:port of the OTLP/HTTP trace collector, exporter default when empty")
+	fs.BoolVar(&cfg.Tracing.OTLPInsecure, "otlp_insecure", cfg.Tracing.OTLPInsecure, "send traces to the collector over plain HTTP")
+
+	for old, name := range deprecatedFlags {
+		fs.Var(fs.Lookup(name).Value, old, "deprecated, use -"+name)
+	}
+}
+
+// flags - the command line of Load, bound to a copy of the defaults so -h lists them
+func flags(name string, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, configFlag, "", "path of a YAML or JSON config file, env "+EnvPrefix+"CONFIG")
+	defaults := Default()
+	bind(fs, &defaults)
+
+	return fs
+}
+
+// Load - builds the config from the file named by -config or ETHPARSER_CONFIG, the environment and args
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	// first pass only collects the flags that were set, they are applied last
+	var path string
+	fs := flags(name, &path)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if path == "" {
+		path, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+
+	cfg := Default()
+	cfg.File = path
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	target := flag.NewFlagSet(name, flag.ContinueOnError)
+	bind(target, &cfg)
+
+	var errs []error
+	target.VisitAll(func(f *flag.Flag) {
+		if _, ok := deprecatedFlags[f.Name]; ok {
+			return
+		}
+		env := EnvPrefix + strings.ToUpper(f.Name)
+		if v, ok := lookupEnv(env); ok {
+			if err := f.Value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: %w", env, v, err))
+			}
+		}
+	})
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == configFlag {
+			return
+		}
+		// values were validated by the first pass
+		_ = target.Set(f.Name, f.Value.String())
+	})
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// listValue - a flag of comma separated strings, each use replaces the list
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	*l = nil
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+
+	return nil
+}
+
+// readFile - decodes a YAML file over cfg, JSON is accepted as a subset of YAML
+func readFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate - reports every invalid setting
+func (c Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must be set"))
+	}
+	if c.Server.GRPCAddr != "" && c.Server.GRPCAddr == c.Server.Addr {
+		errs = append(errs, fmt.Errorf("server.grpc_addr must differ from server.addr, both are %q", c.Server.Addr))
+	}
+	if c.Server.ReadyMaxLag < 0 {
+		errs = append(errs, fmt.Errorf("server.ready_max_lag must not be negative, got %d", c.Server.ReadyMaxLag))
+	}
+	if c.Server.ReadyMaxStaleTicks < 0 {
+		errs = append(errs, fmt.Errorf("server.ready_max_stale_ticks must not be negative, got %d", c.Server.ReadyMaxStaleTicks))
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server timeouts must not be negative, got read %s, write %s, idle %s",
+			c.Server.ReadTimeout, c.Server.WriteTimeout, c.Server.IdleTimeout))
+	}
+	if c.Server.MaxBodyBytes < 1 {
+		errs = append(errs, fmt.Errorf("server.max_body_bytes must be positive, got %d", c.Server.MaxBodyBytes))
+	}
+	if c.Server.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("server.rate_limit must not be negative, got %g", c.Server.RateLimit))
+	}
+	if c.Server.RateLimit > 0 && c.Server.RateBurst < 1 {
+		errs = append(errs, fmt.Errorf("server.rate_burst must be positive when rate limiting, got %d", c.Server.RateBurst))
+	}
+	for _, origin := range c.Server.WSOrigins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			errs = append(errs, fmt.Errorf("server.ws_origins must be http or https origins without a path, got %q", origin))
+		}
+	}
+	if u, err := url.Parse(c.Node.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("node.endpoint must be an http or https url, got %q", c.Node.Endpoint))
+	}
+	if c.Parser.Interval < minInterval || c.Parser.Interval > maxInterval {
+		errs = append(errs, fmt.Errorf("parser.interval must be between %s and %s, got %s", minInterval, maxInterval, c.Parser.Interval))
+	}
+	if c.Parser.Workers < 1 || c.Parser.Workers > maxWorkers {
+		errs = append(errs, fmt.Errorf("parser.workers must be between 1 and %d, got %d", maxWorkers, c.Parser.Workers))
+	}
+	if c.Parser.ExpectedSubscribers < 1 {
+		errs = append(errs, fmt.Errorf("parser.expected_subscribers must be positive, got %d", c.Parser.ExpectedSubscribers))
+	}
+	if c.Store.Shards < 0 {
+		errs = append(errs, fmt.Errorf("store.shards must not be negative, got %d", c.Store.Shards))
+	}
+	if c.Auth.Enabled && len(c.Auth.Keys) == 0 {
+		errs = append(errs, errors.New("auth.keys must not be empty when auth is enabled"))
+	}
+	if _, err := c.Auth.Keyring(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := logging.New(io.Discard, c.Log.Format, c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be %s, %s or %s, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogValue - effective config for the startup log, credentials in the node url are redacted
+func (c Config) LogValue() slog.Value {
+	endpoint := c.Node.Endpoint
+	if u, err := url.Parse(endpoint); err == nil {
+		endpoint = u.Redacted()
+	}
+
+	return slog.GroupValue(
+		slog.String("file", c.File),
+		slog.Group("server",
+			slog.String("addr", c.Server.Addr),
+			slog.String("grpc_addr", c.Server.GRPCAddr),
+			slog.Int("ready_max_lag", c.Server.ReadyMaxLag),
+			slog.Int("ready_max_stale_ticks", c.Server.ReadyMaxStaleTicks),
+			slog.String("read_timeout", c.Server.ReadTimeout.String()),
+			slog.String("write_timeout", c.Server.WriteTimeout.String()),
+			slog.String("idle_timeout", c.Server.IdleTimeout.String()),
+			slog.Int64("max_body_bytes", c.Server.MaxBodyBytes),
+			slog.Float64("rate_limit", c.Server.RateLimit),
+			slog.Int("rate_burst", c.Server.RateBurst),
+			slog.Any("ws_origins", c.Server.WSOrigins),
+		),
+		slog.Group("node",
+			slog.String("endpoint", endpoint),
+		),
+		slog.Group("parser",
+			slog.String("interval", c.Parser.Interval.String()),
+			slog.Int("workers", c.Parser.Workers),
+			slog.Int("expected_subscribers", c.Parser.ExpectedSubscribers),
+		),
+		slog.Group("store",
+			slog.Int("shards", c.Store.Shards),
+		),
+		slog.Group("subscriptions",
+			slog.String("watchlist", c.Subscriptions.Watchlist),
+		),
+		slog.Group("auth",
+			slog.Bool("enabled", c.Auth.Enabled),
+			slog.Int("keys", len(c.Auth.Keys)),
+			slog.String("audit_log", c.Auth.AuditLog),
+		),
+		slog.Group("log",
+			slog.String("format", c.Log.Format),
+			slog.String("level", c.Log.Level),
+		),
+		slog.Group("tracing",
+			slog.String("exporter", c.Tracing.Exporter),
+			slog.String("otlp_endpoint", c.Tracing.OTLPEndpoint),
+			slog.Bool("otlp_insecure", c.Tracing.OTLPInsecure),
+		),
+	)
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// liveKeys - settings a reload applies to the running service, every other change needs a restart
+var liveKeys = []string{
+	"node.endpoint",
+	"parser.interval",
+	"parser.workers",
+	"subscriptions.watchlist",
+}
+
+// Live - reports whether a reload applies key without a restart
+func Live(key string) bool {
+	return slices.Contains(liveKeys, key)
+}
+
+// Diff - dotted keys of the settings that differ between old and next, in declaration order
+func Diff(old, next Config) []string {
+	var changed []string
+	diffValues(reflect.ValueOf(old), reflect.ValueOf(next), "", &changed)
+	return changed
+}
+
+func diffValues(old, next reflect.Value, prefix string, changed *[]string) {
+	if old.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(old.Interface(), next.Interface()) {
+			*changed = append(*changed, prefix)
+		}
+		return
+	}
+	for i := range old.NumField() {
+		name, _, _ := strings.Cut(old.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "-" || name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		diffValues(old.Field(i), next.Field(i), name, changed)
+	}
+}
@@ -0,0 +1,27 @@
+package data_store_test
+
+import (
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/data_store/storetest"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+)
+
+func TestDBConformance(t *testing.T) {
+	storetest.Run(t, func() data_store.DataStore {
+		return data_store.NewDataStore()
+	})
+}
+
+func TestShardedDBConformance(t *testing.T) {
+	storetest.Run(t, func() data_store.DataStore {
+		return data_store.NewShardedDataStore(4)
+	})
+}
+
+func TestTracedDBConformance(t *testing.T) {
+	storetest.Run(t, func() data_store.DataStore {
+		return data_store.NewTracedDataStore(data_store.NewDataStore(), tracing.Noop())
+	})
+}
@@ -1,82 +1,212 @@
 package data_store
 
 import (
-	"log"
+	"context"
+	"errors"
+	"iter"
+	"log/slog"
+	"slices"
 	"sync"
-	"sync/atomic"
 
+	"github.com/galecic/ethereum_parser/internal/logging"
 	"github.com/galecic/ethereum_parser/internal/models"
 )
 
+// DataStore - address subscriptions and rule subscriptions are owned by tenants. A transaction of an
+// address is stored once and shared by every tenant watching the address when it was added.
 type DataStore interface {
-	GetCurrentBlock() int
-	SetCurrentBlock(currBlock int)
-	GetLastProcessedTxIndex() int
-	SetLastProcessedTxIndex(idx int)
-	AddSubscriber(addr models.Address)
-	AddTx(addr models.Address, tx models.Transaction)
-	AddressExists(addr models.Address) bool
-	GetTransactions(addr models.Address) []models.Transaction
+	GetCurrentBlock(ctx context.Context) int
+	SetCurrentBlock(ctx context.Context, currBlock int)
+	GetLastProcessedTxIndex(ctx context.Context) int
+	SetLastProcessedTxIndex(ctx context.Context, idx int)
+	// AddSubscriber - tenant starts watching addr, its history starts empty. False when tenant already watched addr.
+	AddSubscriber(ctx context.Context, tenant string, addr models.Address) bool
+	// RemoveSubscriber - tenant stops watching addr, the address and its transactions are dropped with the last tenant.
+	// False when tenant did not watch addr.
+	RemoveSubscriber(ctx context.Context, tenant string, addr models.Address) bool
+	// GetSubscribers - addresses watched by any tenant
+	GetSubscribers(ctx context.Context) []models.Address
+	// GetTenantSubscribers - addresses tenant watches, sorted
+	GetTenantSubscribers(ctx context.Context, tenant string) []models.Address
+	// AddTx - stores tx for a watched addr once per (address, tx hash, log index); reports whether it was new
+	AddTx(ctx context.Context, addr models.Address, tx models.Transaction) bool
+	// AddressExists - addr is watched by any tenant
+	AddressExists(ctx context.Context, addr models.Address) bool
+	// IsSubscriber - tenant watches addr
+	IsSubscriber(ctx context.Context, tenant string, addr models.Address) bool
+	// GetTransactions - transactions of addr added while tenant watched it, nil when it does not
+	GetTransactions(ctx context.Context, tenant string, addr models.Address) []models.Transaction
+	// AddSubscription - stores a rule subscription owned by sub.Tenant, fails with ErrSubscriptionExists on a taken ID
+	AddSubscription(ctx context.Context, sub models.Subscription) error
+	// RemoveSubscription - drops a rule subscription of tenant and its matches
+	RemoveSubscription(ctx context.Context, tenant, id string) error
+	GetSubscription(ctx context.Context, tenant, id string) (models.Subscription, error)
+	// GetSubscriptions - rule subscriptions of tenant sorted by ID
+	GetSubscriptions(ctx context.Context, tenant string) []models.Subscription
+	// AllSubscriptions - rule subscriptions of every tenant sorted by ID
+	AllSubscriptions(ctx context.Context) []models.Subscription
+	// AddMatch - stores tx for subscription id once per (tx hash, log index); reports whether it was new
+	AddMatch(ctx context.Context, id string, tx models.Transaction) bool
+	// GetMatches - matches of a subscription of tenant, ErrSubscriptionNotFound for another tenant's
+	GetMatches(ctx context.Context, tenant, id string) ([]models.Transaction, error)
+	// BatchGetMatches - matches of several subscriptions of tenant by ID in one read, unknown and other tenants' IDs are left out
+	BatchGetMatches(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction
+	// Scan - transactions selected by q as (address, transaction) pairs, ordered by address then by insertion.
+	// Transactions are read lazily, stopping the iteration or cancelling ctx ends the scan.
+	Scan(ctx context.Context, q TxQuery) iter.Seq2[models.Address, models.Transaction]
+	// PurgeAbove - drops the stored transactions and rule matches mined after block so the blocks can be parsed
+	// again, subscriptions stay. Returns the number of transactions and matches dropped.
+	PurgeAbove(ctx context.Context, block int) int
 }
 
+var (
+	ErrSubscriptionExists   = errors.New("subscription already exists")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+)
+
 type DB struct {
-	mu                    sync.RWMutex
-	txMap                 map[models.Address][]models.Transaction
-	lastProcessedBlock    atomic.Int64
-	lastProcessedTxsIndex atomic.Int64
+	mu      sync.RWMutex
+	records map[models.Address]*addressRecord
+	metrics Metrics
+	logger  *slog.Logger
+	checkpoint
+	subscriptionSet
 }
 
-func NewDataStore() DataStore {
+func NewDataStore(opts ...Option) DataStore {
+	o := newOptions(opts)
 	return &DB{
-		txMap: make(map[models.Address][]models.Transaction),
+		records:         make(map[models.Address]*addressRecord),
+		metrics:         o.metrics,
+		logger:          o.logger,
+		subscriptionSet: newSubscriptionSet(o.logger),
 	}
 }
 
-func (db *DB) AddSubscriber(addr models.Address) {
+func (db *DB) AddSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	if _, ok := db.txMap[addr]; ok {
-		return
+	record, ok := db.records[addr]
+	if !ok {
+		record = newAddressRecord()
+		db.records[addr] = record
+		db.metrics.AddSubscribers(1)
 	}
-	db.txMap[addr] = make([]models.Transaction, 0)
-	log.Println("Added Subscriber", addr)
+	if !record.watch(tenant) {
+		return false
+	}
+	db.logger.Info("added subscriber", slog.String(logging.KeyAddress, string(addr)), slog.String(logging.KeyTenant, tenant))
+
+	return true
 }
 
-func (ds *DB) AddressExists(addr models.Address) bool {
+func (ds *DB) RemoveSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	record, ok := ds.records[addr]
+	if !ok || !record.unwatch(tenant) {
+		return false
+	}
+	if len(record.tenants) == 0 {
+		delete(ds.records, addr)
+		ds.metrics.AddSubscribers(-1)
+	}
+	ds.logger.Info("removed subscriber", slog.String(logging.KeyAddress, string(addr)), slog.String(logging.KeyTenant, tenant))
+
+	return true
+}
+
+func (ds *DB) GetSubscribers(ctx context.Context) []models.Address {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	addrs := make([]models.Address, 0, len(ds.records))
+	for addr := range ds.records {
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+func (ds *DB) GetTenantSubscribers(ctx context.Context, tenant string) []models.Address {
 	ds.mu.RLock()
-	_, ok := ds.txMap[addr]
+	defer ds.mu.RUnlock()
+	addrs := make([]models.Address, 0)
+	for addr, record := range ds.records {
+		if record.watchedBy(tenant) {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.Sort(addrs)
+
+	return addrs
+}
+
+func (ds *DB) AddressExists(ctx context.Context, addr models.Address) bool {
+	ds.mu.RLock()
+	_, ok := ds.records[addr]
 	ds.mu.RUnlock()
 
 	return ok
 }
 
-func (ds *DB) AddTx(addr models.Address, tx models.Transaction) {
-	ds.mu.Lock()
-	defer ds.mu.Unlock()
+func (ds *DB) IsSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	record, ok := ds.records[addr]
 
-	ds.txMap[addr] = append(ds.txMap[addr], tx)
+	return ok && record.watchedBy(tenant)
 }
 
-func (ds *DB) GetTransactions(addr models.Address) []models.Transaction {
+func (ds *DB) AddTx(ctx context.Context, addr models.Address, tx models.Transaction) bool {
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
-	txs, ok := ds.txMap[addr]
+	record, ok := ds.records[addr]
+	if !ok {
+		return false
+	}
+
+	return record.add(tx)
+}
+
+// GetTransactions - returns a copy, appends by AddTx never show up in it
+func (ds *DB) GetTransactions(ctx context.Context, tenant string, addr models.Address) []models.Transaction {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	record, ok := ds.records[addr]
 	if !ok {
 		return nil
 	}
 
-	return txs
+	return record.visible(tenant)
 }
 
-func (ds *DB) GetCurrentBlock() int {
-	return int(ds.lastProcessedBlock.Load())
+func (ds *DB) PurgeAbove(ctx context.Context, block int) int {
+	ds.mu.Lock()
+	purged := 0
+	for _, record := range ds.records {
+		purged += record.purgeAbove(block)
+	}
+	ds.mu.Unlock()
+
+	return purged + ds.purgeMatchesAbove(block)
 }
-func (ds *DB) GetLastProcessedTxIndex() int {
-	return int(ds.lastProcessedTxsIndex.Load())
+
+func (ds *DB) Scan(ctx context.Context, q TxQuery) iter.Seq2[models.Address, models.Transaction] {
+	return scan(ctx, q, ds.watchedBy, ds.page)
 }
-func (ds *DB) SetCurrentBlock(currBlock int) {
-	ds.lastProcessedBlock.Store(int64(currBlock))
+
+func (ds *DB) watchedBy(tenant string) []models.Address {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return appendWatchedBy(make([]models.Address, 0), ds.records, tenant)
 }
-func (ds *DB) SetLastProcessedTxIndex(currIndex int) {
-	ds.lastProcessedTxsIndex.Store(int64(currIndex))
+
+func (ds *DB) page(addr models.Address, tenant string, after uint64) ([]models.Transaction, uint64, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	record, ok := ds.records[addr]
+	if !ok {
+		return nil, after, false
+	}
+	return record.page(tenant, after)
 }
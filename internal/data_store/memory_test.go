@@ -1,6 +1,7 @@
 package data_store
 
 import (
+	"context"
 	"slices"
 	"testing"
 
@@ -9,26 +10,47 @@ import (
 )
 
 func TestAddressExists(t *testing.T) {
+	ctx := context.Background()
 	db := NewDataStore()
 
 	addr := models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
-	db.AddSubscriber(addr)
+	db.AddSubscriber(ctx, models.DefaultTenant, addr)
 
-	require.True(t, db.AddressExists(addr))
+	require.True(t, db.AddressExists(ctx, addr))
 
 }
 
 func TestGetTransactions(t *testing.T) {
+	ctx := context.Background()
 	db := NewDataStore()
 
 	addr := models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
-	db.AddTx(addr, models.Transaction{
+	db.AddSubscriber(ctx, models.DefaultTenant, addr)
+	db.AddTx(ctx, addr, models.Transaction{
 		From: addr,
 	})
 
-	txs := db.GetTransactions(addr)
+	txs := db.GetTransactions(ctx, models.DefaultTenant, addr)
 
 	require.True(t, slices.ContainsFunc(txs, func(transaction models.Transaction) bool {
 		return transaction.From == addr
 	}))
 }
+
+func TestAddTxIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := NewDataStore()
+
+	addr := models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
+	tx := models.Transaction{Hash: "0x123", From: addr}
+	db.AddSubscriber(ctx, models.DefaultTenant, addr)
+
+	require.True(t, db.AddTx(ctx, addr, tx))
+	require.False(t, db.AddTx(ctx, addr, tx))
+
+	// an event log of the same transaction is a separate record
+	tx.LogIndex = "0x1"
+	require.True(t, db.AddTx(ctx, addr, tx))
+
+	require.Len(t, db.GetTransactions(ctx, models.DefaultTenant, addr), 2)
+}
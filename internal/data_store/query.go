@@ -0,0 +1,103 @@
+package data_store
+
+import (
+	"context"
+	"iter"
+	"slices"
+
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+// scanPage - transactions copied per lock hold while scanning, bounds both memory and how long writers wait
+const scanPage = 256
+
+// TxQuery - selects the transactions Scan yields
+type TxQuery struct {
+	Tenant string
+	// Addresses - nil scans every address the tenant watches
+	Addresses []models.Address
+	// FromBlock, ToBlock - inclusive block range, a zero ToBlock has no upper bound
+	FromBlock int
+	ToBlock   int
+}
+
+func (q TxQuery) inRange(tx models.Transaction) bool {
+	if q.FromBlock <= 0 && q.ToBlock <= 0 {
+		return true
+	}
+	block, err := helpers.ParseHexInt(tx.BlockNumber)
+	if err != nil {
+		return false
+	}
+
+	return block >= q.FromBlock && (q.ToBlock <= 0 || block <= q.ToBlock)
+}
+
+// pageReader - reads a page of the transactions of addr tenant sees, stored after the one numbered after, under
+// the store's lock. last is the sequence number of the last transaction of the page. ok is false once the
+// address is no longer watched by tenant.
+type pageReader func(addr models.Address, tenant string, after uint64) (page []models.Transaction, last uint64, ok bool)
+
+// scan - Scan of the in-memory stores, addresses are read one page at a time so the whole history is never copied.
+// Pages continue after the last transaction read rather than at an offset, so a purge between two pages neither
+// skips nor repeats transactions.
+func scan(ctx context.Context, q TxQuery, watched func(tenant string) []models.Address, read pageReader) iter.Seq2[models.Address, models.Transaction] {
+	return func(yield func(models.Address, models.Transaction) bool) {
+		addrs := q.Addresses
+		if addrs == nil {
+			// sorted so scans are stable
+			addrs = watched(q.Tenant)
+			slices.Sort(addrs)
+		}
+		for _, addr := range addrs {
+			var after uint64
+			for {
+				page, last, ok := read(addr, q.Tenant, after)
+				if !ok {
+					break
+				}
+				after = last
+				for _, tx := range page {
+					if ctx.Err() != nil {
+						return
+					}
+					if q.inRange(tx) && !yield(addr, tx) {
+						return
+					}
+				}
+				if len(page) < scanPage {
+					break
+				}
+			}
+		}
+	}
+}
+
+// page - copy of at most scanPage transactions tenant sees, stored after the one numbered after
+func (r *addressRecord) page(tenant string, after uint64) ([]models.Transaction, uint64, bool) {
+	offset, ok := r.tenants[tenant]
+	if !ok {
+		return nil, after, false
+	}
+	// seqs are increasing, purges keep their order
+	start, _ := slices.BinarySearch(r.seqs, after+1)
+	start = max(start, offset)
+	end := min(start+scanPage, len(r.txs))
+	if start == end {
+		return nil, after, true
+	}
+
+	return slices.Clone(r.txs[start:end]), r.seqs[end-1], true
+}
+
+// appendWatchedBy - appends the addresses of records tenant watches
+func appendWatchedBy(addrs []models.Address, records map[models.Address]*addressRecord, tenant string) []models.Address {
+	for addr, record := range records {
+		if _, ok := record.tenants[tenant]; ok {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs
+}
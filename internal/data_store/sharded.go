@@ -0,0 +1,203 @@
+package data_store
+
+import (
+	"context"
+	"hash/maphash"
+	"iter"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+const DefaultShards = 64
+
+// ShardedDB - in-memory store with addresses hashed into independently locked shards,
+// so matcher workers touching different addresses do not contend on one lock.
+type ShardedDB struct {
+	seed    maphash.Seed
+	shards  []shard
+	metrics Metrics
+	logger  *slog.Logger
+	checkpoint
+	subscriptionSet
+}
+
+type shard struct {
+	mu      sync.RWMutex
+	records map[models.Address]*addressRecord
+}
+
+func NewShardedDataStore(shards int, opts ...Option) DataStore {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	o := newOptions(opts)
+	db := &ShardedDB{
+		seed:            maphash.MakeSeed(),
+		shards:          make([]shard, shards),
+		metrics:         o.metrics,
+		logger:          o.logger,
+		subscriptionSet: newSubscriptionSet(o.logger),
+	}
+	for i := range db.shards {
+		db.shards[i].records = make(map[models.Address]*addressRecord)
+	}
+
+	return db
+}
+
+func (ds *ShardedDB) shard(addr models.Address) *shard {
+	return &ds.shards[maphash.String(ds.seed, string(addr))%uint64(len(ds.shards))]
+}
+
+func (ds *ShardedDB) AddSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	s := ds.shard(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[addr]
+	if !ok {
+		record = newAddressRecord()
+		s.records[addr] = record
+		ds.metrics.AddSubscribers(1)
+	}
+	if !record.watch(tenant) {
+		return false
+	}
+	ds.logger.Info("added subscriber", slog.String(logging.KeyAddress, string(addr)), slog.String(logging.KeyTenant, tenant))
+
+	return true
+}
+
+func (ds *ShardedDB) RemoveSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	s := ds.shard(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[addr]
+	if !ok || !record.unwatch(tenant) {
+		return false
+	}
+	if len(record.tenants) == 0 {
+		delete(s.records, addr)
+		ds.metrics.AddSubscribers(-1)
+	}
+	ds.logger.Info("removed subscriber", slog.String(logging.KeyAddress, string(addr)), slog.String(logging.KeyTenant, tenant))
+
+	return true
+}
+
+func (ds *ShardedDB) GetSubscribers(ctx context.Context) []models.Address {
+	addrs := make([]models.Address, 0)
+	for i := range ds.shards {
+		s := &ds.shards[i]
+		s.mu.RLock()
+		for addr := range s.records {
+			addrs = append(addrs, addr)
+		}
+		s.mu.RUnlock()
+	}
+
+	return addrs
+}
+
+func (ds *ShardedDB) GetTenantSubscribers(ctx context.Context, tenant string) []models.Address {
+	addrs := make([]models.Address, 0)
+	for i := range ds.shards {
+		s := &ds.shards[i]
+		s.mu.RLock()
+		for addr, record := range s.records {
+			if record.watchedBy(tenant) {
+				addrs = append(addrs, addr)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	slices.Sort(addrs)
+
+	return addrs
+}
+
+func (ds *ShardedDB) AddressExists(ctx context.Context, addr models.Address) bool {
+	s := ds.shard(addr)
+	s.mu.RLock()
+	_, ok := s.records[addr]
+	s.mu.RUnlock()
+
+	return ok
+}
+
+func (ds *ShardedDB) IsSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	s := ds.shard(addr)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[addr]
+
+	return ok && record.watchedBy(tenant)
+}
+
+func (ds *ShardedDB) AddTx(ctx context.Context, addr models.Address, tx models.Transaction) bool {
+	s := ds.shard(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[addr]
+	if !ok {
+		return false
+	}
+
+	return record.add(tx)
+}
+
+// GetTransactions - returns a copy, appends by AddTx never show up in it
+func (ds *ShardedDB) GetTransactions(ctx context.Context, tenant string, addr models.Address) []models.Transaction {
+	s := ds.shard(addr)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[addr]
+	if !ok {
+		return nil
+	}
+
+	return record.visible(tenant)
+}
+
+func (ds *ShardedDB) PurgeAbove(ctx context.Context, block int) int {
+	purged := 0
+	for i := range ds.shards {
+		s := &ds.shards[i]
+		s.mu.Lock()
+		for _, record := range s.records {
+			purged += record.purgeAbove(block)
+		}
+		s.mu.Unlock()
+	}
+
+	return purged + ds.purgeMatchesAbove(block)
+}
+
+func (ds *ShardedDB) Scan(ctx context.Context, q TxQuery) iter.Seq2[models.Address, models.Transaction] {
+	return scan(ctx, q, ds.watchedBy, ds.page)
+}
+
+func (ds *ShardedDB) watchedBy(tenant string) []models.Address {
+	addrs := make([]models.Address, 0)
+	for i := range ds.shards {
+		s := &ds.shards[i]
+		s.mu.RLock()
+		addrs = appendWatchedBy(addrs, s.records, tenant)
+		s.mu.RUnlock()
+	}
+	return addrs
+}
+
+func (ds *ShardedDB) page(addr models.Address, tenant string, after uint64) ([]models.Transaction, uint64, bool) {
+	s := ds.shard(addr)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[addr]
+	if !ok {
+		return nil, after, false
+	}
+	return record.page(tenant, after)
+}
@@ -0,0 +1,119 @@
+package data_store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func testAddress(i int) models.Address {
+	return models.Address(fmt.Sprintf("0x%040x", i))
+}
+
+func TestShardedGetTransactionsReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	db := NewShardedDataStore(4)
+
+	addr := testAddress(1)
+	db.AddSubscriber(ctx, models.DefaultTenant, addr)
+	db.AddTx(ctx, addr, models.Transaction{Hash: "0x1", From: addr})
+
+	txs := db.GetTransactions(ctx, models.DefaultTenant, addr)
+	txs[0].Hash = "0x2"
+	db.AddTx(ctx, addr, models.Transaction{Hash: "0x3", From: addr})
+
+	stored := db.GetTransactions(ctx, models.DefaultTenant, addr)
+	require.Len(t, stored, 2)
+	require.Equal(t, "0x1", stored[0].Hash)
+	require.Len(t, txs, 1)
+}
+
+// TestShardedConcurrentAccess - meant to be run with -race
+func TestShardedConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	const (
+		addresses = 64
+		writers   = 8
+		txs       = 100
+	)
+	db := NewShardedDataStore(8)
+
+	wg := sync.WaitGroup{}
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range txs {
+				addr := testAddress(i % addresses)
+				db.AddSubscriber(ctx, models.DefaultTenant, addr)
+				// every writer inserts the same records, each must be stored once
+				db.AddTx(ctx, addr, models.Transaction{Hash: fmt.Sprintf("0x%x", i), From: addr})
+				if w == 0 {
+					db.SetCurrentBlock(ctx, i)
+				}
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range txs {
+				addr := testAddress(i % addresses)
+				if db.AddressExists(ctx, addr) {
+					for range db.GetTransactions(ctx, models.DefaultTenant, addr) {
+					}
+				}
+				db.GetSubscribers(ctx)
+				db.GetCurrentBlock(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, addr := range db.GetSubscribers(ctx) {
+		total += len(db.GetTransactions(ctx, models.DefaultTenant, addr))
+	}
+	require.Equal(t, txs, total)
+	require.Len(t, db.GetSubscribers(ctx), addresses)
+}
+
+func benchmarkStore(b *testing.B, db DataStore) {
+	ctx := context.Background()
+	const addresses = 10_000
+	addrs := make([]models.Address, addresses)
+	hashes := make([]string, addresses)
+	for i := range addresses {
+		addrs[i] = testAddress(i)
+		hashes[i] = fmt.Sprintf("0x%064x", i)
+		db.AddSubscriber(ctx, models.DefaultTenant, addrs[i])
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			addr := addrs[i%addresses]
+			// matcher workload: mostly lookups, some inserts and reads
+			db.AddressExists(ctx, addr)
+			if i%10 == 0 {
+				db.AddTx(ctx, addr, models.Transaction{Hash: hashes[(i/10)%addresses], From: addr})
+			}
+			if i%100 == 0 {
+				db.GetTransactions(ctx, models.DefaultTenant, addr)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkDB(b *testing.B) {
+	benchmarkStore(b, NewDataStore())
+}
+
+func BenchmarkShardedDB(b *testing.B) {
+	benchmarkStore(b, NewShardedDataStore(DefaultShards))
+}
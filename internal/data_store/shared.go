@@ -0,0 +1,336 @@
+package data_store
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+// Metrics - instrumentation of the store
+type Metrics interface {
+	// AddSubscribers - change in the number of subscribed addresses
+	AddSubscribers(delta int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) AddSubscribers(int) {}
+
+type options struct {
+	metrics Metrics
+	logger  *slog.Logger
+}
+
+type Option func(o *options)
+
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		metrics: noopMetrics{},
+		logger:  logging.Discard(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// checkpoint - parsing progress shared by the in-memory stores
+type checkpoint struct {
+	lastProcessedBlock    atomic.Int64
+	lastProcessedTxsIndex atomic.Int64
+}
+
+func (c *checkpoint) GetCurrentBlock(ctx context.Context) int {
+	return int(c.lastProcessedBlock.Load())
+}
+func (c *checkpoint) GetLastProcessedTxIndex(ctx context.Context) int {
+	return int(c.lastProcessedTxsIndex.Load())
+}
+func (c *checkpoint) SetCurrentBlock(ctx context.Context, currBlock int) {
+	c.lastProcessedBlock.Store(int64(currBlock))
+}
+func (c *checkpoint) SetLastProcessedTxIndex(ctx context.Context, currIndex int) {
+	c.lastProcessedTxsIndex.Store(int64(currIndex))
+}
+
+// addressRecord - transactions of a watched address stored once, each tenant sees the ones
+// added after it started watching
+type addressRecord struct {
+	txs []models.Transaction
+	// seqs - sequence number of each of txs, increasing and never reused, so a scan can resume after the
+	// last transaction it read while a purge compacts txs
+	seqs    []uint64
+	lastSeq uint64
+	keys    map[models.TxKey]struct{}
+	// tenants - offset into txs of the first transaction each watching tenant sees
+	tenants map[string]int
+}
+
+func newAddressRecord() *addressRecord {
+	return &addressRecord{
+		txs:     make([]models.Transaction, 0),
+		keys:    make(map[models.TxKey]struct{}),
+		tenants: make(map[string]int),
+	}
+}
+
+// watch - reports whether tenant was not watching yet
+func (r *addressRecord) watch(tenant string) bool {
+	if _, ok := r.tenants[tenant]; ok {
+		return false
+	}
+	r.tenants[tenant] = len(r.txs)
+
+	return true
+}
+
+// unwatch - reports whether tenant was watching
+func (r *addressRecord) unwatch(tenant string) bool {
+	if _, ok := r.tenants[tenant]; !ok {
+		return false
+	}
+	delete(r.tenants, tenant)
+
+	return true
+}
+
+func (r *addressRecord) add(tx models.Transaction) bool {
+	key := tx.Key()
+	if _, ok := r.keys[key]; ok {
+		return false
+	}
+	r.keys[key] = struct{}{}
+	r.txs = append(r.txs, tx)
+	r.lastSeq++
+	r.seqs = append(r.seqs, r.lastSeq)
+
+	return true
+}
+
+// aboveBlock - tx was mined after block, a transaction with an unreadable block number is kept
+func aboveBlock(tx models.Transaction, block int) bool {
+	number, err := helpers.ParseHexInt(tx.BlockNumber)
+	return err == nil && number > block
+}
+
+// purgeAbove - drops the transactions mined after block, each tenant keeps seeing the remaining ones
+// it saw before. Returns the number dropped.
+func (r *addressRecord) purgeAbove(block int) int {
+	// keptBefore[i] - transactions kept out of the first i
+	keptBefore := make([]int, len(r.txs)+1)
+	kept := r.txs[:0]
+	keptSeqs := r.seqs[:0]
+	for i, tx := range r.txs {
+		keptBefore[i] = len(kept)
+		if aboveBlock(tx, block) {
+			delete(r.keys, tx.Key())
+			continue
+		}
+		kept = append(kept, tx)
+		keptSeqs = append(keptSeqs, r.seqs[i])
+	}
+	keptBefore[len(r.txs)] = len(kept)
+	for tenant, offset := range r.tenants {
+		r.tenants[tenant] = keptBefore[offset]
+	}
+	purged := len(r.txs) - len(kept)
+	clear(r.txs[len(kept):])
+	r.txs = kept
+	r.seqs = keptSeqs
+
+	return purged
+}
+
+func (r *addressRecord) watchedBy(tenant string) bool {
+	_, ok := r.tenants[tenant]
+	return ok
+}
+
+// visible - copy of the transactions tenant sees, nil when it does not watch the address
+func (r *addressRecord) visible(tenant string) []models.Transaction {
+	offset, ok := r.tenants[tenant]
+	if !ok {
+		return nil
+	}
+
+	return slices.Clone(r.txs[offset:])
+}
+
+// subscriptionSet - rule subscriptions and their matches shared by the in-memory stores.
+// Rule subscriptions are few, so a single lock is enough.
+type subscriptionSet struct {
+	subsMu        sync.RWMutex
+	subscriptions map[string]*subscriptionRecord
+	subsLogger    *slog.Logger
+}
+
+type subscriptionRecord struct {
+	sub     models.Subscription
+	matches []models.Transaction
+	keys    map[models.TxKey]struct{}
+}
+
+func newSubscriptionSet(logger *slog.Logger) subscriptionSet {
+	return subscriptionSet{
+		subscriptions: make(map[string]*subscriptionRecord),
+		subsLogger:    logger,
+	}
+}
+
+func (s *subscriptionSet) AddSubscription(ctx context.Context, sub models.Subscription) error {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if _, ok := s.subscriptions[sub.ID]; ok {
+		return ErrSubscriptionExists
+	}
+	s.subscriptions[sub.ID] = &subscriptionRecord{
+		sub:  sub,
+		keys: make(map[models.TxKey]struct{}),
+	}
+	s.subsLogger.Info("added subscription", slog.String(logging.KeySubscription, sub.ID), slog.String(logging.KeyTenant, sub.Tenant))
+
+	return nil
+}
+
+// record - subscription id of tenant, other tenants' subscriptions are not found
+func (s *subscriptionSet) record(tenant, id string) (*subscriptionRecord, bool) {
+	record, ok := s.subscriptions[id]
+	if !ok || record.sub.Tenant != tenant {
+		return nil, false
+	}
+
+	return record, true
+}
+
+func (s *subscriptionSet) RemoveSubscription(ctx context.Context, tenant, id string) error {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if _, ok := s.record(tenant, id); !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(s.subscriptions, id)
+
+	return nil
+}
+
+func (s *subscriptionSet) GetSubscription(ctx context.Context, tenant, id string) (models.Subscription, error) {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+	record, ok := s.record(tenant, id)
+	if !ok {
+		return models.Subscription{}, ErrSubscriptionNotFound
+	}
+
+	return record.sub, nil
+}
+
+func (s *subscriptionSet) GetSubscriptions(ctx context.Context, tenant string) []models.Subscription {
+	return s.subscriptionsOf(func(sub models.Subscription) bool {
+		return sub.Tenant == tenant
+	})
+}
+
+func (s *subscriptionSet) AllSubscriptions(ctx context.Context) []models.Subscription {
+	return s.subscriptionsOf(func(models.Subscription) bool {
+		return true
+	})
+}
+
+func (s *subscriptionSet) subscriptionsOf(keep func(models.Subscription) bool) []models.Subscription {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+	subs := make([]models.Subscription, 0, len(s.subscriptions))
+	for _, record := range s.subscriptions {
+		if keep(record.sub) {
+			subs = append(subs, record.sub)
+		}
+	}
+	slices.SortFunc(subs, func(a, b models.Subscription) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return subs
+}
+
+func (s *subscriptionSet) AddMatch(ctx context.Context, id string, tx models.Transaction) bool {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	record, ok := s.subscriptions[id]
+	if !ok {
+		return false
+	}
+	key := tx.Key()
+	if _, ok := record.keys[key]; ok {
+		return false
+	}
+	record.keys[key] = struct{}{}
+	record.matches = append(record.matches, tx)
+
+	return true
+}
+
+func (s *subscriptionSet) GetMatches(ctx context.Context, tenant, id string) ([]models.Transaction, error) {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+	record, ok := s.record(tenant, id)
+	if !ok {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	return slices.Clone(record.matches), nil
+}
+
+func (s *subscriptionSet) BatchGetMatches(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+	matches := make(map[string][]models.Transaction, len(ids))
+	for _, id := range ids {
+		if record, ok := s.record(tenant, id); ok {
+			matches[id] = slices.Clone(record.matches)
+		}
+	}
+
+	return matches
+}
+
+// purgeMatchesAbove - drops the matches mined after block, returns the number dropped
+func (s *subscriptionSet) purgeMatchesAbove(block int) int {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	purged := 0
+	for _, record := range s.subscriptions {
+		kept := record.matches[:0]
+		for _, tx := range record.matches {
+			if aboveBlock(tx, block) {
+				delete(record.keys, tx.Key())
+				continue
+			}
+			kept = append(kept, tx)
+		}
+		purged += len(record.matches) - len(kept)
+		clear(record.matches[len(kept):])
+		record.matches = kept
+	}
+
+	return purged
+}
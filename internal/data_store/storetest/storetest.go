@@ -0,0 +1,552 @@
+// Package storetest - contract every data_store.DataStore implementation must satisfy.
+// Backends call Run from their own tests with a factory returning an empty store.
+package storetest
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Factory func() data_store.DataStore
+
+const (
+	tenant      = models.DefaultTenant
+	otherTenant = "other"
+)
+
+func Run(t *testing.T, newStore Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, ds data_store.DataStore)
+	}{
+		{"Subscribers", testSubscribers},
+		{"RemoveSubscriber", testRemoveSubscriber},
+		{"AddTx", testAddTx},
+		{"AddTxUnknownAddress", testAddTxUnknownAddress},
+		{"TenantTransactions", testTenantTransactions},
+		{"TenantSubscriptions", testTenantSubscriptions},
+		{"TenantSubscribers", testTenantSubscribers},
+		{"TransactionsOrder", testTransactionsOrder},
+		{"TransactionsCopy", testTransactionsCopy},
+		{"Checkpoint", testCheckpoint},
+		{"Subscriptions", testSubscriptions},
+		{"SubscriptionErrors", testSubscriptionErrors},
+		{"Matches", testMatches},
+		{"BatchGetMatches", testBatchGetMatches},
+		{"PurgeAbove", testPurgeAbove},
+		{"Scan", testScan},
+		{"ScanLargeHistory", testScanLargeHistory},
+		{"ScanDuringPurge", testScanDuringPurge},
+		{"ConcurrentAddTx", testConcurrentAddTx},
+		{"ConcurrentMatches", testConcurrentMatches},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore())
+		})
+	}
+}
+
+func Address(i int) models.Address {
+	return models.Address(fmt.Sprintf("0x%040x", i))
+}
+
+func tx(i int, addr models.Address) models.Transaction {
+	return models.Transaction{
+		Hash:             fmt.Sprintf("0x%064x", i),
+		From:             addr,
+		BlockNumber:      "0x1",
+		TransactionIndex: fmt.Sprintf("0x%x", i),
+	}
+}
+
+func txAt(i int, addr models.Address, block int) models.Transaction {
+	record := tx(i, addr)
+	record.BlockNumber = fmt.Sprintf("0x%x", block)
+	return record
+}
+
+type scanned struct {
+	addr models.Address
+	tx   models.Transaction
+}
+
+func collect(ds data_store.DataStore, t *testing.T, q data_store.TxQuery) []scanned {
+	rows := make([]scanned, 0)
+	for addr, tx := range ds.Scan(t.Context(), q) {
+		rows = append(rows, scanned{addr, tx})
+	}
+	return rows
+}
+
+func testScan(t *testing.T, ds data_store.DataStore) {
+	a, b, c := Address(1), Address(2), Address(3)
+	ds.AddSubscriber(t.Context(), tenant, b)
+	ds.AddSubscriber(t.Context(), tenant, a)
+	ds.AddSubscriber(t.Context(), otherTenant, c)
+	for block := 1; block <= 3; block++ {
+		ds.AddTx(t.Context(), a, txAt(block, a, block))
+		ds.AddTx(t.Context(), b, txAt(block, b, block))
+		ds.AddTx(t.Context(), c, txAt(block, c, block))
+	}
+	// a tenant watching later sees only what was added since
+	ds.AddSubscriber(t.Context(), otherTenant, a)
+	ds.AddTx(t.Context(), a, txAt(4, a, 4))
+
+	all := collect(ds, t, data_store.TxQuery{Tenant: tenant})
+	require.Equal(t, []scanned{
+		{a, txAt(1, a, 1)}, {a, txAt(2, a, 2)}, {a, txAt(3, a, 3)}, {a, txAt(4, a, 4)},
+		{b, txAt(1, b, 1)}, {b, txAt(2, b, 2)}, {b, txAt(3, b, 3)},
+	}, all)
+
+	ranged := collect(ds, t, data_store.TxQuery{Tenant: tenant, FromBlock: 2, ToBlock: 2})
+	require.Equal(t, []scanned{{a, txAt(2, a, 2)}, {b, txAt(2, b, 2)}}, ranged)
+
+	tail := collect(ds, t, data_store.TxQuery{Tenant: tenant, Addresses: []models.Address{b}, FromBlock: 3})
+	require.Equal(t, []scanned{{b, txAt(3, b, 3)}}, tail)
+
+	other := collect(ds, t, data_store.TxQuery{Tenant: otherTenant, FromBlock: 3})
+	require.Equal(t, []scanned{{a, txAt(4, a, 4)}, {c, txAt(3, c, 3)}}, other)
+
+	// addresses the tenant does not watch are skipped
+	require.Empty(t, collect(ds, t, data_store.TxQuery{Tenant: tenant, Addresses: []models.Address{c, Address(9)}}))
+
+	rows := 0
+	for range ds.Scan(t.Context(), data_store.TxQuery{Tenant: tenant}) {
+		rows++
+		break
+	}
+	require.Equal(t, 1, rows)
+}
+
+func testScanLargeHistory(t *testing.T, ds data_store.DataStore) {
+	const n = 1000
+	addr := Address(1)
+	ds.AddSubscriber(t.Context(), tenant, addr)
+	for i := range n {
+		ds.AddTx(t.Context(), addr, txAt(i, addr, i/10))
+	}
+
+	rows := collect(ds, t, data_store.TxQuery{Tenant: tenant})
+	require.Len(t, rows, n)
+	for i, row := range rows {
+		require.Equal(t, tx(i, addr).Hash, row.tx.Hash)
+	}
+	require.Len(t, collect(ds, t, data_store.TxQuery{Tenant: tenant, FromBlock: 50, ToBlock: 59}), 100)
+}
+
+func testSubscribers(t *testing.T, ds data_store.DataStore) {
+	require.Empty(t, ds.GetSubscribers(t.Context()))
+	require.False(t, ds.AddressExists(t.Context(), Address(1)))
+
+	require.True(t, ds.AddSubscriber(t.Context(), tenant, Address(1)))
+	require.True(t, ds.AddSubscriber(t.Context(), tenant, Address(2)))
+	// subscribing twice is a no-op
+	require.False(t, ds.AddSubscriber(t.Context(), tenant, Address(1)))
+
+	require.True(t, ds.AddressExists(t.Context(), Address(1)))
+	require.True(t, ds.AddressExists(t.Context(), Address(2)))
+	require.ElementsMatch(t, []models.Address{Address(1), Address(2)}, ds.GetSubscribers(t.Context()))
+
+	// a subscriber without transactions has an empty, non-nil history
+	txs := ds.GetTransactions(t.Context(), tenant, Address(1))
+	require.NotNil(t, txs)
+	require.Empty(t, txs)
+	require.Nil(t, ds.GetTransactions(t.Context(), tenant, Address(3)))
+}
+
+func testRemoveSubscriber(t *testing.T, ds data_store.DataStore) {
+	ds.AddSubscriber(t.Context(), tenant, Address(1))
+	ds.AddTx(t.Context(), Address(1), tx(1, Address(1)))
+
+	require.True(t, ds.RemoveSubscriber(t.Context(), tenant, Address(1)))
+	// removing an unknown address is a no-op
+	require.False(t, ds.RemoveSubscriber(t.Context(), tenant, Address(2)))
+	require.False(t, ds.RemoveSubscriber(t.Context(), tenant, Address(1)))
+
+	require.False(t, ds.AddressExists(t.Context(), Address(1)))
+	require.Empty(t, ds.GetSubscribers(t.Context()))
+	require.Nil(t, ds.GetTransactions(t.Context(), tenant, Address(1)))
+
+	// history starts over after subscribing again
+	ds.AddSubscriber(t.Context(), tenant, Address(1))
+	require.Empty(t, ds.GetTransactions(t.Context(), tenant, Address(1)))
+	require.True(t, ds.AddTx(t.Context(), Address(1), tx(1, Address(1))))
+}
+
+func testAddTx(t *testing.T, ds data_store.DataStore) {
+	addr := Address(1)
+	ds.AddSubscriber(t.Context(), tenant, addr)
+
+	record := tx(1, addr)
+	require.True(t, ds.AddTx(t.Context(), addr, record))
+	require.False(t, ds.AddTx(t.Context(), addr, record))
+
+	// the same transaction under another address is a separate record
+	ds.AddSubscriber(t.Context(), tenant, Address(2))
+	require.True(t, ds.AddTx(t.Context(), Address(2), record))
+
+	// so is an event log of the same transaction
+	event := record
+	event.LogIndex = "0x0"
+	require.True(t, ds.AddTx(t.Context(), addr, event))
+	require.False(t, ds.AddTx(t.Context(), addr, event))
+
+	require.Equal(t, []models.Transaction{record, event}, ds.GetTransactions(t.Context(), tenant, addr))
+	require.Equal(t, []models.Transaction{record}, ds.GetTransactions(t.Context(), tenant, Address(2)))
+}
+
+func testAddTxUnknownAddress(t *testing.T, ds data_store.DataStore) {
+	addr := Address(1)
+	// transactions of addresses nobody watches are dropped
+	require.False(t, ds.AddTx(t.Context(), addr, tx(1, addr)))
+
+	require.False(t, ds.AddressExists(t.Context(), addr))
+	require.False(t, ds.IsSubscriber(t.Context(), tenant, addr))
+	require.Nil(t, ds.GetTransactions(t.Context(), tenant, addr))
+}
+
+func testScanDuringPurge(t *testing.T, ds data_store.DataStore) {
+	addr := Address(1)
+	ds.AddSubscriber(t.Context(), tenant, addr)
+	for i := range 600 {
+		ds.AddTx(t.Context(), addr, txAt(i, addr, i+1))
+	}
+
+	hashes := make([]string, 0)
+	for _, record := range ds.Scan(t.Context(), data_store.TxQuery{Tenant: tenant}) {
+		if len(hashes) == 10 {
+			// a rewind to block 50 while the first page is read, then other blocks parsed in place of the dropped
+			require.Equal(t, 550, ds.PurgeAbove(t.Context(), 50))
+			for i := 600; i < 900; i++ {
+				ds.AddTx(t.Context(), addr, txAt(i, addr, i-549))
+			}
+		}
+		hashes = append(hashes, record.Hash)
+	}
+
+	// rows kept through the rewind are yielded once, rows parsed after it all or none, as a store may scan a
+	// snapshot; none are skipped from the middle of a history
+	require.Len(t, slices.Compact(slices.Sorted(slices.Values(hashes))), len(hashes))
+	for i := range 50 {
+		require.Contains(t, hashes, tx(i, addr).Hash)
+	}
+	reparsed := 0
+	for i := 600; i < 900; i++ {
+		if slices.Contains(hashes, tx(i, addr).Hash) {
+			reparsed++
+		}
+	}
+	require.Contains(t, []int{0, 300}, reparsed)
+}
+
+func testTenantSubscribers(t *testing.T, ds data_store.DataStore) {
+	require.Empty(t, ds.GetTenantSubscribers(t.Context(), tenant))
+
+	ds.AddSubscriber(t.Context(), tenant, Address(3))
+	ds.AddSubscriber(t.Context(), tenant, Address(1))
+	ds.AddSubscriber(t.Context(), otherTenant, Address(1))
+	ds.AddSubscriber(t.Context(), otherTenant, Address(2))
+
+	// sorted, another tenant's addresses are left out
+	require.Equal(t, []models.Address{Address(1), Address(3)}, ds.GetTenantSubscribers(t.Context(), tenant))
+	require.Equal(t, []models.Address{Address(1), Address(2)}, ds.GetTenantSubscribers(t.Context(), otherTenant))
+
+	ds.RemoveSubscriber(t.Context(), tenant, Address(1))
+	require.Equal(t, []models.Address{Address(3)}, ds.GetTenantSubscribers(t.Context(), tenant))
+	require.Equal(t, []models.Address{Address(1), Address(2)}, ds.GetTenantSubscribers(t.Context(), otherTenant))
+}
+
+func testTenantTransactions(t *testing.T, ds data_store.DataStore) {
+	addr := Address(1)
+	ds.AddSubscriber(t.Context(), tenant, addr)
+	require.True(t, ds.AddTx(t.Context(), addr, tx(1, addr)))
+
+	// a tenant watching later sees only what was added since
+	require.False(t, ds.IsSubscriber(t.Context(), otherTenant, addr))
+	require.True(t, ds.AddSubscriber(t.Context(), otherTenant, addr))
+	require.True(t, ds.IsSubscriber(t.Context(), otherTenant, addr))
+	require.Empty(t, ds.GetTransactions(t.Context(), otherTenant, addr))
+	// the shared record is stored once for both
+	require.True(t, ds.AddTx(t.Context(), addr, tx(2, addr)))
+	require.False(t, ds.AddTx(t.Context(), addr, tx(2, addr)))
+
+	require.Equal(t, []models.Transaction{tx(1, addr), tx(2, addr)}, ds.GetTransactions(t.Context(), tenant, addr))
+	require.Equal(t, []models.Transaction{tx(2, addr)}, ds.GetTransactions(t.Context(), otherTenant, addr))
+	require.Nil(t, ds.GetTransactions(t.Context(), "third", addr))
+	require.Equal(t, []models.Address{addr}, ds.GetSubscribers(t.Context()))
+
+	// the address stays watched until its last tenant leaves
+	ds.RemoveSubscriber(t.Context(), tenant, addr)
+	require.Nil(t, ds.GetTransactions(t.Context(), tenant, addr))
+	require.False(t, ds.IsSubscriber(t.Context(), tenant, addr))
+	require.True(t, ds.IsSubscriber(t.Context(), otherTenant, addr))
+	require.True(t, ds.AddressExists(t.Context(), addr))
+	require.Len(t, ds.GetTransactions(t.Context(), otherTenant, addr), 1)
+
+	// removing a tenant that does not watch the address is a no-op
+	require.False(t, ds.RemoveSubscriber(t.Context(), tenant, addr))
+	require.True(t, ds.AddressExists(t.Context(), addr))
+
+	ds.RemoveSubscriber(t.Context(), otherTenant, addr)
+	require.False(t, ds.AddressExists(t.Context(), addr))
+	require.Empty(t, ds.GetSubscribers(t.Context()))
+}
+
+func testTenantSubscriptions(t *testing.T, ds data_store.DataStore) {
+	mine := models.Subscription{ID: "a", Tenant: tenant, Rule: models.Rule{Type: models.RuleContractCreation}}
+	theirs := models.Subscription{ID: "b", Tenant: otherTenant, Rule: models.Rule{Type: models.RuleContractCreation}}
+	require.NoError(t, ds.AddSubscription(t.Context(), mine))
+	require.NoError(t, ds.AddSubscription(t.Context(), theirs))
+	require.True(t, ds.AddMatch(t.Context(), "b", tx(1, Address(1))))
+
+	require.Equal(t, []models.Subscription{mine}, ds.GetSubscriptions(t.Context(), tenant))
+	require.Equal(t, []models.Subscription{theirs}, ds.GetSubscriptions(t.Context(), otherTenant))
+	require.Equal(t, []models.Subscription{mine, theirs}, ds.AllSubscriptions(t.Context()))
+
+	// another tenant's subscription does not exist for tenant
+	_, err := ds.GetSubscription(t.Context(), tenant, "b")
+	require.ErrorIs(t, err, data_store.ErrSubscriptionNotFound)
+	_, err = ds.GetMatches(t.Context(), tenant, "b")
+	require.ErrorIs(t, err, data_store.ErrSubscriptionNotFound)
+	require.ErrorIs(t, ds.RemoveSubscription(t.Context(), tenant, "b"), data_store.ErrSubscriptionNotFound)
+
+	matches, err := ds.GetMatches(t.Context(), otherTenant, "b")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	// IDs are unique across tenants
+	require.ErrorIs(t, ds.AddSubscription(t.Context(), models.Subscription{ID: "b", Tenant: tenant}), data_store.ErrSubscriptionExists)
+}
+
+func testTransactionsOrder(t *testing.T, ds data_store.DataStore) {
+	addr := Address(1)
+	ds.AddSubscriber(t.Context(), tenant, addr)
+
+	want := make([]models.Transaction, 0, 10)
+	for i := 10; i > 0; i-- {
+		want = append(want, tx(i, addr))
+		ds.AddTx(t.Context(), addr, tx(i, addr))
+	}
+
+	require.Equal(t, want, ds.GetTransactions(t.Context(), tenant, addr))
+}
+
+func testTransactionsCopy(t *testing.T, ds data_store.DataStore) {
+	addr := Address(1)
+	ds.AddSubscriber(t.Context(), tenant, addr)
+	ds.AddTx(t.Context(), addr, tx(1, addr))
+
+	txs := ds.GetTransactions(t.Context(), tenant, addr)
+	txs[0].Hash = "0x0"
+	ds.AddTx(t.Context(), addr, tx(2, addr))
+
+	require.Len(t, txs, 1)
+	stored := ds.GetTransactions(t.Context(), tenant, addr)
+	require.Len(t, stored, 2)
+	require.Equal(t, tx(1, addr).Hash, stored[0].Hash)
+}
+
+func testCheckpoint(t *testing.T, ds data_store.DataStore) {
+	require.Zero(t, ds.GetCurrentBlock(t.Context()))
+	require.Zero(t, ds.GetLastProcessedTxIndex(t.Context()))
+
+	ds.SetCurrentBlock(t.Context(), 100)
+	ds.SetLastProcessedTxIndex(t.Context(), 7)
+	require.Equal(t, 100, ds.GetCurrentBlock(t.Context()))
+	require.Equal(t, 7, ds.GetLastProcessedTxIndex(t.Context()))
+
+	// rewinding is allowed
+	ds.SetCurrentBlock(t.Context(), 90)
+	require.Equal(t, 90, ds.GetCurrentBlock(t.Context()))
+}
+
+func testSubscriptions(t *testing.T, ds data_store.DataStore) {
+	require.Empty(t, ds.GetSubscriptions(t.Context(), tenant))
+
+	b := models.Subscription{ID: "b", Tenant: tenant, Rule: models.Rule{Type: models.RuleContractCreation}}
+	a := models.Subscription{ID: "a", Tenant: tenant, Rule: models.Rule{Type: models.RuleAddress, Addresses: []models.Address{Address(1)}}}
+	require.NoError(t, ds.AddSubscription(t.Context(), b))
+	require.NoError(t, ds.AddSubscription(t.Context(), a))
+
+	got, err := ds.GetSubscription(t.Context(), tenant, "a")
+	require.NoError(t, err)
+	require.Equal(t, a, got)
+
+	// listed by ID
+	require.Equal(t, []models.Subscription{a, b}, ds.GetSubscriptions(t.Context(), tenant))
+
+	require.NoError(t, ds.RemoveSubscription(t.Context(), tenant, "a"))
+	require.Equal(t, []models.Subscription{b}, ds.GetSubscriptions(t.Context(), tenant))
+}
+
+func testSubscriptionErrors(t *testing.T, ds data_store.DataStore) {
+	sub := models.Subscription{ID: "a", Tenant: tenant, Rule: models.Rule{Type: models.RuleContractCreation}}
+	require.NoError(t, ds.AddSubscription(t.Context(), sub))
+	require.ErrorIs(t, ds.AddSubscription(t.Context(), sub), data_store.ErrSubscriptionExists)
+
+	_, err := ds.GetSubscription(t.Context(), tenant, "b")
+	require.ErrorIs(t, err, data_store.ErrSubscriptionNotFound)
+	_, err = ds.GetMatches(t.Context(), tenant, "b")
+	require.ErrorIs(t, err, data_store.ErrSubscriptionNotFound)
+	require.ErrorIs(t, ds.RemoveSubscription(t.Context(), tenant, "b"), data_store.ErrSubscriptionNotFound)
+	require.False(t, ds.AddMatch(t.Context(), "b", tx(1, Address(1))))
+
+	require.NoError(t, ds.RemoveSubscription(t.Context(), tenant, "a"))
+	require.ErrorIs(t, ds.RemoveSubscription(t.Context(), tenant, "a"), data_store.ErrSubscriptionNotFound)
+}
+
+func testMatches(t *testing.T, ds data_store.DataStore) {
+	sub := models.Subscription{ID: "a", Tenant: tenant, Rule: models.Rule{Type: models.RuleContractCreation}}
+	require.NoError(t, ds.AddSubscription(t.Context(), sub))
+
+	matches, err := ds.GetMatches(t.Context(), tenant, "a")
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	require.True(t, ds.AddMatch(t.Context(), "a", tx(2, Address(1))))
+	require.True(t, ds.AddMatch(t.Context(), "a", tx(1, Address(1))))
+	require.False(t, ds.AddMatch(t.Context(), "a", tx(2, Address(1))))
+
+	matches, err = ds.GetMatches(t.Context(), tenant, "a")
+	require.NoError(t, err)
+	require.Equal(t, []models.Transaction{tx(2, Address(1)), tx(1, Address(1))}, matches)
+
+	// matches are dropped with the subscription
+	require.NoError(t, ds.RemoveSubscription(t.Context(), tenant, "a"))
+	require.NoError(t, ds.AddSubscription(t.Context(), sub))
+	matches, err = ds.GetMatches(t.Context(), tenant, "a")
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+func testBatchGetMatches(t *testing.T, ds data_store.DataStore) {
+	require.NoError(t, ds.AddSubscription(t.Context(), models.Subscription{ID: "a", Tenant: tenant}))
+	require.NoError(t, ds.AddSubscription(t.Context(), models.Subscription{ID: "b", Tenant: tenant}))
+	require.NoError(t, ds.AddSubscription(t.Context(), models.Subscription{ID: "c", Tenant: otherTenant}))
+	require.True(t, ds.AddMatch(t.Context(), "a", tx(1, Address(1))))
+	require.True(t, ds.AddMatch(t.Context(), "c", tx(2, Address(1))))
+
+	// unknown IDs and another tenant's subscriptions are left out
+	matches := ds.BatchGetMatches(t.Context(), tenant, []string{"a", "b", "c", "missing"})
+	require.Len(t, matches, 2)
+	require.Equal(t, []models.Transaction{tx(1, Address(1))}, matches["a"])
+	require.Contains(t, matches, "b")
+	require.Empty(t, matches["b"])
+
+	// the result is a copy
+	matches["a"][0].Hash = "changed"
+	require.Equal(t, tx(1, Address(1)), ds.BatchGetMatches(t.Context(), tenant, []string{"a"})["a"][0])
+	require.Empty(t, ds.BatchGetMatches(t.Context(), tenant, nil))
+}
+
+func testPurgeAbove(t *testing.T, ds data_store.DataStore) {
+	ctx := t.Context()
+	addr := Address(1)
+	require.True(t, ds.AddSubscriber(ctx, tenant, addr))
+	require.True(t, ds.AddTx(ctx, addr, txAt(1, addr, 10)))
+	require.True(t, ds.AddTx(ctx, addr, txAt(2, addr, 12)))
+	// the other tenant subscribes after the first two transactions
+	require.True(t, ds.AddSubscriber(ctx, otherTenant, addr))
+	require.True(t, ds.AddTx(ctx, addr, txAt(3, addr, 11)))
+	require.True(t, ds.AddTx(ctx, addr, txAt(4, addr, 13)))
+	require.NoError(t, ds.AddSubscription(ctx, models.Subscription{ID: "a", Tenant: tenant}))
+	require.True(t, ds.AddMatch(ctx, "a", txAt(1, addr, 10)))
+	require.True(t, ds.AddMatch(ctx, "a", txAt(4, addr, 13)))
+
+	require.Equal(t, 3, ds.PurgeAbove(ctx, 11))
+	require.Equal(t, []models.Transaction{txAt(1, addr, 10), txAt(3, addr, 11)}, ds.GetTransactions(ctx, tenant, addr))
+	require.Equal(t, []models.Transaction{txAt(3, addr, 11)}, ds.GetTransactions(ctx, otherTenant, addr))
+	matches, err := ds.GetMatches(ctx, tenant, "a")
+	require.NoError(t, err)
+	require.Equal(t, []models.Transaction{txAt(1, addr, 10)}, matches)
+
+	// purged transactions can be stored again, subscriptions are kept
+	require.True(t, ds.AddTx(ctx, addr, txAt(2, addr, 12)))
+	require.True(t, ds.AddMatch(ctx, "a", txAt(4, addr, 13)))
+	require.False(t, ds.AddTx(ctx, addr, txAt(1, addr, 10)))
+	require.Len(t, ds.GetTransactions(ctx, otherTenant, addr), 2)
+	require.True(t, ds.IsSubscriber(ctx, otherTenant, addr))
+	require.Zero(t, ds.PurgeAbove(ctx, 13))
+}
+
+func testConcurrentAddTx(t *testing.T, ds data_store.DataStore) {
+	const (
+		addresses = 16
+		workers   = 8
+		txs       = 200
+	)
+	var added sync.Map
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range txs {
+				addr := Address(i % addresses)
+				ds.AddSubscriber(t.Context(), tenant, addr)
+				if ds.AddTx(t.Context(), addr, tx(i, addr)) {
+					_, loaded := added.LoadOrStore(i, struct{}{})
+					assert.False(t, loaded, "tx %d reported as new twice", i)
+				}
+				ds.AddressExists(t.Context(), addr)
+				ds.GetTransactions(t.Context(), tenant, addr)
+				ds.SetCurrentBlock(t.Context(), i)
+				ds.GetCurrentBlock(t.Context())
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, addr := range ds.GetSubscribers(t.Context()) {
+		total += len(ds.GetTransactions(t.Context(), tenant, addr))
+	}
+	require.Equal(t, txs, total)
+}
+
+func testConcurrentMatches(t *testing.T, ds data_store.DataStore) {
+	const (
+		subscriptions = 4
+		workers       = 8
+		txs           = 100
+	)
+	for i := range subscriptions {
+		require.NoError(t, ds.AddSubscription(t.Context(), models.Subscription{
+			ID:     fmt.Sprint(i),
+			Tenant: tenant,
+			Rule:   models.Rule{Type: models.RuleContractCreation},
+		}))
+	}
+
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range txs {
+				id := fmt.Sprint(i % subscriptions)
+				ds.AddMatch(t.Context(), id, tx(i, Address(1)))
+				_, err := ds.GetMatches(t.Context(), tenant, id)
+				assert.NoError(t, err)
+				ds.GetSubscriptions(t.Context(), tenant)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range subscriptions {
+		matches, err := ds.GetMatches(t.Context(), tenant, fmt.Sprint(i))
+		require.NoError(t, err)
+		require.Len(t, matches, txs/subscriptions)
+	}
+}
@@ -0,0 +1,204 @@
+package data_store
+
+import (
+	"context"
+	"iter"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/galecic/ethereum_parser/internal/data_store"
+
+// TracedDB - DataStore decorator recording a span per call
+type TracedDB struct {
+	next   DataStore
+	tracer trace.Tracer
+}
+
+func NewTracedDataStore(next DataStore, tp trace.TracerProvider) DataStore {
+	return &TracedDB{
+		next:   next,
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+func (t *TracedDB) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "store."+method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func addrAttr(addr models.Address) attribute.KeyValue {
+	return tracing.KeyAddress.String(string(addr))
+}
+
+func tenantAttr(tenant string) attribute.KeyValue {
+	return attribute.String("tenant", tenant)
+}
+
+func subAttr(id string) attribute.KeyValue {
+	return attribute.String("subscription.id", id)
+}
+
+func (t *TracedDB) GetCurrentBlock(ctx context.Context) int {
+	ctx, span := t.start(ctx, "GetCurrentBlock")
+	defer span.End()
+	return t.next.GetCurrentBlock(ctx)
+}
+
+func (t *TracedDB) SetCurrentBlock(ctx context.Context, currBlock int) {
+	ctx, span := t.start(ctx, "SetCurrentBlock", tracing.KeyBlock.Int(currBlock))
+	defer span.End()
+	t.next.SetCurrentBlock(ctx, currBlock)
+}
+
+func (t *TracedDB) GetLastProcessedTxIndex(ctx context.Context) int {
+	ctx, span := t.start(ctx, "GetLastProcessedTxIndex")
+	defer span.End()
+	return t.next.GetLastProcessedTxIndex(ctx)
+}
+
+func (t *TracedDB) SetLastProcessedTxIndex(ctx context.Context, idx int) {
+	ctx, span := t.start(ctx, "SetLastProcessedTxIndex")
+	defer span.End()
+	t.next.SetLastProcessedTxIndex(ctx, idx)
+}
+
+func (t *TracedDB) AddSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	ctx, span := t.start(ctx, "AddSubscriber", tenantAttr(tenant), addrAttr(addr))
+	defer span.End()
+	added := t.next.AddSubscriber(ctx, tenant, addr)
+	span.SetAttributes(attribute.Bool("added", added))
+	return added
+}
+
+func (t *TracedDB) RemoveSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	ctx, span := t.start(ctx, "RemoveSubscriber", tenantAttr(tenant), addrAttr(addr))
+	defer span.End()
+	removed := t.next.RemoveSubscriber(ctx, tenant, addr)
+	span.SetAttributes(attribute.Bool("removed", removed))
+	return removed
+}
+
+func (t *TracedDB) GetSubscribers(ctx context.Context) []models.Address {
+	ctx, span := t.start(ctx, "GetSubscribers")
+	defer span.End()
+	return t.next.GetSubscribers(ctx)
+}
+
+func (t *TracedDB) GetTenantSubscribers(ctx context.Context, tenant string) []models.Address {
+	ctx, span := t.start(ctx, "GetTenantSubscribers", tenantAttr(tenant))
+	defer span.End()
+	return t.next.GetTenantSubscribers(ctx, tenant)
+}
+
+func (t *TracedDB) AddTx(ctx context.Context, addr models.Address, tx models.Transaction) bool {
+	ctx, span := t.start(ctx, "AddTx", addrAttr(addr), attribute.String("tx.hash", tx.Hash))
+	defer span.End()
+	added := t.next.AddTx(ctx, addr, tx)
+	span.SetAttributes(attribute.Bool("added", added))
+	return added
+}
+
+func (t *TracedDB) AddressExists(ctx context.Context, addr models.Address) bool {
+	ctx, span := t.start(ctx, "AddressExists", addrAttr(addr))
+	defer span.End()
+	return t.next.AddressExists(ctx, addr)
+}
+
+func (t *TracedDB) IsSubscriber(ctx context.Context, tenant string, addr models.Address) bool {
+	ctx, span := t.start(ctx, "IsSubscriber", tenantAttr(tenant), addrAttr(addr))
+	defer span.End()
+	return t.next.IsSubscriber(ctx, tenant, addr)
+}
+
+func (t *TracedDB) GetTransactions(ctx context.Context, tenant string, addr models.Address) []models.Transaction {
+	ctx, span := t.start(ctx, "GetTransactions", tenantAttr(tenant), addrAttr(addr))
+	defer span.End()
+	txs := t.next.GetTransactions(ctx, tenant, addr)
+	span.SetAttributes(attribute.Int("txs", len(txs)))
+	return txs
+}
+
+func (t *TracedDB) AddSubscription(ctx context.Context, sub models.Subscription) (err error) {
+	ctx, span := t.start(ctx, "AddSubscription", tenantAttr(sub.Tenant), subAttr(sub.ID))
+	defer func() { tracing.End(span, err) }()
+	return t.next.AddSubscription(ctx, sub)
+}
+
+func (t *TracedDB) RemoveSubscription(ctx context.Context, tenant, id string) (err error) {
+	ctx, span := t.start(ctx, "RemoveSubscription", tenantAttr(tenant), subAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.RemoveSubscription(ctx, tenant, id)
+}
+
+func (t *TracedDB) GetSubscription(ctx context.Context, tenant, id string) (_ models.Subscription, err error) {
+	ctx, span := t.start(ctx, "GetSubscription", tenantAttr(tenant), subAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.GetSubscription(ctx, tenant, id)
+}
+
+func (t *TracedDB) GetSubscriptions(ctx context.Context, tenant string) []models.Subscription {
+	ctx, span := t.start(ctx, "GetSubscriptions", tenantAttr(tenant))
+	defer span.End()
+	return t.next.GetSubscriptions(ctx, tenant)
+}
+
+func (t *TracedDB) AllSubscriptions(ctx context.Context) []models.Subscription {
+	ctx, span := t.start(ctx, "AllSubscriptions")
+	defer span.End()
+	return t.next.AllSubscriptions(ctx)
+}
+
+func (t *TracedDB) AddMatch(ctx context.Context, id string, tx models.Transaction) bool {
+	ctx, span := t.start(ctx, "AddMatch", subAttr(id), attribute.String("tx.hash", tx.Hash))
+	defer span.End()
+	added := t.next.AddMatch(ctx, id, tx)
+	span.SetAttributes(attribute.Bool("added", added))
+	return added
+}
+
+func (t *TracedDB) GetMatches(ctx context.Context, tenant, id string) (_ []models.Transaction, err error) {
+	ctx, span := t.start(ctx, "GetMatches", tenantAttr(tenant), subAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.GetMatches(ctx, tenant, id)
+}
+
+func (t *TracedDB) BatchGetMatches(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction {
+	ctx, span := t.start(ctx, "BatchGetMatches", tenantAttr(tenant), attribute.Int("subscriptions", len(ids)))
+	defer span.End()
+	return t.next.BatchGetMatches(ctx, tenant, ids)
+}
+
+func (t *TracedDB) PurgeAbove(ctx context.Context, block int) int {
+	ctx, span := t.start(ctx, "PurgeAbove", tracing.KeyBlock.Int(block))
+	defer span.End()
+	purged := t.next.PurgeAbove(ctx, block)
+	span.SetAttributes(attribute.Int("purged", purged))
+	return purged
+}
+
+// Scan - the span covers the whole iteration, from the first pull to the last
+func (t *TracedDB) Scan(ctx context.Context, q TxQuery) iter.Seq2[models.Address, models.Transaction] {
+	return func(yield func(models.Address, models.Transaction) bool) {
+		ctx, span := t.start(ctx, "Scan",
+			tenantAttr(q.Tenant),
+			attribute.Int("addresses", len(q.Addresses)),
+			attribute.Int("block.from", q.FromBlock),
+			attribute.Int("block.to", q.ToBlock),
+		)
+		defer span.End()
+		rows := 0
+		defer func() { span.SetAttributes(attribute.Int("rows", rows)) }()
+		for addr, tx := range t.next.Scan(ctx, q) {
+			rows++
+			if !yield(addr, tx) {
+				return
+			}
+		}
+	}
+}
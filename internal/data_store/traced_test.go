@@ -0,0 +1,31 @@
+package data_store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedDataStore(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	db := NewTracedDataStore(NewDataStore(), tp)
+
+	addr := models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
+	db.AddSubscriber(ctx, models.DefaultTenant, addr)
+	_, err := db.GetMatches(ctx, models.DefaultTenant, "missing")
+	require.ErrorIs(t, err, ErrSubscriptionNotFound)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "store.AddSubscriber", spans[0].Name)
+	require.Equal(t, codes.Unset, spans[0].Status.Code)
+	require.Equal(t, "store.GetMatches", spans[1].Name)
+	require.Equal(t, codes.Error, spans[1].Status.Code)
+}
@@ -0,0 +1,249 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid export format")
+	ErrInvalidColumn = errors.New("invalid export column")
+)
+
+type Column string
+
+const (
+	ColumnAddress Column = "address"
+	ColumnBlock   Column = "block"
+	ColumnHash    Column = "hash"
+	ColumnFrom    Column = "from"
+	ColumnTo      Column = "to"
+	// ColumnValue - value in ETH as a decimal
+	ColumnValue Column = "value"
+	// ColumnValueWei - value in wei as a decimal integer
+	ColumnValueWei Column = "value_wei"
+	ColumnTxIndex  Column = "tx_index"
+	ColumnLogIndex Column = "log_index"
+	ColumnInput    Column = "input"
+)
+
+// Columns - every column in its default order
+var Columns = []Column{
+	ColumnAddress, ColumnBlock, ColumnHash, ColumnFrom, ColumnTo, ColumnValue, ColumnValueWei, ColumnTxIndex, ColumnLogIndex, ColumnInput,
+}
+
+// DefaultColumns - columns of an export that does not pick any
+var DefaultColumns = []Column{
+	ColumnAddress, ColumnBlock, ColumnHash, ColumnFrom, ColumnTo, ColumnValue,
+}
+
+var contentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// ParseFormat - validates a format name
+func ParseFormat(s string) (string, error) {
+	if _, ok := contentTypes[s]; !ok {
+		return "", fmt.Errorf("%w %q, want %s, %s or %s", ErrInvalidFormat, s, FormatJSON, FormatCSV, FormatNDJSON)
+	}
+	return s, nil
+}
+
+// Negotiate - format asked for by an Accept header, empty when none of the export formats is acceptable
+func Negotiate(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch strings.TrimSpace(mediaType) {
+		case "text/csv":
+			return FormatCSV
+		case "application/x-ndjson", "application/jsonl":
+			return FormatNDJSON
+		case "application/json":
+			return FormatJSON
+		}
+	}
+	return ""
+}
+
+// ContentType - media type of format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// ParseColumns - comma separated column names, DefaultColumns when s is empty
+func ParseColumns(s string) ([]Column, error) {
+	if s == "" {
+		return DefaultColumns, nil
+	}
+	columns := make([]Column, 0)
+	for _, name := range strings.Split(s, ",") {
+		column := Column(strings.TrimSpace(name))
+		if !slices.Contains(Columns, column) {
+			return nil, fmt.Errorf("%w %q", ErrInvalidColumn, name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// Writer - encodes transactions row by row
+type Writer interface {
+	// Write - one row, addr is the subscribed address the transaction was stored under
+	Write(addr models.Address, tx models.Transaction) error
+	// Flush - writes buffered rows to the underlying writer
+	Flush() error
+}
+
+// NewWriter - CSV writes a header row first, NDJSON a JSON object of the columns per line
+func NewWriter(w io.Writer, format string, columns []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := &csvWriter{w: csv.NewWriter(w), columns: columns}
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = string(column)
+		}
+		return cw, cw.w.Write(header)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	default:
+		return nil, fmt.Errorf("%w %q, want %s or %s", ErrInvalidFormat, format, FormatCSV, FormatNDJSON)
+	}
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	row     []string
+}
+
+func (c *csvWriter) Write(addr models.Address, tx models.Transaction) error {
+	c.row = c.row[:0]
+	for _, column := range c.columns {
+		c.row = append(c.row, value(column, addr, tx))
+	}
+	return c.w.Write(c.row)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	buf     []byte
+}
+
+// Write - keys are written in column order, which encoding a map would not keep
+func (n *ndjsonWriter) Write(addr models.Address, tx models.Transaction) error {
+	n.buf = append(n.buf[:0], '{')
+	for i, column := range n.columns {
+		if i > 0 {
+			n.buf = append(n.buf, ',')
+		}
+		n.buf = strconv.AppendQuote(n.buf, string(column))
+		n.buf = append(n.buf, ':')
+		v, err := json.Marshal(value(column, addr, tx))
+		if err != nil {
+			return err
+		}
+		n.buf = append(n.buf, v...)
+	}
+	n.buf = append(n.buf, '}', '\n')
+	_, err := n.w.Write(n.buf)
+	return err
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return n.w.Flush()
+}
+
+// value - hex quantities of the node are written as decimals
+func value(column Column, addr models.Address, tx models.Transaction) string {
+	switch column {
+	case ColumnAddress:
+		return string(addr)
+	case ColumnBlock:
+		return decimal(tx.BlockNumber)
+	case ColumnHash:
+		return tx.Hash
+	case ColumnFrom:
+		return string(tx.From)
+	case ColumnTo:
+		return string(tx.To)
+	case ColumnValue:
+		return FormatEth(tx.Value)
+	case ColumnValueWei:
+		if wei, ok := parseWei(tx.Value); ok {
+			return wei.String()
+		}
+		return ""
+	case ColumnTxIndex:
+		return decimal(tx.TransactionIndex)
+	case ColumnLogIndex:
+		return decimal(tx.LogIndex)
+	case ColumnInput:
+		return tx.Input
+	default:
+		return ""
+	}
+}
+
+func decimal(hex string) string {
+	n, err := helpers.ParseHexInt(hex)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func parseWei(s string) (*big.Int, bool) {
+	if s == "" {
+		return nil, false
+	}
+	return new(big.Int).SetString(s, 0)
+}
+
+var weiPerEth = big.NewInt(1_000_000_000_000_000_000)
+
+// FormatEth - a wei amount, hex or decimal, as ETH without trailing zeros: "1.5", "0.000000000000000001".
+// Empty for a missing or malformed amount.
+func FormatEth(wei string) string {
+	n, ok := parseWei(wei)
+	if !ok {
+		return ""
+	}
+	sign := ""
+	if n.Sign() < 0 {
+		sign = "-"
+		n = new(big.Int).Neg(n)
+	}
+	whole, frac := new(big.Int).QuoRem(n, weiPerEth, new(big.Int))
+	if frac.Sign() == 0 {
+		return sign + whole.String()
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%018s", frac.String()), "0")
+
+	return sign + whole.String() + "." + fraction
+}
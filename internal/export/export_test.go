@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+const addr = models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
+
+var testTx = models.Transaction{
+	BlockNumber:      "0x10",
+	From:             addr,
+	Hash:             "0xabc",
+	To:               "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5",
+	TransactionIndex: "0x2",
+	Value:            "0x14d1120d7b160000", // 1.5 ETH
+}
+
+func TestFormatEth(t *testing.T) {
+	tests := map[string]string{
+		"0x0":                  "0",
+		"0xde0b6b3a7640000":    "1",
+		"0x14d1120d7b160000":   "1.5",
+		"0x1":                  "0.000000000000000001",
+		"2500000000000000000":  "2.5",
+		"-1000000000000000000": "-1",
+		"":                     "",
+		"0xzz":                 "",
+	}
+	for wei, want := range tests {
+		require.Equal(t, want, FormatEth(wei), wei)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	columns, err := ParseColumns("block,hash, value,value_wei,log_index")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, FormatCSV, columns)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(addr, testTx))
+	require.NoError(t, w.Flush())
+
+	require.Equal(t, "block,hash,value,value_wei,log_index\n16,0xabc,1.5,1500000000000000000,\n", buf.String())
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, FormatNDJSON, DefaultColumns)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(addr, testTx))
+	require.NoError(t, w.Write(addr, models.Transaction{Hash: "0xdef"}))
+	require.NoError(t, w.Flush())
+
+	require.Equal(t,
+		`{"address":"`+string(addr)+`","block":"16","hash":"0xabc","from":"`+string(addr)+`","to":"0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5","value":"1.5"}`+"\n"+
+			`{"address":"`+string(addr)+`","block":"","hash":"0xdef","from":"","to":"","value":""}`+"\n",
+		buf.String())
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := ParseColumns("hash,gas")
+	require.ErrorIs(t, err, ErrInvalidColumn)
+	_, err = ParseFormat("xml")
+	require.ErrorIs(t, err, ErrInvalidFormat)
+	_, err = NewWriter(&bytes.Buffer{}, FormatJSON, DefaultColumns)
+	require.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestNegotiate(t *testing.T) {
+	require.Equal(t, FormatCSV, Negotiate("text/csv"))
+	require.Equal(t, FormatNDJSON, Negotiate("text/html;q=0.9, application/x-ndjson"))
+	require.Equal(t, FormatJSON, Negotiate("application/json; charset=utf-8"))
+	require.Equal(t, "", Negotiate("*/*"))
+	require.Equal(t, "", Negotiate(""))
+}
@@ -0,0 +1,705 @@
+// Package fakenode - an Ethereum JSON-RPC node over an in-memory chain, served with httptest for tests.
+// Tests append blocks, reorganize the chain and inject failures, latency and rate limits while a client talks to it.
+package fakenode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+// JSON-RPC error codes the node answers with
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+	// CodeRateLimited - code of rate limited requests, as public providers send it
+	CodeRateLimited = -32005
+)
+
+const (
+	jsonRpcVersion = "2.0"
+	// genesisTime, blockTime - timestamps of the blocks, block n is mined blockTime after block n-1
+	genesisTime = 1_700_000_000
+	blockTime   = 12
+	gasPerTx    = 21_000
+)
+
+// Tx - a transaction to mine, the node fills in its hash, block and index
+type Tx struct {
+	// Hash - empty for one derived from the transaction, set it to include the same transaction again after a reorg
+	Hash string
+	From models.Address
+	// To - empty for a contract creation
+	To models.Address
+	// Value - wei as a hex number, 0x0 when empty
+	Value string
+	Input string
+	Logs  []Log
+	// Failed - the receipt reports the transaction reverted, its logs are not emitted
+	Failed bool
+}
+
+// Log - an event emitted by a transaction
+type Log struct {
+	Address models.Address
+	Topics  []string
+	Data    string
+}
+
+type block struct {
+	number     int
+	hash       string
+	parentHash string
+	txs        []minedTx
+}
+
+type minedTx struct {
+	Tx
+	block *block
+	index int
+	// firstLog - index of the transaction's first log in its block
+	firstLog int
+}
+
+// Node - a JSON-RPC node serving eth_blockNumber, eth_getBlockByNumber, eth_getLogs and eth_getTransactionReceipt,
+// single or batched, over a chain that starts with an empty genesis block
+type Node struct {
+	tb     testing.TB
+	server *httptest.Server
+
+	mu    sync.Mutex
+	chain []*block
+	txs   map[string]*minedTx
+	// fork - bumped by a reorg so the blocks mined after it get new hashes
+	fork   int
+	minted int
+
+	latency     time.Duration
+	httpFaults  []int
+	rpcFaults   map[string][]client.RPCError
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	windowCalls int
+
+	requests int
+	calls    map[string]int
+}
+
+// New - a node serving a chain of the genesis block, closed when the test ends
+func New(tb testing.TB) *Node {
+	tb.Helper()
+	n := &Node{
+		tb:        tb,
+		txs:       make(map[string]*minedTx),
+		rpcFaults: make(map[string][]client.RPCError),
+		calls:     make(map[string]int),
+	}
+	n.chain = []*block{{number: 0, hash: hash("block", 0, 0), parentHash: zeroHash()}}
+	n.server = httptest.NewServer(n)
+	tb.Cleanup(n.server.Close)
+
+	return n
+}
+
+// URL - endpoint of the node
+func (n *Node) URL() string {
+	return n.server.URL
+}
+
+// Head - number of the latest block
+func (n *Node) Head() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.head().number
+}
+
+func (n *Node) head() *block {
+	return n.chain[len(n.chain)-1]
+}
+
+// AddBlock - mines a block of txs on top of the chain, returns its number
+func (n *Node) AddBlock(txs ...Tx) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	parent := n.head()
+	b := &block{
+		number:     parent.number + 1,
+		parentHash: parent.hash,
+	}
+	b.hash = hash("block", b.number, n.fork, parent.hash)
+	logs := 0
+	for i, tx := range txs {
+		if tx.Hash == "" {
+			n.minted++
+			tx.Hash = hash("tx", n.minted, string(tx.From), string(tx.To), tx.Value, tx.Input)
+		}
+		tx.Hash = strings.ToLower(tx.Hash)
+		tx.From, tx.To = lower(tx.From), lower(tx.To)
+		if _, ok := n.txs[tx.Hash]; ok {
+			n.tb.Fatalf("fakenode: transaction %s is in the chain already", tx.Hash)
+		}
+		b.txs = append(b.txs, minedTx{Tx: tx, block: b, index: i, firstLog: logs})
+		if !tx.Failed {
+			logs += len(tx.Logs)
+		}
+	}
+	for i := range b.txs {
+		n.txs[b.txs[i].Hash] = &b.txs[i]
+	}
+	n.chain = append(n.chain, b)
+
+	return b.number
+}
+
+// Mine - mines count empty blocks, returns the new head
+func (n *Node) Mine(count int) int {
+	head := 0
+	for range count {
+		head = n.AddBlock()
+	}
+	return head
+}
+
+// Reorg - drops the latest depth blocks, the blocks added after it replace them with new hashes
+func (n *Node) Reorg(depth int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if depth < 1 || depth >= len(n.chain) {
+		n.tb.Fatalf("fakenode: reorg of %d blocks on a chain of %d blocks after genesis", depth, len(n.chain)-1)
+	}
+	for _, b := range n.chain[len(n.chain)-depth:] {
+		for _, tx := range b.txs {
+			delete(n.txs, tx.Hash)
+		}
+	}
+	n.chain = n.chain[:len(n.chain)-depth]
+	n.fork++
+}
+
+// Transactions - the transactions of a block as the client parses them, nil for a block the chain does not have
+func (n *Node) Transactions(number int) []models.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	b := n.block(number)
+	if b == nil {
+		return nil
+	}
+	txs := make([]models.Transaction, 0, len(b.txs))
+	for _, tx := range b.txs {
+		txs = append(txs, models.Transaction{
+			BlockNumber:      helpers.FormatHexInt(b.number),
+			From:             tx.From,
+			Hash:             tx.Hash,
+			To:               tx.To,
+			TransactionIndex: helpers.FormatHexInt(tx.index),
+			Value:            value(tx.Tx),
+			Input:            input(tx.Tx),
+		})
+	}
+
+	return txs
+}
+
+func (n *Node) block(number int) *block {
+	if number < 0 || number >= len(n.chain) {
+		return nil
+	}
+	return n.chain[number]
+}
+
+// SetLatency - delay of every response, a request whose context ends first gets none
+func (n *Node) SetLatency(d time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.latency = d
+}
+
+// FailRequests - the next count requests are answered with status and its text instead of JSON
+func (n *Node) FailRequests(count, status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for range count {
+		n.httpFaults = append(n.httpFaults, status)
+	}
+}
+
+// FailCalls - the next count calls of method, in batches too, get err instead of their result
+func (n *Node) FailCalls(method string, count int, err client.RPCError) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for range count {
+		n.rpcFaults[method] = append(n.rpcFaults[method], err)
+	}
+}
+
+// SetRateLimit - requests beyond limit within a window of per are answered 429, a batch counts once. 0 lifts the limit.
+func (n *Node) SetRateLimit(limit int, per time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.limit = limit
+	n.window = per
+	n.windowStart = time.Time{}
+	n.windowCalls = 0
+}
+
+// Requests - number of HTTP requests received, rejected ones included
+func (n *Node) Requests() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests
+}
+
+// Calls - number of calls of method answered, with a result or an error
+func (n *Node) Calls(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      json.RawMessage  `json:"id"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *client.RPCError `json:"error,omitempty"`
+}
+
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	n.requests++
+	latency := n.latency
+	n.mu.Unlock()
+	// read first, the server notices a client giving up only once the body is consumed
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests are POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.rateLimited() {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(n.window.Seconds()))))
+		writeJSON(w, http.StatusTooManyRequests, rpcResponse{
+			JSONRPC: jsonRpcVersion,
+			ID:      json.RawMessage("null"),
+			Error:   &client.RPCError{Code: CodeRateLimited, Message: "rate limit exceeded"},
+		})
+		return
+	}
+	if len(n.httpFaults) > 0 {
+		status := n.httpFaults[0]
+		n.httpFaults = n.httpFaults[1:]
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeJSON(w, http.StatusOK, errorResponse(nil, CodeParseError, err.Error()))
+			return
+		}
+		if len(batch) == 0 {
+			writeJSON(w, http.StatusOK, errorResponse(nil, CodeInvalidRequest, "empty batch"))
+			return
+		}
+		responses := make([]rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			responses = append(responses, n.answer(raw))
+		}
+		writeJSON(w, http.StatusOK, responses)
+		return
+	}
+	writeJSON(w, http.StatusOK, n.answer(body))
+}
+
+// rateLimited - counts a request in the current window, true when it is over the limit
+func (n *Node) rateLimited() bool {
+	if n.limit <= 0 {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(n.windowStart) >= n.window {
+		n.windowStart = now
+		n.windowCalls = 0
+	}
+	n.windowCalls++
+	return n.windowCalls > n.limit
+}
+
+func (n *Node) answer(raw json.RawMessage) rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, CodeParseError, err.Error())
+	}
+	if req.JSONRPC != jsonRpcVersion || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, "invalid request")
+	}
+	handler, ok := methods[req.Method]
+	if !ok {
+		return errorResponse(req.ID, CodeMethodNotFound, fmt.Sprintf("the method %s does not exist/is not available", req.Method))
+	}
+	n.calls[req.Method]++
+	if faults := n.rpcFaults[req.Method]; len(faults) > 0 {
+		n.rpcFaults[req.Method] = faults[1:]
+		return rpcResponse{JSONRPC: jsonRpcVersion, ID: id(req.ID), Error: &faults[0]}
+	}
+	result, rpcErr := handler(n, req.Params)
+	if rpcErr != nil {
+		return rpcResponse{JSONRPC: jsonRpcVersion, ID: id(req.ID), Error: rpcErr}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, CodeServerError, err.Error())
+	}
+
+	return rpcResponse{JSONRPC: jsonRpcVersion, ID: id(req.ID), Result: data}
+}
+
+func errorResponse(reqID json.RawMessage, code int, message string) rpcResponse {
+	return rpcResponse{
+		JSONRPC: jsonRpcVersion,
+		ID:      id(reqID),
+		Error:   &client.RPCError{Code: code, Message: message},
+	}
+}
+
+func id(reqID json.RawMessage) json.RawMessage {
+	if len(reqID) == 0 {
+		return json.RawMessage("null")
+	}
+	return reqID
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// methods - handlers of the supported methods, called with the node locked
+var methods = map[string]func(n *Node, params json.RawMessage) (any, *client.RPCError){
+	"eth_blockNumber":           (*Node).blockNumber,
+	"eth_getBlockByNumber":      (*Node).getBlockByNumber,
+	"eth_getTransactionReceipt": (*Node).getTransactionReceipt,
+	"eth_getLogs":               (*Node).getLogs,
+}
+
+func (n *Node) blockNumber(json.RawMessage) (any, *client.RPCError) {
+	return helpers.FormatHexInt(n.head().number), nil
+}
+
+func (n *Node) getBlockByNumber(params json.RawMessage) (any, *client.RPCError) {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 2 {
+		return nil, invalidParams("want [block, fullTransactions]")
+	}
+	var tag string
+	var full bool
+	if json.Unmarshal(args[0], &tag) != nil || json.Unmarshal(args[1], &full) != nil {
+		return nil, invalidParams("want [block, fullTransactions]")
+	}
+	number, rpcErr := n.blockTag(tag)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	b := n.block(number)
+	if b == nil {
+		return nil, nil
+	}
+
+	txs := make([]any, 0, len(b.txs))
+	for _, tx := range b.txs {
+		if full {
+			txs = append(txs, txJSON(tx))
+		} else {
+			txs = append(txs, tx.Hash)
+		}
+	}
+	return map[string]any{
+		"number":       helpers.FormatHexInt(b.number),
+		"hash":         b.hash,
+		"parentHash":   b.parentHash,
+		"timestamp":    helpers.FormatHexInt(genesisTime + b.number*blockTime),
+		"gasUsed":      helpers.FormatHexInt(len(b.txs) * gasPerTx),
+		"transactions": txs,
+		"uncles":       []string{},
+	}, nil
+}
+
+// blockTag - number of a block parameter, a hex number or a tag. Safe and finalized blocks are the head.
+func (n *Node) blockTag(tag string) (int, *client.RPCError) {
+	switch tag {
+	case "latest", "pending", "safe", "finalized":
+		return n.head().number, nil
+	case "earliest":
+		return 0, nil
+	}
+	number, err := helpers.ParseHexInt(tag)
+	if err != nil || !strings.HasPrefix(tag, "0x") {
+		return 0, invalidParams(fmt.Sprintf("invalid block %q", tag))
+	}
+	return number, nil
+}
+
+func (n *Node) getTransactionReceipt(params json.RawMessage) (any, *client.RPCError) {
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return nil, invalidParams("want [transactionHash]")
+	}
+	tx, ok := n.txs[strings.ToLower(args[0])]
+	if !ok {
+		return nil, nil
+	}
+
+	status := "0x1"
+	logs := make([]any, 0, len(tx.Logs))
+	if tx.Failed {
+		// a reverted transaction emits no logs
+		status = "0x0"
+	} else {
+		for i := range tx.Logs {
+			logs = append(logs, logJSON(tx, i))
+		}
+	}
+	var contract any
+	if tx.To == "" {
+		contract = "0x" + hash("contract", tx.Hash)[26:]
+	}
+	return map[string]any{
+		"transactionHash":   tx.Hash,
+		"transactionIndex":  helpers.FormatHexInt(tx.index),
+		"blockHash":         tx.block.hash,
+		"blockNumber":       helpers.FormatHexInt(tx.block.number),
+		"from":              tx.From,
+		"to":                optional(tx.To),
+		"contractAddress":   contract,
+		"cumulativeGasUsed": helpers.FormatHexInt((tx.index + 1) * gasPerTx),
+		"gasUsed":           helpers.FormatHexInt(gasPerTx),
+		"status":            status,
+		"logs":              logs,
+	}, nil
+}
+
+// logFilter - eth_getLogs parameter, Address is one address or a list, each topic a hash, a list of hashes or null
+type logFilter struct {
+	FromBlock string            `json:"fromBlock"`
+	ToBlock   string            `json:"toBlock"`
+	BlockHash string            `json:"blockHash"`
+	Address   json.RawMessage   `json:"address"`
+	Topics    []json.RawMessage `json:"topics"`
+}
+
+func (n *Node) getLogs(params json.RawMessage) (any, *client.RPCError) {
+	var args []logFilter
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+		return nil, invalidParams("want [filter]")
+	}
+	filter := args[0]
+	addresses, err := oneOrMany(filter.Address)
+	if err != nil {
+		return nil, invalidParams("address: " + err.Error())
+	}
+	topics := make([][]string, len(filter.Topics))
+	for i, raw := range filter.Topics {
+		if topics[i], err = oneOrMany(raw); err != nil {
+			return nil, invalidParams(fmt.Sprintf("topics[%d]: %v", i, err))
+		}
+	}
+
+	from, to, rpcErr := n.logRange(filter)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	logs := make([]any, 0)
+	for number := from; number <= to; number++ {
+		for _, tx := range n.block(number).txs {
+			if tx.Failed {
+				continue
+			}
+			for i, log := range tx.Logs {
+				if matchesLog(log, addresses, topics) {
+					logs = append(logs, logJSON(&tx, i))
+				}
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+// logRange - blocks a filter covers, clamped to the chain
+func (n *Node) logRange(filter logFilter) (from, to int, rpcErr *client.RPCError) {
+	if filter.BlockHash != "" {
+		if filter.FromBlock != "" || filter.ToBlock != "" {
+			return 0, 0, invalidParams("blockHash excludes fromBlock and toBlock")
+		}
+		for _, b := range n.chain {
+			if b.hash == strings.ToLower(filter.BlockHash) {
+				return b.number, b.number, nil
+			}
+		}
+		return 0, 0, &client.RPCError{Code: CodeServerError, Message: "unknown block"}
+	}
+	from, to = n.head().number, n.head().number
+	if filter.FromBlock != "" {
+		if from, rpcErr = n.blockTag(filter.FromBlock); rpcErr != nil {
+			return 0, 0, rpcErr
+		}
+	}
+	if filter.ToBlock != "" {
+		if to, rpcErr = n.blockTag(filter.ToBlock); rpcErr != nil {
+			return 0, 0, rpcErr
+		}
+	}
+
+	return from, min(to, n.head().number), nil
+}
+
+// oneOrMany - a JSON string or list of strings lower cased, nil for null
+func oneOrMany(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return []string{strings.ToLower(one)}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, fmt.Errorf("want a string or a list of strings")
+	}
+	for i := range many {
+		many[i] = strings.ToLower(many[i])
+	}
+	return many, nil
+}
+
+// matchesLog - log is from one of addresses and each topic position is one of its alternatives, nil matches anything
+func matchesLog(log Log, addresses []string, topics [][]string) bool {
+	if addresses != nil && !slices.Contains(addresses, strings.ToLower(string(log.Address))) {
+		return false
+	}
+	if len(topics) > len(log.Topics) {
+		return false
+	}
+	for i, alternatives := range topics {
+		if alternatives != nil && !slices.Contains(alternatives, strings.ToLower(log.Topics[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func txJSON(tx minedTx) map[string]any {
+	return map[string]any{
+		"blockHash":        tx.block.hash,
+		"blockNumber":      helpers.FormatHexInt(tx.block.number),
+		"from":             tx.From,
+		"gas":              helpers.FormatHexInt(gasPerTx),
+		"gasPrice":         "0x3b9aca00",
+		"hash":             tx.Hash,
+		"input":            input(tx.Tx),
+		"to":               optional(tx.To),
+		"transactionIndex": helpers.FormatHexInt(tx.index),
+		"type":             "0x0",
+		"value":            value(tx.Tx),
+	}
+}
+
+func logJSON(tx *minedTx, i int) map[string]any {
+	log := tx.Logs[i]
+	data := log.Data
+	if data == "" {
+		data = "0x"
+	}
+	return map[string]any{
+		"address":          lower(log.Address),
+		"topics":           log.Topics,
+		"data":             data,
+		"blockNumber":      helpers.FormatHexInt(tx.block.number),
+		"blockHash":        tx.block.hash,
+		"transactionHash":  tx.Hash,
+		"transactionIndex": helpers.FormatHexInt(tx.index),
+		"logIndex":         helpers.FormatHexInt(tx.firstLog + i),
+		"removed":          false,
+	}
+}
+
+func value(tx Tx) string {
+	if tx.Value == "" {
+		return "0x0"
+	}
+	return tx.Value
+}
+
+func input(tx Tx) string {
+	if tx.Input == "" {
+		return "0x"
+	}
+	return tx.Input
+}
+
+// optional - null for an empty address, as nodes send the recipient of a contract creation
+func optional(addr models.Address) any {
+	if addr == "" {
+		return nil
+	}
+	return addr
+}
+
+func lower(addr models.Address) models.Address {
+	return models.Address(strings.ToLower(string(addr)))
+}
+
+func invalidParams(msg string) *client.RPCError {
+	return &client.RPCError{Code: CodeInvalidParams, Message: "invalid params: " + msg}
+}
+
+// hash - a 32 byte hex hash of parts, deterministic so runs of a test see the same chain
+func hash(parts ...any) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%#v", parts))
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+func zeroHash() string {
+	return "0x" + strings.Repeat("0", 64)
+}
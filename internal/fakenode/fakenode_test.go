@@ -0,0 +1,257 @@
+package fakenode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	alice    = "0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497"
+	bob      = "0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5"
+	token    = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	transfer = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+)
+
+type response struct {
+	ID     json.RawMessage  `json:"id"`
+	Result json.RawMessage  `json:"result"`
+	Error  *client.RPCError `json:"error"`
+}
+
+// call - posts a request for method to the node and decodes the result into out
+func call(t *testing.T, n *Node, method, params string, out any) *client.RPCError {
+	t.Helper()
+	var resp response
+	require.Equal(t, http.StatusOK, post(t, n, `{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":`+params+`}`, &resp))
+	if resp.Error != nil {
+		return resp.Error
+	}
+	require.NoError(t, json.Unmarshal(resp.Result, out))
+	return nil
+}
+
+// post - sends body to the node, decodes the answer into out and returns the status
+func post(t *testing.T, n *Node, body string, out any) int {
+	t.Helper()
+	resp, err := http.Post(n.URL(), "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+// topic - addr as an indexed event parameter
+func topic(addr string) string {
+	return "0x" + strings.Repeat("0", 24) + addr[2:]
+}
+
+func TestNode_Client(t *testing.T) {
+	ctx := context.Background()
+	n := New(t)
+	c := client.NewClient(n.URL())
+
+	head, err := c.GetBlockNumber(ctx)
+	require.NoError(t, err)
+	require.Zero(t, head)
+
+	n.Mine(2)
+	number := n.AddBlock(Tx{From: alice, To: bob, Value: "0xde0b6b3a7640000"}, Tx{From: "0x95222290DD7278AA3DDD389CC1E1D165CC4BAFE5"})
+	require.Equal(t, 3, number)
+	head, err = c.GetBlockNumber(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, head)
+
+	txs, err := c.GetTxsFromBlock(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, n.Transactions(3), txs)
+	assert.Equal(t, "0x1", txs[1].TransactionIndex)
+	assert.Equal(t, bob, string(txs[1].From))
+	assert.Empty(t, txs[1].To)
+	assert.Equal(t, "0x0", txs[1].Value)
+
+	// a block after the head is null, the client reads it as empty
+	txs, err = c.GetTxsFromBlock(ctx, 4)
+	require.NoError(t, err)
+	assert.Empty(t, txs)
+	assert.Equal(t, 2, n.Calls("eth_getBlockByNumber"))
+	assert.Equal(t, 4, n.Requests())
+}
+
+func TestNode_Reorg(t *testing.T) {
+	n := New(t)
+	n.Mine(2)
+	n.AddBlock(Tx{From: alice, To: bob, Value: "0x1"})
+	n.AddBlock(Tx{From: bob, To: alice})
+	kept, dropped := n.Transactions(3)[0], n.Transactions(4)[0]
+	var old struct {
+		Hash string `json:"hash"`
+	}
+	require.Nil(t, call(t, n, "eth_getBlockByNumber", `["0x3",false]`, &old))
+
+	n.Reorg(2)
+	require.Equal(t, 2, n.Head())
+	require.Nil(t, n.Transactions(3))
+	// the fork includes one of the transactions again, in a block of another hash
+	n.AddBlock(Tx{Hash: kept.Hash, From: alice, To: bob, Value: "0x1"})
+	require.Equal(t, kept, n.Transactions(3)[0])
+
+	var forked struct {
+		Hash         string   `json:"hash"`
+		Transactions []string `json:"transactions"`
+	}
+	require.Nil(t, call(t, n, "eth_getBlockByNumber", `["latest",false]`, &forked))
+	assert.NotEqual(t, old.Hash, forked.Hash)
+	assert.Equal(t, []string{kept.Hash}, forked.Transactions)
+
+	var receipt map[string]any
+	require.Nil(t, call(t, n, "eth_getTransactionReceipt", `["`+kept.Hash+`"]`, &receipt))
+	assert.Equal(t, forked.Hash, receipt["blockHash"])
+	require.Nil(t, call(t, n, "eth_getTransactionReceipt", `["`+dropped.Hash+`"]`, &receipt))
+	assert.Nil(t, receipt)
+}
+
+func TestNode_LogsAndReceipts(t *testing.T) {
+	n := New(t)
+	sent := Log{Address: token, Topics: []string{transfer, topic(alice), topic(bob)}, Data: "0x01"}
+	received := Log{Address: token, Topics: []string{transfer, topic(bob), topic(alice)}, Data: "0x02"}
+	n.AddBlock(Tx{From: alice, To: token, Logs: []Log{sent, received}})
+	n.AddBlock(
+		Tx{From: alice, To: token, Logs: []Log{sent}, Failed: true},
+		Tx{From: bob, Input: "0x6080"},
+		Tx{From: bob, To: token, Logs: []Log{received}},
+	)
+	blocks := [][]string{{n.Transactions(1)[0].Hash}, {n.Transactions(2)[0].Hash, n.Transactions(2)[1].Hash, n.Transactions(2)[2].Hash}}
+
+	type log struct {
+		TransactionHash string   `json:"transactionHash"`
+		LogIndex        string   `json:"logIndex"`
+		Topics          []string `json:"topics"`
+	}
+	var logs []log
+	require.Nil(t, call(t, n, "eth_getLogs", `[{"fromBlock":"earliest","address":"`+strings.ToUpper(token)+`"}]`, &logs))
+	// the reverted transaction has no logs
+	require.Len(t, logs, 3)
+	assert.Equal(t, []string{"0x0", "0x1", "0x0"}, []string{logs[0].LogIndex, logs[1].LogIndex, logs[2].LogIndex})
+	assert.Equal(t, blocks[1][2], logs[2].TransactionHash)
+
+	// transfers received by alice: any first topic, the sender is any, the recipient alice or nobody
+	require.Nil(t, call(t, n, "eth_getLogs", `[{"fromBlock":"0x1","toBlock":"0x9","topics":[null,null,["`+topic(alice)+`","0x00"]]}]`, &logs))
+	require.Len(t, logs, 2)
+	assert.Equal(t, topic(alice), logs[0].Topics[2])
+
+	// only the latest block without a range
+	require.Nil(t, call(t, n, "eth_getLogs", `[{"topics":["`+transfer+`"]}]`, &logs))
+	require.Len(t, logs, 1)
+
+	var header struct {
+		Hash string `json:"hash"`
+	}
+	require.Nil(t, call(t, n, "eth_getBlockByNumber", `["0x1",false]`, &header))
+	require.Nil(t, call(t, n, "eth_getLogs", `[{"blockHash":"`+header.Hash+`"}]`, &logs))
+	require.Len(t, logs, 2)
+	rpcErr := call(t, n, "eth_getLogs", `[{"blockHash":"0x01"}]`, &logs)
+	require.NotNil(t, rpcErr)
+	assert.Equal(t, CodeServerError, rpcErr.Code)
+	rpcErr = call(t, n, "eth_getLogs", `[{"address":7}]`, &logs)
+	require.NotNil(t, rpcErr)
+	assert.Equal(t, CodeInvalidParams, rpcErr.Code)
+
+	var receipt struct {
+		Status          string  `json:"status"`
+		ContractAddress *string `json:"contractAddress"`
+		Logs            []log   `json:"logs"`
+	}
+	require.Nil(t, call(t, n, "eth_getTransactionReceipt", `["`+blocks[0][0]+`"]`, &receipt))
+	assert.Equal(t, "0x1", receipt.Status)
+	assert.Len(t, receipt.Logs, 2)
+	assert.Nil(t, receipt.ContractAddress)
+	require.Nil(t, call(t, n, "eth_getTransactionReceipt", `["`+blocks[1][0]+`"]`, &receipt))
+	assert.Equal(t, "0x0", receipt.Status)
+	assert.Empty(t, receipt.Logs)
+	require.Nil(t, call(t, n, "eth_getTransactionReceipt", `["`+blocks[1][1]+`"]`, &receipt))
+	require.NotNil(t, receipt.ContractAddress)
+	assert.Len(t, *receipt.ContractAddress, 42)
+}
+
+func TestNode_Batch(t *testing.T) {
+	n := New(t)
+	n.Mine(5)
+
+	var responses []response
+	require.Equal(t, http.StatusOK, post(t, n, `[
+		{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]},
+		{"jsonrpc":"2.0","id":"b","method":"eth_getBlockByNumber","params":["0x2",true]},
+		{"jsonrpc":"2.0","id":3,"method":"eth_sendRawTransaction","params":["0x00"]},
+		{"jsonrpc":"2.0","id":4,"method":"eth_getBlockByNumber","params":["two",true]},
+		{"id":5,"method":"eth_blockNumber"}
+	]`, &responses))
+	require.Len(t, responses, 5)
+	assert.JSONEq(t, `"0x5"`, string(responses[0].Result))
+	assert.JSONEq(t, `"b"`, string(responses[1].ID))
+	assert.Contains(t, string(responses[1].Result), `"number":"0x2"`)
+	for i, code := range map[int]int{2: CodeMethodNotFound, 3: CodeInvalidParams, 4: CodeInvalidRequest} {
+		require.NotNil(t, responses[i].Error, i)
+		assert.Equal(t, code, responses[i].Error.Code, i)
+	}
+
+	var single response
+	post(t, n, `[]`, &single)
+	require.NotNil(t, single.Error)
+	assert.Equal(t, CodeInvalidRequest, single.Error.Code)
+	post(t, n, `{"jsonrpc":`, &single)
+	assert.Equal(t, CodeParseError, single.Error.Code)
+}
+
+func TestNode_Faults(t *testing.T) {
+	ctx := context.Background()
+	n := New(t)
+	n.Mine(1)
+	c := client.NewClient(n.URL())
+
+	n.FailCalls("eth_blockNumber", 1, client.RPCError{Code: CodeServerError, Message: "header not found"})
+	_, err := c.GetBlockNumber(ctx)
+	require.ErrorContains(t, err, "header not found")
+	_, err = c.GetBlockNumber(ctx)
+	require.NoError(t, err)
+
+	n.FailRequests(1, http.StatusBadGateway)
+	_, err = c.GetTxsFromBlock(ctx, 1)
+	var httpErr *client.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusBadGateway, httpErr.Code)
+
+	n.SetLatency(time.Second)
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	_, err = c.GetBlockNumber(timeout)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	n.SetLatency(0)
+
+	n.SetRateLimit(2, time.Minute)
+	for range 2 {
+		_, err = c.GetBlockNumber(ctx)
+		require.NoError(t, err)
+	}
+	_, err = c.GetBlockNumber(ctx)
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.Code)
+	var limited response
+	require.Equal(t, http.StatusTooManyRequests, post(t, n, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`, &limited))
+	assert.Equal(t, CodeRateLimited, limited.Error.Code)
+
+	n.SetRateLimit(0, 0)
+	_, err = c.GetBlockNumber(ctx)
+	require.NoError(t, err)
+	require.False(t, errors.Is(err, context.DeadlineExceeded))
+}
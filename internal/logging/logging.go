@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys shared by every package so logs can be filtered on the same fields
+const (
+	KeyBlock        = "block"
+	KeyTxHash       = "tx_hash"
+	KeyAddress      = "address"
+	KeySubscription = "subscription_id"
+	KeyTenant       = "tenant"
+	KeyRPCMethod    = "rpc_method"
+	KeyEndpoint     = "endpoint"
+	KeyRequestID    = "request_id"
+	KeyError        = "error"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New - logger writing to w in format (text or json) at level (debug, info, warn, error)
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, want %s or %s", format, FormatText, FormatJSON)
+	}
+}
+
+// Discard - default logger of components that were not given one
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// Err - error attribute
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+type loggerKey struct{}
+
+// WithContext - attaches a request scoped logger to ctx
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext - request scoped logger or fallback when ctx carries none
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return fallback
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger, err := New(buf, FormatJSON, "warn")
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept", slog.String(KeyTxHash, "0x123"))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "kept", line["msg"])
+	require.Equal(t, "0x123", line[KeyTxHash])
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml", "info")
+	require.Error(t, err)
+	_, err = New(&bytes.Buffer{}, FormatText, "loud")
+	require.Error(t, err)
+}
+
+func TestFromContext(t *testing.T) {
+	fallback := Discard()
+	require.Same(t, fallback, FromContext(context.Background(), fallback))
+
+	scoped := Discard()
+	ctx := WithContext(context.Background(), scoped)
+	require.Same(t, scoped, FromContext(ctx, fallback))
+}
@@ -0,0 +1,188 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+type Matcher interface {
+	// Match - reports whether tx satisfies the rule
+	Match(tx models.Transaction) bool
+}
+
+type MatcherFunc func(tx models.Transaction) bool
+
+func (f MatcherFunc) Match(tx models.Transaction) bool {
+	return f(tx)
+}
+
+const (
+	selectorLen = len("0x") + 8
+)
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+// Involves - tx is sent from or to one of addrs
+func Involves(addrs ...models.Address) Matcher {
+	set := addressSet(addrs)
+	return MatcherFunc(func(tx models.Transaction) bool {
+		_, from := set[normalize(tx.From)]
+		_, to := set[normalize(tx.To)]
+		return from || to
+	})
+}
+
+// ValueAbove - tx transfers strictly more than wei
+func ValueAbove(wei *big.Int) Matcher {
+	return MatcherFunc(func(tx models.Transaction) bool {
+		value, ok := txValue(tx)
+		return ok && value.Cmp(wei) > 0
+	})
+}
+
+// ValueBelow - tx transfers strictly less than wei
+func ValueBelow(wei *big.Int) Matcher {
+	return MatcherFunc(func(tx models.Transaction) bool {
+		value, ok := txValue(tx)
+		return ok && value.Cmp(wei) < 0
+	})
+}
+
+// ContractCreation - tx has an empty recipient
+func ContractCreation() Matcher {
+	return MatcherFunc(func(tx models.Transaction) bool {
+		return tx.To == ""
+	})
+}
+
+// MethodSelector - tx input starts with one of the 4-byte selectors, e.g. 0xa9059cbb
+func MethodSelector(selectors ...string) Matcher {
+	normalized := make([]string, 0, len(selectors))
+	for _, s := range selectors {
+		normalized = append(normalized, strings.ToLower(s))
+	}
+	return MatcherFunc(func(tx models.Transaction) bool {
+		if len(tx.Input) < selectorLen {
+			return false
+		}
+		return slices.Contains(normalized, strings.ToLower(tx.Input[:selectorLen]))
+	})
+}
+
+// TokenContract - tx calls one of the contracts
+func TokenContract(contracts ...models.Address) Matcher {
+	set := addressSet(contracts)
+	return MatcherFunc(func(tx models.Transaction) bool {
+		if tx.To == "" || len(tx.Input) < selectorLen {
+			return false
+		}
+		_, ok := set[normalize(tx.To)]
+		return ok
+	})
+}
+
+// All - every matcher matches
+func All(matchers ...Matcher) Matcher {
+	return MatcherFunc(func(tx models.Transaction) bool {
+		for _, m := range matchers {
+			if !m.Match(tx) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Any - at least one matcher matches
+func Any(matchers ...Matcher) Matcher {
+	return MatcherFunc(func(tx models.Transaction) bool {
+		for _, m := range matchers {
+			if m.Match(tx) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FromRule - builds a Matcher from its serialized description
+func FromRule(rule models.Rule) (Matcher, error) {
+	switch rule.Type {
+	case models.RuleAddress, models.RuleTokenContract:
+		if len(rule.Addresses) == 0 {
+			return nil, fmt.Errorf("%w: %s requires addresses", ErrInvalidRule, rule.Type)
+		}
+		for _, addr := range rule.Addresses {
+			if !addr.Valid() {
+				return nil, fmt.Errorf("%w: %s: invalid address %q", ErrInvalidRule, rule.Type, addr)
+			}
+		}
+		if rule.Type == models.RuleAddress {
+			return Involves(rule.Addresses...), nil
+		}
+		return TokenContract(rule.Addresses...), nil
+	case models.RuleValueAbove, models.RuleValueBelow:
+		wei, ok := new(big.Int).SetString(rule.Value, 0)
+		if !ok || wei.Sign() < 0 {
+			return nil, fmt.Errorf("%w: %s: invalid value %q", ErrInvalidRule, rule.Type, rule.Value)
+		}
+		if rule.Type == models.RuleValueAbove {
+			return ValueAbove(wei), nil
+		}
+		return ValueBelow(wei), nil
+	case models.RuleContractCreation:
+		return ContractCreation(), nil
+	case models.RuleMethod:
+		if len(rule.Selectors) == 0 {
+			return nil, fmt.Errorf("%w: %s requires selectors", ErrInvalidRule, rule.Type)
+		}
+		for _, s := range rule.Selectors {
+			if len(s) != selectorLen || !strings.HasPrefix(s, "0x") {
+				return nil, fmt.Errorf("%w: %s: invalid selector %q", ErrInvalidRule, rule.Type, s)
+			}
+		}
+		return MethodSelector(rule.Selectors...), nil
+	case models.RuleAnd, models.RuleOr:
+		if len(rule.Rules) == 0 {
+			return nil, fmt.Errorf("%w: %s requires nested rules", ErrInvalidRule, rule.Type)
+		}
+		matchers := make([]Matcher, 0, len(rule.Rules))
+		for _, nested := range rule.Rules {
+			m, err := FromRule(nested)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		}
+		if rule.Type == models.RuleAnd {
+			return All(matchers...), nil
+		}
+		return Any(matchers...), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidRule, rule.Type)
+	}
+}
+
+func txValue(tx models.Transaction) (*big.Int, bool) {
+	if tx.Value == "" {
+		return nil, false
+	}
+	return new(big.Int).SetString(tx.Value, 0)
+}
+
+func addressSet(addrs []models.Address) map[models.Address]struct{} {
+	set := make(map[models.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[normalize(addr)] = struct{}{}
+	}
+	return set
+}
+
+func normalize(addr models.Address) models.Address {
+	return models.NormalizeAddress(string(addr))
+}
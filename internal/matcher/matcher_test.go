@@ -0,0 +1,135 @@
+package matcher
+
+import (
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	alice    = models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
+	bob      = models.Address("0x00000000219ab540356cbb839cbe05303d7705fa")
+	usdt     = models.Address("0xdac17f958d2ee523a2206206994597c13d831ec7")
+	transfer = "0xa9059cbb"
+)
+
+func TestFromRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  models.Rule
+		tx    models.Transaction
+		match bool
+	}{
+		{
+			name:  "address matches recipient case insensitively",
+			rule:  models.Rule{Type: models.RuleAddress, Addresses: []models.Address{alice}},
+			tx:    models.Transaction{From: bob, To: "0xB0BC44CA9EF6EB6F4EAAC6807C9F6307F8136497"},
+			match: true,
+		},
+		{
+			name:  "address does not match",
+			rule:  models.Rule{Type: models.RuleAddress, Addresses: []models.Address{alice}},
+			tx:    models.Transaction{From: bob, To: usdt},
+			match: false,
+		},
+		{
+			name:  "value above threshold",
+			rule:  models.Rule{Type: models.RuleValueAbove, Value: "1000000000000000000"},
+			tx:    models.Transaction{Value: "0xde0b6b3a7640001"},
+			match: true,
+		},
+		{
+			name:  "value equal to threshold is not above",
+			rule:  models.Rule{Type: models.RuleValueAbove, Value: "0xde0b6b3a7640000"},
+			tx:    models.Transaction{Value: "0xde0b6b3a7640000"},
+			match: false,
+		},
+		{
+			name:  "value below threshold",
+			rule:  models.Rule{Type: models.RuleValueBelow, Value: "10"},
+			tx:    models.Transaction{Value: "0x9"},
+			match: true,
+		},
+		{
+			name:  "missing value never matches",
+			rule:  models.Rule{Type: models.RuleValueBelow, Value: "10"},
+			tx:    models.Transaction{},
+			match: false,
+		},
+		{
+			name:  "contract creation",
+			rule:  models.Rule{Type: models.RuleContractCreation},
+			tx:    models.Transaction{From: alice, Input: "0x6080"},
+			match: true,
+		},
+		{
+			name:  "method selector",
+			rule:  models.Rule{Type: models.RuleMethod, Selectors: []string{transfer}},
+			tx:    models.Transaction{To: usdt, Input: "0xA9059CBB000000"},
+			match: true,
+		},
+		{
+			name:  "short input has no selector",
+			rule:  models.Rule{Type: models.RuleMethod, Selectors: []string{transfer}},
+			tx:    models.Transaction{To: usdt, Input: "0x"},
+			match: false,
+		},
+		{
+			name:  "token contract call",
+			rule:  models.Rule{Type: models.RuleTokenContract, Addresses: []models.Address{usdt}},
+			tx:    models.Transaction{To: usdt, Input: transfer + "00"},
+			match: true,
+		},
+		{
+			name:  "plain transfer to token contract is not a call",
+			rule:  models.Rule{Type: models.RuleTokenContract, Addresses: []models.Address{usdt}},
+			tx:    models.Transaction{To: usdt, Input: "0x"},
+			match: false,
+		},
+		{
+			name: "and",
+			rule: models.Rule{Type: models.RuleAnd, Rules: []models.Rule{
+				{Type: models.RuleAddress, Addresses: []models.Address{alice}},
+				{Type: models.RuleValueAbove, Value: "100"},
+			}},
+			tx:    models.Transaction{From: alice, To: bob, Value: "0x10"},
+			match: false,
+		},
+		{
+			name: "or",
+			rule: models.Rule{Type: models.RuleOr, Rules: []models.Rule{
+				{Type: models.RuleAddress, Addresses: []models.Address{alice}},
+				{Type: models.RuleValueAbove, Value: "100"},
+			}},
+			tx:    models.Transaction{From: alice, To: bob, Value: "0x10"},
+			match: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := FromRule(tt.rule)
+			require.NoError(t, err)
+			require.Equal(t, tt.match, m.Match(tt.tx))
+		})
+	}
+}
+
+func TestFromRuleInvalid(t *testing.T) {
+	rules := []models.Rule{
+		{Type: "unknown"},
+		{Type: models.RuleAddress},
+		{Type: models.RuleAddress, Addresses: []models.Address{"0x123"}},
+		{Type: models.RuleValueAbove, Value: "lots"},
+		{Type: models.RuleValueBelow, Value: "-1"},
+		{Type: models.RuleMethod, Selectors: []string{"a9059cbb"}},
+		{Type: models.RuleAnd},
+		{Type: models.RuleOr, Rules: []models.Rule{{Type: "unknown"}}},
+	}
+
+	for _, rule := range rules {
+		_, err := FromRule(rule)
+		require.ErrorIs(t, err, ErrInvalidRule, rule)
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+const namespace = "ethparser_"
+
+// matchBuckets - new matches of a single address within one parser tick
+var matchBuckets = []float64{1, 2, 5, 10, 25, 50, 100}
+
+// Collector - implements the Metrics interfaces of the client, parser, data store and router packages
+type Collector struct {
+	blocksProcessed CounterVec
+	parserLag       GaugeVec
+	addressMatches  HistogramVec
+	rpcDuration     HistogramVec
+	rpcErrors       CounterVec
+	httpDuration    HistogramVec
+	httpRequests    CounterVec
+	subscribers     GaugeVec
+}
+
+func NewCollector(r *Registry) *Collector {
+	return &Collector{
+		blocksProcessed: r.Counter(namespace+"blocks_processed_total",
+			"Blocks parsed since start."),
+		parserLag: r.Gauge(namespace+"parser_lag_blocks",
+			"Remote head minus the last parsed block."),
+		addressMatches: r.Histogram(namespace+"address_matches",
+			"New matches of a single address per parser tick.", matchBuckets),
+		rpcDuration: r.Histogram(namespace+"rpc_duration_seconds",
+			"JSON-RPC call latency.", DefBuckets, "method"),
+		rpcErrors: r.Counter(namespace+"rpc_errors_total",
+			"Failed JSON-RPC calls.", "method"),
+		httpDuration: r.Histogram(namespace+"http_request_duration_seconds",
+			"HTTP handler latency.", DefBuckets, "route"),
+		httpRequests: r.Counter(namespace+"http_requests_total",
+			"HTTP requests by route and status code.", "route", "code"),
+		subscribers: r.Gauge(namespace+"subscribers",
+			"Subscribed addresses."),
+	}
+}
+
+func (c *Collector) ObserveCall(method string, duration time.Duration, err error) {
+	c.rpcDuration.Observe(duration.Seconds(), method)
+	if err != nil {
+		c.rpcErrors.Inc(method)
+	}
+}
+
+func (c *Collector) BlocksProcessed(n int) {
+	c.blocksProcessed.Add(float64(n))
+}
+
+func (c *Collector) SetLag(blocks int) {
+	c.parserLag.Set(float64(blocks))
+}
+
+func (c *Collector) ObserveAddressMatches(n int) {
+	c.addressMatches.Observe(float64(n))
+}
+
+func (c *Collector) AddSubscribers(delta int) {
+	c.subscribers.Add(float64(delta))
+}
+
+func (c *Collector) ObserveRequest(route string, code int, duration time.Duration) {
+	c.httpDuration.Observe(duration.Seconds(), route)
+	c.httpRequests.Inc(route, strconv.Itoa(code))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	c := NewCollector(r)
+
+	c.BlocksProcessed(3)
+	c.BlocksProcessed(-2)
+	c.SetLag(2)
+	c.AddSubscribers(2)
+	c.AddSubscribers(-1)
+	c.ObserveCall("eth_blockNumber", 20*time.Millisecond, nil)
+	c.ObserveCall("eth_blockNumber", 2*time.Second, errTest)
+	c.ObserveRequest("GET /current-block", 200, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, contentType, rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE ethparser_blocks_processed_total counter",
+		"ethparser_blocks_processed_total 3",
+		"ethparser_parser_lag_blocks 2",
+		"ethparser_subscribers 1",
+		`ethparser_rpc_duration_seconds_bucket{method="eth_blockNumber",le="0.025"} 1`,
+		`ethparser_rpc_duration_seconds_bucket{method="eth_blockNumber",le="+Inf"} 2`,
+		`ethparser_rpc_duration_seconds_count{method="eth_blockNumber"} 2`,
+		`ethparser_rpc_errors_total{method="eth_blockNumber"} 1`,
+		`ethparser_http_requests_total{route="GET /current-block",code="200"} 1`,
+	} {
+		require.Contains(t, strings.Split(body, "\n"), line)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("test_total", "help", "label").Inc("a\"b\\c\nd")
+
+	sb := &strings.Builder{}
+	_, err := r.WriteTo(sb)
+	require.NoError(t, err)
+	require.Contains(t, sb.String(), `test_total{label="a\"b\\c\nd"} 1`)
+}
+
+var errTest = errors.New("boom")
@@ -2,6 +2,8 @@ package models
 
 import (
 	"strings"
+
+	"github.com/galecic/ethereum_parser/internal/helpers"
 )
 
 type Address string
@@ -11,8 +13,31 @@ const (
 	addrLen    = len(addrPrefix) + 40
 )
 
+// NoLogIndex is the log index of a record that is a plain transaction rather than an event log.
+const NoLogIndex = -1
+
+// Valid - 0x followed by 40 hex digits of either case
 func (a Address) Valid() bool {
-	return len(a) == addrLen && strings.Contains(string(a), addrPrefix)
+	if len(a) != addrLen || !strings.HasPrefix(string(a), addrPrefix) {
+		return false
+	}
+	for _, c := range a[len(addrPrefix):] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NormalizeAddress - s with its hex digits lower cased, the form the node returns addresses in and the one
+// they are stored and matched in. Anything but a 0x prefix is kept, so Valid still rejects it.
+func NormalizeAddress(s string) Address {
+	digits, ok := strings.CutPrefix(s, addrPrefix)
+	if !ok {
+		return Address(s)
+	}
+	return Address(addrPrefix + strings.ToLower(digits))
 }
 
 type Transaction struct {
@@ -21,8 +46,44 @@ type Transaction struct {
 	Hash             string  `json:"hash"`
 	To               Address `json:"to"`
 	TransactionIndex string  `json:"transactionIndex"`
+	Value            string  `json:"value,omitempty"`
+	Input            string  `json:"input,omitempty"`
+	LogIndex         string  `json:"logIndex,omitempty"`
 }
 
 func (tx Transaction) BelongsToAddr(addr Address) bool {
 	return tx.From == addr || tx.To == addr
 }
+
+// Parties - distinct non-empty addresses the transaction touches
+func (tx Transaction) Parties() []Address {
+	parties := make([]Address, 0, 2)
+	if tx.From != "" {
+		parties = append(parties, tx.From)
+	}
+	if tx.To != "" && tx.To != tx.From {
+		parties = append(parties, tx.To)
+	}
+
+	return parties
+}
+
+// TxKey identifies a stored record of an address: the transaction hash plus the log index for event logs.
+type TxKey struct {
+	Hash     string
+	LogIndex int
+}
+
+func (tx Transaction) Key() TxKey {
+	key := TxKey{
+		Hash:     tx.Hash,
+		LogIndex: NoLogIndex,
+	}
+	if tx.LogIndex != "" {
+		if idx, err := helpers.ParseHexInt(tx.LogIndex); err == nil {
+			key.LogIndex = idx
+		}
+	}
+
+	return key
+}
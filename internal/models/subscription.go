@@ -0,0 +1,41 @@
+package models
+
+type RuleType string
+
+const (
+	// RuleAddress - transaction involves one of Addresses as sender or recipient
+	RuleAddress RuleType = "address"
+	// RuleValueAbove - transferred value in wei is greater than Value
+	RuleValueAbove RuleType = "value_above"
+	// RuleValueBelow - transferred value in wei is less than Value
+	RuleValueBelow RuleType = "value_below"
+	// RuleContractCreation - transaction has no recipient
+	RuleContractCreation RuleType = "contract_creation"
+	// RuleMethod - input starts with one of the 4-byte Selectors
+	RuleMethod RuleType = "method"
+	// RuleTokenContract - transaction calls one of the token contracts in Addresses
+	RuleTokenContract RuleType = "token_contract"
+	// RuleAnd - all nested Rules match
+	RuleAnd RuleType = "and"
+	// RuleOr - at least one of nested Rules matches
+	RuleOr RuleType = "or"
+)
+
+// Rule - serializable description of a matcher, nested through Rules for and/or
+type Rule struct {
+	Type      RuleType  `json:"type"`
+	Addresses []Address `json:"addresses,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	Selectors []string  `json:"selectors,omitempty"`
+	Rules     []Rule    `json:"rules,omitempty"`
+}
+
+// DefaultTenant - tenant of callers whose credentials name none, everyone while auth is disabled
+const DefaultTenant = "default"
+
+type Subscription struct {
+	ID string `json:"id"`
+	// Tenant - owner, the only one who sees the subscription and its matches
+	Tenant string `json:"tenant"`
+	Rule   Rule   `json:"rule"`
+}
@@ -0,0 +1,200 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+// defaultWatchBuffer - matches queued per watcher before it counts as too slow
+const defaultWatchBuffer = 256
+
+var (
+	ErrNotSubscribed = errors.New("address not subscribed")
+	// ErrWatcherTooSlow - a watcher fell further behind than its buffer and was dropped
+	ErrWatcherTooSlow = errors.New("watcher too slow, dropped")
+	ErrWatcherClosed  = errors.New("watcher closed")
+)
+
+// Match - a transaction newly stored for a subscribed address
+type Match struct {
+	Address models.Address
+	Tx      models.Transaction
+}
+
+// Watcher - live matches of addresses one tenant is subscribed to.
+// Matches are never blocked on: a watcher that does not keep up is dropped and its Done channel closed.
+type Watcher struct {
+	tenant  string
+	feed    *feed
+	matches chan Match
+	done    chan struct{}
+	once    sync.Once
+	err     error
+
+	mu    sync.RWMutex
+	addrs map[models.Address]struct{}
+}
+
+// Matches - receive side of the match queue, select on Done as well, it is never closed
+func (w *Watcher) Matches() <-chan Match {
+	return w.matches
+}
+
+// Done - closed once the watcher is dropped or closed
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Err - why Done was closed, nil while the watcher is live
+func (w *Watcher) Err() error {
+	select {
+	case <-w.done:
+		return w.err
+	default:
+		return nil
+	}
+}
+
+// Close - stops delivery, safe to call more than once
+func (w *Watcher) Close() {
+	w.stop(ErrWatcherClosed)
+	w.feed.remove(w)
+}
+
+// Add - start delivering the matches of addr, which the watcher's tenant must be subscribed to
+func (w *Watcher) Add(ctx context.Context, addr models.Address) error {
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return w.feed.add(ctx, w, models.NormalizeAddress(string(addr)))
+}
+
+// Remove - stop delivering the matches of addr, a no-op for an address the watcher does not watch
+func (w *Watcher) Remove(addr models.Address) {
+	w.remove(models.NormalizeAddress(string(addr)))
+}
+
+// Addresses - number of watched addresses
+func (w *Watcher) Addresses() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.addrs)
+}
+
+func (w *Watcher) stop(err error) {
+	w.once.Do(func() {
+		w.err = err
+		close(w.done)
+	})
+}
+
+func (w *Watcher) add(addr models.Address) {
+	w.mu.Lock()
+	w.addrs[addr] = struct{}{}
+	w.mu.Unlock()
+}
+
+func (w *Watcher) remove(addr models.Address) {
+	w.mu.Lock()
+	delete(w.addrs, addr)
+	w.mu.Unlock()
+}
+
+// Watches - addr is one of the watched addresses
+func (w *Watcher) Watches(addr models.Address) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.addrs[models.NormalizeAddress(string(addr))]
+	return ok
+}
+
+// feed - fans matches out to watchers, publishing never waits on a watcher
+type feed struct {
+	mu       sync.RWMutex
+	watchers map[*Watcher]struct{}
+	buffer   int
+	// subscribed - whether tenant may watch addr
+	subscribed func(ctx context.Context, tenant string, addr models.Address) bool
+	// dropped - called for every watcher dropped as too slow
+	dropped func(w *Watcher)
+}
+
+func newFeed(buffer int, subscribed func(ctx context.Context, tenant string, addr models.Address) bool) *feed {
+	return &feed{
+		watchers:   make(map[*Watcher]struct{}),
+		buffer:     buffer,
+		subscribed: subscribed,
+		dropped:    func(*Watcher) {},
+	}
+}
+
+func (f *feed) watch(tenant string) *Watcher {
+	w := &Watcher{
+		tenant:  tenant,
+		feed:    f,
+		matches: make(chan Match, f.buffer),
+		done:    make(chan struct{}),
+		addrs:   make(map[models.Address]struct{}),
+	}
+	f.mu.Lock()
+	f.watchers[w] = struct{}{}
+	f.mu.Unlock()
+
+	return w
+}
+
+// add - adds addr to w if its tenant is subscribed, an unsubscribe of addr cannot land
+// between the check and the add as both run under mu
+func (f *feed) add(ctx context.Context, w *Watcher, addr models.Address) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.subscribed(ctx, w.tenant, addr) {
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, addr)
+	}
+	w.add(addr)
+
+	return nil
+}
+
+func (f *feed) remove(w *Watcher) {
+	f.mu.Lock()
+	delete(f.watchers, w)
+	f.mu.Unlock()
+}
+
+// publish - queues m for every live watcher of its address, a watcher with a full queue is dropped
+func (f *feed) publish(m Match) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for w := range f.watchers {
+		if !w.Watches(m.Address) {
+			continue
+		}
+		select {
+		case <-w.done:
+			continue
+		default:
+		}
+		select {
+		case w.matches <- m:
+		default:
+			w.stop(ErrWatcherTooSlow)
+			f.dropped(w)
+		}
+	}
+}
+
+// unsubscribed - tenant stopped watching addr, its watchers stop receiving the address's matches
+func (f *feed) unsubscribed(tenant string, addr models.Address) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for w := range f.watchers {
+		if w.tenant == tenant {
+			w.remove(addr)
+		}
+	}
+}
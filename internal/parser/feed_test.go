@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+// feedTxs - streams txs through the matcher as one worker would
+func feedTxs(ctx context.Context, p *ParserRuntime, txs ...models.Transaction) {
+	txStream := make(chan models.Transaction, len(txs))
+	for _, tx := range txs {
+		txStream <- tx
+	}
+	close(txStream)
+	p.matchTx(ctx, txStream)
+}
+
+func TestParserRuntime_Watch(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+
+	addr, other := testAddress(1), testAddress(2)
+	_, err := parser.Watch(ctx, models.DefaultTenant, []models.Address{addr})
+	require.ErrorIs(t, err, ErrNotSubscribed)
+
+	parser.Subscribe(ctx, models.DefaultTenant, addr)
+	parser.Subscribe(ctx, models.DefaultTenant, other)
+	w, err := parser.Watch(ctx, models.DefaultTenant, []models.Address{addr})
+	require.NoError(t, err)
+	defer w.Close()
+
+	tx := models.Transaction{Hash: "0x1", From: addr, To: other}
+	feedTxs(ctx, parser, tx)
+	require.Equal(t, Match{Address: addr, Tx: tx}, <-w.Matches())
+	// only the watched address is delivered
+	require.Empty(t, w.Matches())
+
+	// an address the tenant unsubscribes is no longer delivered
+	parser.Subscribe(ctx, "other", addr)
+	parser.Unsubscribe(ctx, models.DefaultTenant, addr)
+	feedTxs(ctx, parser, models.Transaction{Hash: "0x2", From: addr})
+	require.Empty(t, w.Matches())
+	require.Zero(t, w.Addresses())
+	require.NoError(t, w.Err())
+}
+
+func TestParserRuntime_WatchSlowWatcher(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{}, WithWatchBuffer(2))
+
+	addr := testAddress(1)
+	parser.Subscribe(ctx, models.DefaultTenant, addr)
+	slow, err := parser.Watch(ctx, models.DefaultTenant, []models.Address{addr})
+	require.NoError(t, err)
+	defer slow.Close()
+
+	txs := make([]models.Transaction, 0, 5)
+	for i := range 5 {
+		txs = append(txs, models.Transaction{Hash: fmt.Sprintf("0x%x", i), From: addr})
+	}
+	// the matcher carries on past a watcher that does not read
+	feedTxs(ctx, parser, txs...)
+
+	<-slow.Done()
+	require.ErrorIs(t, slow.Err(), ErrWatcherTooSlow)
+	require.Len(t, slow.Matches(), 2)
+	require.Len(t, parser.GetTransactions(ctx, models.DefaultTenant, addr), 5)
+}
+
+func TestWatcher_Close(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+
+	addr := testAddress(1)
+	parser.Subscribe(ctx, models.DefaultTenant, addr)
+	w, err := parser.Watch(ctx, models.DefaultTenant, []models.Address{addr})
+	require.NoError(t, err)
+
+	w.Close()
+	w.Close()
+	require.ErrorIs(t, w.Err(), ErrWatcherClosed)
+	feedTxs(ctx, parser, models.Transaction{Hash: "0x1", From: addr})
+	require.Empty(t, w.Matches())
+}
+
+func TestWatcher_AddRemove(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+
+	addr, other := testAddress(1), testAddress(2)
+	parser.Subscribe(ctx, models.DefaultTenant, addr)
+	parser.Subscribe(ctx, "other", other)
+	w, err := parser.Watch(ctx, models.DefaultTenant, nil)
+	require.NoError(t, err)
+	defer w.Close()
+
+	// only addresses of the watcher's tenant can be added
+	require.ErrorIs(t, w.Add(ctx, other), ErrNotSubscribed)
+	require.NoError(t, w.Add(ctx, addr))
+	require.Equal(t, 1, w.Addresses())
+
+	tx := models.Transaction{Hash: "0x1", From: addr}
+	feedTxs(ctx, parser, tx)
+	require.Equal(t, Match{Address: addr, Tx: tx}, <-w.Matches())
+
+	w.Remove(addr)
+	w.Remove(addr)
+	feedTxs(ctx, parser, models.Transaction{Hash: "0x2", From: addr})
+	require.Empty(t, w.Matches())
+
+	w.Close()
+	require.ErrorIs(t, w.Add(ctx, addr), ErrWatcherClosed)
+}
+
+func TestWatcher_AddRacingUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	addr := testAddress(1)
+	var f *feed
+	f = newFeed(1, func(context.Context, string, models.Address) bool {
+		// an unsubscribe cannot land between the check and the add
+		require.False(t, f.mu.TryRLock())
+		return true
+	})
+	w := f.watch(models.DefaultTenant)
+
+	require.NoError(t, w.Add(ctx, addr))
+	// so it comes after the add and removes the address
+	f.unsubscribed(models.DefaultTenant, addr)
+	require.False(t, w.Watches(addr))
+}
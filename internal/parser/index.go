@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/galecic/ethereum_parser/internal/bloom"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+const (
+	defaultExpectedSubscribers = 1 << 16
+	filterFalsePositiveRate    = 0.01
+	// staleRebuildRatio - share of removed addresses still set in the filter that triggers a rebuild
+	staleRebuildRatio = 0.25
+)
+
+// SubscriptionIndex - screens addresses with a bloom filter before the exact data store lookup,
+// so the common case of an unsubscribed address never touches the store lock.
+type SubscriptionIndex struct {
+	dataStore data_store.DataStore
+	filter    atomic.Pointer[bloom.Filter]
+
+	// mu serialises filter writers; readers only load the filter pointer
+	mu       sync.Mutex
+	capacity int
+	stale    int
+}
+
+func NewSubscriptionIndex(ctx context.Context, dataStore data_store.DataStore, expected int) *SubscriptionIndex {
+	if expected <= 0 {
+		expected = defaultExpectedSubscribers
+	}
+	idx := &SubscriptionIndex{
+		dataStore: dataStore,
+		capacity:  expected,
+	}
+	idx.mu.Lock()
+	idx.rebuild(ctx)
+	idx.mu.Unlock()
+
+	return idx
+}
+
+// Contains - reports whether addr is subscribed
+func (idx *SubscriptionIndex) Contains(ctx context.Context, addr models.Address) bool {
+	if !idx.filter.Load().Test(string(addr)) {
+		return false
+	}
+	return idx.dataStore.AddressExists(ctx, addr)
+}
+
+// Add - must be called after addr is stored
+func (idx *SubscriptionIndex) Add(ctx context.Context, addr models.Address) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	filter := idx.filter.Load()
+	if filter.Count() >= idx.capacity {
+		idx.capacity *= 2
+		idx.rebuild(ctx)
+		return
+	}
+	filter.Add(string(addr))
+}
+
+// Remove - must be called after addr is deleted from the store. Bloom filters cannot unset bits,
+// so removed addresses are confirmed away by the exact lookup until enough pile up to rebuild.
+// An address the filter never held is not stale, only a false positive is counted in its place.
+func (idx *SubscriptionIndex) Remove(ctx context.Context, addr models.Address) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	filter := idx.filter.Load()
+	if !filter.Test(string(addr)) {
+		return
+	}
+	idx.stale++
+	if float64(idx.stale) > float64(filter.Count())*staleRebuildRatio {
+		idx.rebuild(ctx)
+	}
+}
+
+func (idx *SubscriptionIndex) rebuild(ctx context.Context) {
+	addrs := idx.dataStore.GetSubscribers(ctx)
+	for len(addrs) > idx.capacity {
+		idx.capacity *= 2
+	}
+	filter := bloom.New(idx.capacity, filterFalsePositiveRate)
+	for _, addr := range addrs {
+		filter.Add(string(addr))
+	}
+	idx.filter.Store(filter)
+	idx.stale = 0
+}
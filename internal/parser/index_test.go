@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+func testAddress(i int) models.Address {
+	return models.Address(fmt.Sprintf("0x%040x", i))
+}
+
+func TestSubscriptionIndex(t *testing.T) {
+	ctx := context.Background()
+	store := &MockDataStore{}
+	store.AddSubscriber(ctx, models.DefaultTenant, testAddress(1))
+	idx := NewSubscriptionIndex(ctx, store, 2)
+
+	require.True(t, idx.Contains(ctx, testAddress(1)))
+	require.False(t, idx.Contains(ctx, testAddress(2)))
+
+	// grows past the initial capacity without losing members
+	for i := 2; i < 10; i++ {
+		store.AddSubscriber(ctx, models.DefaultTenant, testAddress(i))
+		idx.Add(ctx, testAddress(i))
+	}
+	for i := 1; i < 10; i++ {
+		require.True(t, idx.Contains(ctx, testAddress(i)))
+	}
+
+	store.RemoveSubscriber(ctx, models.DefaultTenant, testAddress(3))
+	idx.Remove(ctx, testAddress(3))
+	require.False(t, idx.Contains(ctx, testAddress(3)))
+	require.True(t, idx.Contains(ctx, testAddress(4)))
+}
+
+func TestSubscriptionIndex_RemoveUnindexed(t *testing.T) {
+	ctx := context.Background()
+	store := &MockDataStore{}
+	for i := 1; i <= 8; i++ {
+		store.AddSubscriber(ctx, models.DefaultTenant, testAddress(i))
+	}
+	idx := NewSubscriptionIndex(ctx, store, 1024)
+	filter := idx.filter.Load()
+
+	// unsubscribing addresses nobody watched leaves the filter as it is
+	for i := 1000; i < 1004; i++ {
+		idx.Remove(ctx, testAddress(i))
+	}
+	require.Zero(t, idx.stale)
+	require.Same(t, filter, idx.filter.Load())
+
+	store.RemoveSubscriber(ctx, models.DefaultTenant, testAddress(1))
+	idx.Remove(ctx, testAddress(1))
+	require.Equal(t, 1, idx.stale)
+	require.False(t, idx.Contains(ctx, testAddress(1)))
+}
+
+func TestParserRuntime_Unsubscribe(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+
+	addr := testAddress(1)
+	parser.Subscribe(ctx, models.DefaultTenant, addr)
+	parser.Unsubscribe(ctx, models.DefaultTenant, addr)
+
+	txStream := make(chan models.Transaction, 1)
+	txStream <- models.Transaction{Hash: "0x123", From: addr}
+	close(txStream)
+	parser.matchTx(ctx, txStream)
+
+	require.Nil(t, parser.GetTransactions(ctx, models.DefaultTenant, addr))
+}
+
+func TestParserRuntime_UnsubscribeRepeated(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+	for i := 1; i <= 8; i++ {
+		require.True(t, parser.Subscribe(ctx, models.DefaultTenant, testAddress(i)))
+	}
+
+	parser.Unsubscribe(ctx, models.DefaultTenant, testAddress(1))
+	require.Equal(t, 1, parser.index.stale)
+	// the address is still set in the filter, unsubscribing it again removes nothing and is not stale again
+	for range 10 {
+		parser.Unsubscribe(ctx, models.DefaultTenant, testAddress(1))
+	}
+	require.Equal(t, 1, parser.index.stale)
+}
+
+func TestParserRuntime_Subscribe(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+
+	addr := testAddress(1)
+	require.True(t, parser.Subscribe(ctx, models.DefaultTenant, addr))
+	require.False(t, parser.Subscribe(ctx, models.DefaultTenant, addr))
+	require.True(t, parser.Subscribe(ctx, "other", addr))
+	require.False(t, parser.Subscribe(ctx, models.DefaultTenant, "0x123"))
+}
+
+func TestParserRuntime_UnsubscribeSharedAddress(t *testing.T) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, data_store.NewDataStore(), ParserConfig{})
+
+	addr := testAddress(1)
+	parser.Subscribe(ctx, models.DefaultTenant, addr)
+	parser.Subscribe(ctx, "other", addr)
+	parser.Unsubscribe(ctx, models.DefaultTenant, addr)
+
+	txStream := make(chan models.Transaction, 1)
+	txStream <- models.Transaction{Hash: "0x123", From: addr}
+	close(txStream)
+	// the address is matched once for every tenant still watching it
+	require.Equal(t, map[models.Address]int{addr: 1}, parser.matchTx(ctx, txStream))
+
+	require.Nil(t, parser.GetTransactions(ctx, models.DefaultTenant, addr))
+	require.Len(t, parser.GetTransactions(ctx, "other", addr), 1)
+}
+
+const (
+	benchSubscribers = 1_000_000
+	benchBlockTxs    = 200
+)
+
+func benchBlock() []models.Transaction {
+	txs := make([]models.Transaction, 0, benchBlockTxs)
+	for i := range benchBlockTxs {
+		// a handful of subscribed parties, the rest of the block is unrelated traffic
+		from := testAddress(benchSubscribers + i)
+		if i%50 == 0 {
+			from = testAddress(i)
+		}
+		txs = append(txs, models.Transaction{
+			Hash:             fmt.Sprintf("0x%064x", i),
+			From:             from,
+			To:               testAddress(2*benchSubscribers + i),
+			BlockNumber:      "0x1",
+			TransactionIndex: fmt.Sprintf("0x%x", i),
+		})
+	}
+	return txs
+}
+
+func benchStore(b *testing.B) data_store.DataStore {
+	ctx := context.Background()
+	b.Helper()
+	store := data_store.NewDataStore()
+	for i := range benchSubscribers {
+		store.AddSubscriber(ctx, models.DefaultTenant, testAddress(i))
+	}
+	return store
+}
+
+// BenchmarkMatchBlock - cost of matching one block against 1M address subscriptions
+func BenchmarkMatchBlock(b *testing.B) {
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, benchStore(b), ParserConfig{Workers: 10})
+	txs := benchBlock()
+
+	for b.Loop() {
+		if err := parser.parseTxs(ctx, &txs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkScreenBlock - filter pre-screen against exact store lookups for every party of one block
+func BenchmarkScreenBlock(b *testing.B) {
+	ctx := context.Background()
+	store := benchStore(b)
+	idx := NewSubscriptionIndex(ctx, store, benchSubscribers)
+	txs := benchBlock()
+
+	b.Run("index", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				for _, tx := range txs {
+					idx.Contains(ctx, tx.From)
+					idx.Contains(ctx, tx.To)
+				}
+			}
+		})
+	})
+	b.Run("store", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				for _, tx := range txs {
+					store.AddressExists(ctx, tx.From)
+					store.AddressExists(ctx, tx.To)
+				}
+			}
+		})
+	})
+}
@@ -2,23 +2,99 @@ package parser
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/galecic/ethereum_parser/internal/client"
 	"github.com/galecic/ethereum_parser/internal/data_store"
 	"github.com/galecic/ethereum_parser/internal/helpers"
+	"github.com/galecic/ethereum_parser/internal/logging"
+	"github.com/galecic/ethereum_parser/internal/matcher"
 	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/galecic/ethereum_parser/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/galecic/ethereum_parser/internal/parser"
+
+// MaxRescanBlocks - longest range Rescan takes, parsing of new blocks waits for a rescan to finish
+const MaxRescanBlocks = 1000
+
+var (
+	ErrInvalidBlock = errors.New("invalid block")
+	ErrFetchFailed  = errors.New("fetching block failed")
 )
 
 type Parser interface {
 	// GetCurrentBlock - last parsed block
-	GetCurrentBlock() int
-	// Subscribe - add address to observer
-	Subscribe(ctx context.Context, address models.Address)
-	// GetTransactions -  list of inbound or outbound transactions for an address
-	GetTransactions(ctx context.Context, address models.Address) []models.Transaction
+	GetCurrentBlock(ctx context.Context) int
+	// Subscribe - add address to the tenant's observer, false when the address is invalid or already observed
+	Subscribe(ctx context.Context, tenant string, address models.Address) bool
+	// Unsubscribe - remove address from the tenant's observer
+	Unsubscribe(ctx context.Context, tenant string, address models.Address)
+	// GetSubscribers - addresses in the tenant's observer, sorted
+	GetSubscribers(ctx context.Context, tenant string) []models.Address
+	// GetTransactions -  list of inbound or outbound transactions for an address seen since the tenant subscribed
+	GetTransactions(ctx context.Context, tenant string, address models.Address) []models.Transaction
+	// CreateSubscription - register a rule set owned by tenant under a generated ID
+	CreateSubscription(ctx context.Context, tenant string, rule models.Rule) (models.Subscription, error)
+	// DeleteSubscription - stop matching a rule subscription and drop its matches
+	DeleteSubscription(ctx context.Context, tenant, id string) error
+	// GetSubscriptions - rule subscriptions of a tenant
+	GetSubscriptions(ctx context.Context, tenant string) []models.Subscription
+	// GetSubscriptionTransactions - transactions matched by a rule subscription
+	GetSubscriptionTransactions(ctx context.Context, tenant, id string) ([]models.Transaction, error)
+	// BatchGetSubscriptionTransactions - transactions of several rule subscriptions by ID in one store read,
+	// IDs the tenant does not own are left out
+	BatchGetSubscriptionTransactions(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction
+	// ScanTransactions - lazily reads the stored transactions selected by q, see data_store.DataStore.Scan
+	ScanTransactions(ctx context.Context, q data_store.TxQuery) iter.Seq2[models.Address, models.Transaction]
+	// Watch - live matches of addrs, which tenant must be subscribed to, until the watcher is closed or dropped.
+	// Addresses can be added to and removed from a live watcher.
+	Watch(ctx context.Context, tenant string, addrs []models.Address) (*Watcher, error)
+	// Status - parsing progress for health checks
+	Status() Status
+}
+
+// Controller - operator controls of a running parser, for re-parsing blocks a provider served bad data for
+type Controller interface {
+	// Pause - stops parsing new blocks until Resume, a tick in progress finishes first
+	Pause()
+	Resume()
+	// Rewind - sets the current block back to block so the blocks after it are parsed again,
+	// with purge their stored transactions and matches are dropped first. Returns the number dropped.
+	Rewind(ctx context.Context, block int, purge bool) (int, error)
+	// Rescan - fetches the parsed blocks from..to again and stores the matches missing from them,
+	// the current block stays. Returns the number of new address matches.
+	Rescan(ctx context.Context, from, to int) (int, error)
+}
+
+type Status struct {
+	RemoteHead   int `json:"remoteHead"`
+	CurrentBlock int `json:"currentBlock"`
+	// LastSuccessfulTick - zero until the first block is processed
+	LastSuccessfulTick time.Time `json:"lastSuccessfulTick"`
+	LastError          string    `json:"lastError,omitempty"`
+	LastErrorAt        time.Time `json:"lastErrorAt"`
+	Endpoint           string    `json:"endpoint"`
+	StartedAt          time.Time `json:"startedAt"`
+	Uptime             string    `json:"uptime"`
+	// Paused - new blocks are not parsed until the parser is resumed
+	Paused bool `json:"paused"`
+	// Interval - time between ticks, for telling a stuck parser from an idle one
+	Interval time.Duration `json:"-"`
+}
+
+// Lag - blocks the parser is behind the node
+func (s Status) Lag() int {
+	return s.RemoteHead - s.CurrentBlock
 }
 
 type ParserRuntime struct {
@@ -26,20 +102,127 @@ type ParserRuntime struct {
 	cfg       ParserConfig
 	client    client.Client
 	dataStore data_store.DataStore
+	index     *SubscriptionIndex
+	metrics   Metrics
+	logger    *slog.Logger
+	tracer    trace.Tracer
+
+	// remoteHead - latest block number reported by the node
+	remoteHead atomic.Int64
+
+	// tickMu - held by ticks, rewinds and rescans so they do not interleave
+	tickMu sync.Mutex
+	paused atomic.Bool
+
+	startedAt   time.Time
+	statusMu    sync.RWMutex
+	lastTick    time.Time
+	lastErr     error
+	lastErrTime time.Time
+	interval    time.Duration
+
+	rulesMu sync.RWMutex
+	rules   map[string]matcher.Matcher
+
+	feed        *feed
+	watchBuffer int
+
+	// pending - settings queued by Reconfigure, swapped in by the Parse loop between ticks
+	pendingMu    sync.Mutex
+	pending      *reconfiguration
+	reconfigured chan struct{}
+}
+
+type reconfiguration struct {
+	client client.Client
+	cfg    ParserConfig
+}
+
+// Metrics - instrumentation of parser ticks
+type Metrics interface {
+	// BlocksProcessed - new blocks parsed in a tick
+	BlocksProcessed(n int)
+	// SetLag - remote head minus the last parsed block
+	SetLag(blocks int)
+	// ObserveAddressMatches - new matches of one address in a tick
+	ObserveAddressMatches(n int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) BlocksProcessed(int)       {}
+func (noopMetrics) SetLag(int)                {}
+func (noopMetrics) ObserveAddressMatches(int) {}
+
+type Option func(p *ParserRuntime)
+
+func WithMetrics(m Metrics) Option {
+	return func(p *ParserRuntime) {
+		p.metrics = m
+	}
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(p *ParserRuntime) {
+		p.logger = l
+	}
+}
+
+// WithTracerProvider - a span per tick with child spans for block fetches and matching
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(p *ParserRuntime) {
+		p.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithWatchBuffer - matches queued for each watcher, one falling further behind is dropped
+func WithWatchBuffer(n int) Option {
+	return func(p *ParserRuntime) {
+		p.watchBuffer = n
+	}
 }
 
 type ParserConfig struct {
 	TxFetchInterval time.Duration
 	Workers         int
+	// ExpectedSubscribers - initial capacity of the subscription index filter, grown on demand
+	ExpectedSubscribers int
 }
 
-func NewParserRuntime(ctx context.Context, client client.Client, data data_store.DataStore, cfg ParserConfig) *ParserRuntime {
-	return &ParserRuntime{
+func NewParserRuntime(ctx context.Context, client client.Client, data data_store.DataStore, cfg ParserConfig, opts ...Option) *ParserRuntime {
+	p := &ParserRuntime{
 		ctx:       ctx,
 		client:    client,
 		dataStore: data,
 		cfg:       cfg,
+		index:     NewSubscriptionIndex(ctx, data, cfg.ExpectedSubscribers),
+		metrics:   noopMetrics{},
+		logger:    logging.Discard(),
+		tracer:    tracing.Noop().Tracer(tracerName),
+		startedAt: time.Now(),
+		interval:  cfg.TxFetchInterval,
+		rules:     make(map[string]matcher.Matcher),
+
+		watchBuffer:  defaultWatchBuffer,
+		reconfigured: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.feed = newFeed(p.watchBuffer, p.dataStore.IsSubscriber)
+	p.feed.dropped = func(w *Watcher) {
+		p.logger.Warn("dropped slow watcher", slog.String(logging.KeyTenant, w.tenant), slog.Int("buffer", p.watchBuffer))
 	}
+	for _, sub := range data.AllSubscriptions(ctx) {
+		m, err := matcher.FromRule(sub.Rule)
+		if err != nil {
+			p.logger.Warn("skipping subscription", slog.String(logging.KeySubscription, sub.ID), logging.Err(err))
+			continue
+		}
+		p.rules[sub.ID] = m
+	}
+
+	return p
 }
 
 func (p *ParserRuntime) Parse() {
@@ -50,16 +233,246 @@ func (p *ParserRuntime) Parse() {
 		select {
 		case <-p.ctx.Done():
 			return
+		case <-p.reconfigured:
+			p.tickMu.Lock()
+			p.applyPending(ticker)
+			p.tickMu.Unlock()
 		case <-ticker.C:
-			err := p.processNewTxs(p.ctx)
-			if err != nil {
-				log.Println("processTx error", err)
-			}
+			p.tick(ticker)
+		}
+	}
+}
+
+// tick - applies queued settings and, unless paused, parses the new blocks
+func (p *ParserRuntime) tick(ticker *time.Ticker) {
+	p.tickMu.Lock()
+	defer p.tickMu.Unlock()
+	p.applyPending(ticker)
+	if p.paused.Load() {
+		return
+	}
+	err := p.processNewTxs(p.ctx)
+	p.recordTick(err)
+	if err != nil {
+		p.logger.Error("processing new transactions failed", logging.Err(err))
+	}
+}
+
+func (p *ParserRuntime) Pause() {
+	p.tickMu.Lock()
+	defer p.tickMu.Unlock()
+	if !p.paused.Swap(true) {
+		p.logger.Info("parser paused", slog.Int(logging.KeyBlock, p.GetCurrentBlock(p.ctx)))
+	}
+}
+
+func (p *ParserRuntime) Resume() {
+	if p.paused.Swap(false) {
+		p.logger.Info("parser resumed", slog.Int(logging.KeyBlock, p.GetCurrentBlock(p.ctx)))
+	}
+}
+
+func (p *ParserRuntime) Rewind(ctx context.Context, block int, purge bool) (int, error) {
+	p.tickMu.Lock()
+	defer p.tickMu.Unlock()
+	current := p.GetCurrentBlock(ctx)
+	if block <= 0 || block > current {
+		return 0, fmt.Errorf("%w: %d is not in 1..%d", ErrInvalidBlock, block, current)
+	}
+	purged := 0
+	if purge {
+		purged = p.dataStore.PurgeAbove(ctx, block)
+	}
+	p.dataStore.SetCurrentBlock(ctx, block)
+	logging.FromContext(ctx, p.logger).Warn("parser rewound",
+		slog.Int(logging.KeyBlock, block),
+		slog.Int("from_block", current),
+		slog.Int("purged", purged),
+	)
+
+	return purged, nil
+}
+
+func (p *ParserRuntime) Rescan(ctx context.Context, from, to int) (matches int, err error) {
+	if from <= 0 || to < from || to-from >= MaxRescanBlocks {
+		return 0, fmt.Errorf("%w: %d..%d, at most %d blocks from block 1", ErrInvalidBlock, from, to, MaxRescanBlocks)
+	}
+	p.tickMu.Lock()
+	defer p.tickMu.Unlock()
+	if current := p.GetCurrentBlock(ctx); to > current {
+		return 0, fmt.Errorf("%w: %d is after the current block %d", ErrInvalidBlock, to, current)
+	}
+
+	ctx, span := p.tracer.Start(ctx, "parser.rescan", trace.WithAttributes(
+		attribute.Int("block.from", from),
+		attribute.Int("block.to", to),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("matches", matches))
+		tracing.End(span, err)
+	}()
+
+	txs := make([]models.Transaction, 0)
+	for number := from; number <= to; number++ {
+		blockTxs, err := p.fetchBlock(ctx, number)
+		if err != nil {
+			return 0, fmt.Errorf("%w: block %d: %w", ErrFetchFailed, number, err)
 		}
+		txs = append(txs, blockTxs...)
 	}
+	for _, n := range p.match(ctx, txs) {
+		matches += n
+	}
+	logging.FromContext(ctx, p.logger).Info("rescanned blocks",
+		slog.Int("from_block", from),
+		slog.Int("to_block", to),
+		slog.Int("txs", len(txs)),
+		slog.Int("matches", matches),
+	)
+
+	return matches, nil
 }
 
-func (p *ParserRuntime) processNewTxs(ctx context.Context) error {
+// Replay - parses the blocks from..to in order without asking the node for its head, the current block
+// follows each parsed block so a failed replay can resume after it. Imports archived blocks into the store.
+// Archives can have gaps, blocks the client reports as client.ErrBlockNotFound are skipped and counted as missing.
+func (p *ParserRuntime) Replay(ctx context.Context, from, to int) (matches, missing int, err error) {
+	if from <= 0 || to < from {
+		return 0, 0, fmt.Errorf("%w: %d..%d", ErrInvalidBlock, from, to)
+	}
+	p.tickMu.Lock()
+	defer p.tickMu.Unlock()
+
+	ctx, span := p.tracer.Start(ctx, "parser.replay", trace.WithAttributes(
+		attribute.Int("block.from", from),
+		attribute.Int("block.to", to),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("matches", matches), attribute.Int("missing", missing))
+		tracing.End(span, err)
+	}()
+
+	logger := logging.FromContext(ctx, p.logger)
+	p.remoteHead.Store(int64(to))
+	for number := from; number <= to; number++ {
+		if err := ctx.Err(); err != nil {
+			return matches, missing, err
+		}
+		txs, err := p.fetchBlock(ctx, number)
+		if errors.Is(err, client.ErrBlockNotFound) {
+			missing++
+			logger.Warn("skipping missing block", slog.Int(logging.KeyBlock, number), logging.Err(err))
+			p.dataStore.SetCurrentBlock(ctx, number)
+			continue
+		}
+		if err != nil {
+			return matches, missing, fmt.Errorf("%w: block %d: %w", ErrFetchFailed, number, err)
+		}
+		for _, n := range p.match(ctx, txs) {
+			matches += n
+		}
+		p.dataStore.SetCurrentBlock(ctx, number)
+		p.metrics.BlocksProcessed(1)
+		p.metrics.SetLag(to - number)
+		logger.Debug("replayed block",
+			slog.Int(logging.KeyBlock, number),
+			slog.Int("txs", len(txs)),
+		)
+	}
+	p.recordTick(nil)
+	logger.Info("replayed blocks",
+		slog.Int("from_block", from),
+		slog.Int("to_block", to),
+		slog.Int("matches", matches),
+		slog.Int("missing_blocks", missing),
+	)
+
+	return matches, missing, nil
+}
+
+// Reconfigure - queues a new client and config, the running Parse loop applies them between ticks.
+// A nil client keeps the current one, the subscription index capacity is fixed at construction.
+func (p *ParserRuntime) Reconfigure(c client.Client, cfg ParserConfig) {
+	p.pendingMu.Lock()
+	if c == nil && p.pending != nil {
+		// keep a client queued by an earlier call that was not applied yet
+		c = p.pending.client
+	}
+	p.pending = &reconfiguration{client: c, cfg: cfg}
+	p.pendingMu.Unlock()
+
+	select {
+	case p.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+func (p *ParserRuntime) applyPending(ticker *time.Ticker) {
+	p.pendingMu.Lock()
+	pending := p.pending
+	p.pending = nil
+	p.pendingMu.Unlock()
+	if pending == nil {
+		return
+	}
+
+	if pending.cfg.TxFetchInterval != p.cfg.TxFetchInterval {
+		ticker.Reset(pending.cfg.TxFetchInterval)
+	}
+	pending.cfg.ExpectedSubscribers = p.cfg.ExpectedSubscribers
+	p.cfg = pending.cfg
+	p.statusMu.Lock()
+	p.interval = p.cfg.TxFetchInterval
+	if pending.client != nil {
+		p.client = pending.client
+	}
+	p.statusMu.Unlock()
+	p.logger.Info("applied new configuration",
+		slog.String(logging.KeyEndpoint, p.client.Endpoint()),
+		slog.Duration("interval", p.cfg.TxFetchInterval),
+		slog.Int("workers", p.cfg.Workers),
+	)
+}
+
+func (p *ParserRuntime) recordTick(err error) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+	if err != nil {
+		p.lastErr = err
+		p.lastErrTime = time.Now()
+		return
+	}
+	p.lastTick = time.Now()
+}
+
+func (p *ParserRuntime) Status() Status {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+	status := Status{
+		RemoteHead:         int(p.remoteHead.Load()),
+		CurrentBlock:       p.GetCurrentBlock(p.ctx),
+		LastSuccessfulTick: p.lastTick,
+		LastErrorAt:        p.lastErrTime,
+		Endpoint:           p.client.Endpoint(),
+		StartedAt:          p.startedAt,
+		Uptime:             time.Since(p.startedAt).Round(time.Second).String(),
+		Paused:             p.paused.Load(),
+		Interval:           p.interval,
+	}
+	if p.lastErr != nil {
+		status.LastError = p.lastErr.Error()
+	}
+
+	return status
+}
+
+func (p *ParserRuntime) processNewTxs(ctx context.Context) (err error) {
+	ctx, span := p.tracer.Start(ctx, "parser.tick")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	lastParsed := p.GetCurrentBlock(ctx)
 
 	txs, err := p.getNewTxs(ctx)
 	if err != nil {
@@ -70,22 +483,64 @@ func (p *ParserRuntime) processNewTxs(ctx context.Context) error {
 		return err
 	}
 
+	remoteHead := int(p.remoteHead.Load())
+	// every block up to the head was fetched, blocks without transactions after the last parsed one included
+	if remoteHead > p.GetCurrentBlock(ctx) {
+		p.dataStore.SetCurrentBlock(ctx, remoteHead)
+	}
+	if lastParsed == 0 {
+		p.metrics.BlocksProcessed(1)
+	} else {
+		// a head behind the last parsed block, a lagging node or a rewind, processed nothing new
+		p.metrics.BlocksProcessed(max(0, remoteHead-lastParsed))
+	}
+	p.metrics.SetLag(remoteHead - p.GetCurrentBlock(ctx))
+	span.SetAttributes(
+		tracing.KeyBlock.Int(p.GetCurrentBlock(ctx)),
+		attribute.Int("remote_head", remoteHead),
+		attribute.Int("txs", len(*txs)),
+	)
+	p.logger.Debug("parsed new transactions",
+		slog.Int(logging.KeyBlock, p.GetCurrentBlock(ctx)),
+		slog.Int("remote_head", remoteHead),
+		slog.Int("txs", len(*txs)),
+	)
+
 	return nil
 }
 
+func txBlockAttr(tx models.Transaction) slog.Attr {
+	block, err := helpers.ParseHexInt(tx.BlockNumber)
+	if err != nil {
+		return slog.String(logging.KeyBlock, tx.BlockNumber)
+	}
+	return slog.Int(logging.KeyBlock, block)
+}
+
+func (p *ParserRuntime) fetchBlock(ctx context.Context, number int) (txs []models.Transaction, err error) {
+	ctx, span := p.tracer.Start(ctx, "parser.fetch_block", trace.WithAttributes(tracing.KeyBlock.Int(number)))
+	defer func() {
+		span.SetAttributes(attribute.Int("txs", len(txs)))
+		tracing.End(span, err)
+	}()
+
+	return p.client.GetTxsFromBlock(ctx, number)
+}
+
 func (p *ParserRuntime) getNewTxs(ctx context.Context) (*[]models.Transaction, error) {
 	remoteBlockNumber, err := p.client.GetBlockNumber(ctx)
 	if err != nil {
 		return nil, err
 	}
+	p.remoteHead.Store(int64(remoteBlockNumber))
 
-	localBlockNumber := p.GetCurrentBlock()
+	localBlockNumber := p.GetCurrentBlock(ctx)
 
 	txs := make([]models.Transaction, 0)
 
 	if localBlockNumber != 0 {
 		for localBlockNumber < remoteBlockNumber {
-			txsFromCurrentBlock, err := p.client.GetTxsFromBlock(ctx, localBlockNumber)
+			txsFromCurrentBlock, err := p.fetchBlock(ctx, localBlockNumber)
 			if err != nil {
 				return nil, err
 			}
@@ -93,7 +548,7 @@ func (p *ParserRuntime) getNewTxs(ctx context.Context) (*[]models.Transaction, e
 
 			localBlockNumber++
 		}
-		txsFromCurrentBlock, err := p.client.GetTxsFromBlock(ctx, localBlockNumber)
+		txsFromCurrentBlock, err := p.fetchBlock(ctx, localBlockNumber)
 		if err != nil {
 			return nil, err
 		}
@@ -101,9 +556,9 @@ func (p *ParserRuntime) getNewTxs(ctx context.Context) (*[]models.Transaction, e
 
 	} else {
 		localBlockNumber = remoteBlockNumber
-		p.dataStore.SetCurrentBlock(localBlockNumber)
+		p.dataStore.SetCurrentBlock(ctx, localBlockNumber)
 
-		txsFromCurrentBlock, err := p.client.GetTxsFromBlock(ctx, localBlockNumber)
+		txsFromCurrentBlock, err := p.fetchBlock(ctx, localBlockNumber)
 
 		if err != nil {
 			return nil, err
@@ -117,19 +572,52 @@ func (p *ParserRuntime) parseTxs(
 	ctx context.Context,
 	txs *[]models.Transaction,
 ) error {
+	p.match(ctx, *txs)
+
+	if len(*txs) == 0 {
+		return nil
+	}
+
+	lastProcessedTxIndex, err := helpers.ParseHexInt((*txs)[len(*txs)-1].TransactionIndex)
+	if err != nil {
+		return err
+	}
+	currentBlock, err := helpers.ParseHexInt((*txs)[len(*txs)-1].BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	p.dataStore.SetCurrentBlock(ctx, currentBlock)
+	p.dataStore.SetLastProcessedTxIndex(ctx, lastProcessedTxIndex)
+
+	return err
+}
+
+// match - stores the matches of txs with the configured number of workers, returns the number of new matches per address
+func (p *ParserRuntime) match(ctx context.Context, txs []models.Transaction) map[models.Address]int {
+	ctx, span := p.tracer.Start(ctx, "parser.match", trace.WithAttributes(attribute.Int("txs", len(txs))))
+	defer span.End()
 
 	txChan := make(chan models.Transaction)
 
+	mu := sync.Mutex{}
+	matches := make(map[models.Address]int)
+
 	wg := sync.WaitGroup{}
 	for i := 0; i < p.cfg.Workers; i++ {
 		wg.Add(1)
 		go func() {
-			p.matchTx(ctx, txChan)
+			workerMatches := p.matchTx(ctx, txChan)
+			mu.Lock()
+			for addr, n := range workerMatches {
+				matches[addr] += n
+			}
+			mu.Unlock()
 			wg.Done()
 		}()
 	}
 	go func() {
-		for _, tx := range *txs {
+		for _, tx := range txs {
 			txChan <- tx
 		}
 		close(txChan)
@@ -137,76 +625,171 @@ func (p *ParserRuntime) parseTxs(
 
 	wg.Wait()
 
-	lastProcessedTxIndex, err := helpers.ParseHexInt((*txs)[len(*txs)-1].TransactionIndex)
-	if err != nil {
-		return err
-	}
-	currentBlock, err := helpers.ParseHexInt((*txs)[len(*txs)-1].BlockNumber)
-	if err != nil {
-		return err
+	for _, n := range matches {
+		p.metrics.ObserveAddressMatches(n)
 	}
 
-	p.dataStore.SetCurrentBlock(currentBlock)
-	p.dataStore.SetLastProcessedTxIndex(lastProcessedTxIndex)
-
-	return err
+	return matches
 }
 
+// matchTx - stores matches of the streamed transactions, returns the number of new matches per address
 func (p *ParserRuntime) matchTx(
 	ctx context.Context,
 	txStream chan models.Transaction,
-) {
+) map[models.Address]int {
+	matches := make(map[models.Address]int)
 	for tx := range txStream {
-		txIdx, err := helpers.ParseHexInt(tx.TransactionIndex)
-		if err != nil {
-			log.Println("ParseHexInt", err)
-			continue
-		}
-
-		blockNumber, err := helpers.ParseHexInt(tx.BlockNumber)
-		if err != nil {
-			log.Println("ParseHexInt", err)
-			continue
-		}
-
-		if blockNumber == p.dataStore.GetCurrentBlock() {
-			if txIdx <= p.dataStore.GetLastProcessedTxIndex() {
+		for _, addr := range tx.Parties() {
+			if !p.index.Contains(ctx, addr) {
 				continue
 			}
+			if p.dataStore.AddTx(ctx, addr, tx) {
+				matches[addr]++
+				p.feed.publish(Match{Address: addr, Tx: tx})
+				p.logger.Info("match found",
+					slog.String(logging.KeyAddress, string(addr)),
+					slog.String(logging.KeyTxHash, tx.Hash),
+					txBlockAttr(tx),
+				)
+			}
 		}
 
-		for _, addr := range []models.Address{tx.From, tx.To} {
-			if p.dataStore.AddressExists(addr) {
-				p.dataStore.AddTx(addr, tx)
-				log.Println("Match found: address", addr, "tx", tx.Hash)
-				break
+		p.rulesMu.RLock()
+		for id, m := range p.rules {
+			if m.Match(tx) && p.dataStore.AddMatch(ctx, id, tx) {
+				p.logger.Info("match found",
+					slog.String(logging.KeySubscription, id),
+					slog.String(logging.KeyTxHash, tx.Hash),
+					txBlockAttr(tx),
+				)
 			}
 		}
+		p.rulesMu.RUnlock()
 	}
+
+	return matches
+}
+
+func (p *ParserRuntime) GetCurrentBlock(ctx context.Context) int {
+	return p.dataStore.GetCurrentBlock(ctx)
 }
 
-func (p *ParserRuntime) GetCurrentBlock() int {
-	return p.dataStore.GetCurrentBlock()
+func (p *ParserRuntime) Subscribe(ctx context.Context, tenant string, address models.Address) bool {
+	if !address.Valid() {
+		logging.FromContext(ctx, p.logger).Info("invalid address", slog.String(logging.KeyAddress, string(address)))
+		return false
+	}
+	address = models.NormalizeAddress(string(address))
+	if !p.dataStore.AddSubscriber(ctx, tenant, address) {
+		return false
+	}
+	p.index.Add(ctx, address)
+
+	return true
 }
 
-func (p *ParserRuntime) Subscribe(ctx context.Context, address models.Address) {
+func (p *ParserRuntime) Unsubscribe(ctx context.Context, tenant string, address models.Address) {
 	if !address.Valid() {
-		log.Println("invalid address")
+		logging.FromContext(ctx, p.logger).Info("invalid address", slog.String(logging.KeyAddress, string(address)))
+		return
+	}
+	address = models.NormalizeAddress(string(address))
+	if !p.dataStore.RemoveSubscriber(ctx, tenant, address) {
+		// nothing was removed, so nothing turns stale in the index
 		return
 	}
-	p.dataStore.AddSubscriber(address)
+	p.feed.unsubscribed(tenant, address)
+	// the address stays indexed while other tenants still watch it
+	if !p.dataStore.AddressExists(ctx, address) {
+		p.index.Remove(ctx, address)
+	}
+}
+
+func (p *ParserRuntime) GetSubscribers(ctx context.Context, tenant string) []models.Address {
+	return p.dataStore.GetTenantSubscribers(ctx, tenant)
 }
 
-func (p *ParserRuntime) GetTransactions(ctx context.Context, address models.Address) []models.Transaction {
+func (p *ParserRuntime) GetTransactions(ctx context.Context, tenant string, address models.Address) []models.Transaction {
 	if !address.Valid() {
-		log.Println("invalid address")
+		logging.FromContext(ctx, p.logger).Info("invalid address", slog.String(logging.KeyAddress, string(address)))
 		return nil
 	}
+	address = models.NormalizeAddress(string(address))
+
+	txs := p.dataStore.GetTransactions(ctx, tenant, address)
+	if txs == nil {
+		logging.FromContext(ctx, p.logger).Info("address not subscribed",
+			slog.String(logging.KeyAddress, string(address)),
+			slog.String(logging.KeyTenant, tenant),
+		)
+	}
 
-	if p.dataStore.AddressExists(address) {
-		return p.dataStore.GetTransactions(address)
-	} else {
-		log.Println("address not subscribed")
-		return nil
+	return txs
+}
+
+func (p *ParserRuntime) CreateSubscription(ctx context.Context, tenant string, rule models.Rule) (models.Subscription, error) {
+	m, err := matcher.FromRule(rule)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	sub := models.Subscription{
+		ID:     rand.Text(),
+		Tenant: tenant,
+		Rule:   rule,
+	}
+
+	p.rulesMu.Lock()
+	defer p.rulesMu.Unlock()
+	if err := p.dataStore.AddSubscription(ctx, sub); err != nil {
+		return models.Subscription{}, fmt.Errorf("failed to store subscription: %w", err)
+	}
+	p.rules[sub.ID] = m
+
+	return sub, nil
+}
+
+func (p *ParserRuntime) DeleteSubscription(ctx context.Context, tenant, id string) error {
+	p.rulesMu.Lock()
+	defer p.rulesMu.Unlock()
+	if err := p.dataStore.RemoveSubscription(ctx, tenant, id); err != nil {
+		return err
+	}
+	delete(p.rules, id)
+
+	return nil
+}
+
+func (p *ParserRuntime) GetSubscriptions(ctx context.Context, tenant string) []models.Subscription {
+	return p.dataStore.GetSubscriptions(ctx, tenant)
+}
+
+func (p *ParserRuntime) GetSubscriptionTransactions(ctx context.Context, tenant, id string) ([]models.Transaction, error) {
+	return p.dataStore.GetMatches(ctx, tenant, id)
+}
+
+func (p *ParserRuntime) BatchGetSubscriptionTransactions(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction {
+	return p.dataStore.BatchGetMatches(ctx, tenant, ids)
+}
+
+func (p *ParserRuntime) ScanTransactions(ctx context.Context, q data_store.TxQuery) iter.Seq2[models.Address, models.Transaction] {
+	if q.Addresses != nil {
+		addrs := make([]models.Address, 0, len(q.Addresses))
+		for _, addr := range q.Addresses {
+			addrs = append(addrs, models.NormalizeAddress(string(addr)))
+		}
+		q.Addresses = addrs
 	}
+	return p.dataStore.Scan(ctx, q)
+}
+
+func (p *ParserRuntime) Watch(ctx context.Context, tenant string, addrs []models.Address) (*Watcher, error) {
+	w := p.feed.watch(tenant)
+	for _, addr := range addrs {
+		if err := w.Add(ctx, addr); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
 }
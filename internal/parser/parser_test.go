@@ -2,22 +2,42 @@ package parser
 
 import (
 	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/galecic/ethereum_parser/internal/client"
+	"github.com/galecic/ethereum_parser/internal/data_store"
+	"github.com/galecic/ethereum_parser/internal/data_store/storetest"
+	"github.com/galecic/ethereum_parser/internal/helpers"
 	"github.com/galecic/ethereum_parser/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type MockClient struct {
 	blockNumber int
 	txs         map[int][]models.Transaction
+	endpoint    string
 }
 
 func (m *MockClient) GetBlockNumber(ctx context.Context) (int, error) {
 	return m.blockNumber, nil
 }
 
+func (m *MockClient) Endpoint() string {
+	if m.endpoint != "" {
+		return m.endpoint
+	}
+	return "mock"
+}
+
 func (m *MockClient) GetTxsFromBlock(ctx context.Context, blockNumber int) ([]models.Transaction, error) {
 	return m.txs[blockNumber], nil
 }
@@ -26,68 +46,292 @@ type MockDataStore struct {
 	sync.Mutex
 	currentBlock         int
 	lastProcessedTxIndex int
-	subscribedAddresses  map[models.Address]bool
-	transactions         map[models.Address][]models.Transaction
+	// subscribedAddresses - per address, the history offset each watching tenant starts at
+	subscribedAddresses map[models.Address]map[string]int
+	transactions        map[models.Address][]models.Transaction
+	subscriptions       map[string]models.Subscription
+	matches             map[string][]models.Transaction
 }
 
-func (m *MockDataStore) GetCurrentBlock() int {
+func (m *MockDataStore) GetCurrentBlock(ctx context.Context) int {
 	m.Lock()
 	defer m.Unlock()
 	return m.currentBlock
 }
 
-func (m *MockDataStore) SetCurrentBlock(block int) {
+func (m *MockDataStore) SetCurrentBlock(ctx context.Context, block int) {
 	m.Lock()
 	defer m.Unlock()
 	m.currentBlock = block
 }
 
-func (m *MockDataStore) GetLastProcessedTxIndex() int {
+func (m *MockDataStore) GetLastProcessedTxIndex(ctx context.Context) int {
 	m.Lock()
 	defer m.Unlock()
 	return m.lastProcessedTxIndex
 }
 
-func (m *MockDataStore) SetLastProcessedTxIndex(index int) {
+func (m *MockDataStore) SetLastProcessedTxIndex(ctx context.Context, index int) {
 	m.Lock()
 	defer m.Unlock()
 	m.lastProcessedTxIndex = index
 }
 
-func (m *MockDataStore) AddressExists(address models.Address) bool {
+func (m *MockDataStore) AddressExists(ctx context.Context, address models.Address) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.subscribedAddresses[address]
+	return ok
+}
+
+func (m *MockDataStore) IsSubscriber(ctx context.Context, tenant string, address models.Address) bool {
 	m.Lock()
 	defer m.Unlock()
-	return m.subscribedAddresses[address]
+	_, ok := m.subscribedAddresses[address][tenant]
+	return ok
 }
 
-func (m *MockDataStore) AddSubscriber(address models.Address) {
+func (m *MockDataStore) AddSubscriber(ctx context.Context, tenant string, address models.Address) bool {
 	m.Lock()
 	defer m.Unlock()
 	if m.subscribedAddresses == nil {
-		m.subscribedAddresses = make(map[models.Address]bool)
+		m.subscribedAddresses = make(map[models.Address]map[string]int)
+	}
+	if m.subscribedAddresses[address] == nil {
+		m.subscribedAddresses[address] = make(map[string]int)
 	}
-	m.subscribedAddresses[address] = true
+	if _, ok := m.subscribedAddresses[address][tenant]; ok {
+		return false
+	}
+	m.subscribedAddresses[address][tenant] = len(m.transactions[address])
+	return true
+}
+
+func (m *MockDataStore) RemoveSubscriber(ctx context.Context, tenant string, address models.Address) bool {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.subscribedAddresses[address][tenant]; !ok {
+		return false
+	}
+	delete(m.subscribedAddresses[address], tenant)
+	if len(m.subscribedAddresses[address]) == 0 {
+		delete(m.subscribedAddresses, address)
+		delete(m.transactions, address)
+	}
+	return true
 }
 
-func (m *MockDataStore) AddTx(address models.Address, tx models.Transaction) {
+func (m *MockDataStore) GetSubscribers(ctx context.Context) []models.Address {
 	m.Lock()
 	defer m.Unlock()
+	addrs := make([]models.Address, 0, len(m.subscribedAddresses))
+	for addr := range m.subscribedAddresses {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (m *MockDataStore) GetTenantSubscribers(ctx context.Context, tenant string) []models.Address {
+	m.Lock()
+	defer m.Unlock()
+	addrs := make([]models.Address, 0)
+	for addr, tenants := range m.subscribedAddresses {
+		if _, ok := tenants[tenant]; ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+func (m *MockDataStore) AddTx(ctx context.Context, address models.Address, tx models.Transaction) bool {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.subscribedAddresses[address]; !ok {
+		return false
+	}
 	if m.transactions == nil {
 		m.transactions = make(map[models.Address][]models.Transaction)
 	}
+	for _, stored := range m.transactions[address] {
+		if stored.Key() == tx.Key() {
+			return false
+		}
+	}
 	m.transactions[address] = append(m.transactions[address], tx)
+	return true
+}
+
+func (m *MockDataStore) GetTransactions(ctx context.Context, tenant string, address models.Address) []models.Transaction {
+	m.Lock()
+	defer m.Unlock()
+	offset, ok := m.subscribedAddresses[address][tenant]
+	if !ok {
+		return nil
+	}
+	return append(make([]models.Transaction, 0), m.transactions[address][offset:]...)
+}
+
+func (m *MockDataStore) AddSubscription(ctx context.Context, sub models.Subscription) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.subscriptions == nil {
+		m.subscriptions = make(map[string]models.Subscription)
+	}
+	if _, ok := m.subscriptions[sub.ID]; ok {
+		return data_store.ErrSubscriptionExists
+	}
+	m.subscriptions[sub.ID] = sub
+	return nil
+}
+
+// subscription - must be called with the lock held
+func (m *MockDataStore) subscription(tenant, id string) (models.Subscription, error) {
+	sub, ok := m.subscriptions[id]
+	if !ok || sub.Tenant != tenant {
+		return models.Subscription{}, data_store.ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func (m *MockDataStore) RemoveSubscription(ctx context.Context, tenant, id string) error {
+	m.Lock()
+	defer m.Unlock()
+	if _, err := m.subscription(tenant, id); err != nil {
+		return err
+	}
+	delete(m.subscriptions, id)
+	delete(m.matches, id)
+	return nil
+}
+
+func (m *MockDataStore) GetSubscription(ctx context.Context, tenant, id string) (models.Subscription, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.subscription(tenant, id)
+}
+
+func (m *MockDataStore) GetSubscriptions(ctx context.Context, tenant string) []models.Subscription {
+	return slices.DeleteFunc(m.AllSubscriptions(ctx), func(sub models.Subscription) bool {
+		return sub.Tenant != tenant
+	})
+}
+
+func (m *MockDataStore) AllSubscriptions(ctx context.Context) []models.Subscription {
+	m.Lock()
+	defer m.Unlock()
+	subs := make([]models.Subscription, 0, len(m.subscriptions))
+	for _, sub := range m.subscriptions {
+		subs = append(subs, sub)
+	}
+	slices.SortFunc(subs, func(a, b models.Subscription) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return subs
+}
+
+func (m *MockDataStore) AddMatch(ctx context.Context, id string, tx models.Transaction) bool {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.subscriptions[id]; !ok {
+		return false
+	}
+	if m.matches == nil {
+		m.matches = make(map[string][]models.Transaction)
+	}
+	for _, stored := range m.matches[id] {
+		if stored.Key() == tx.Key() {
+			return false
+		}
+	}
+	m.matches[id] = append(m.matches[id], tx)
+	return true
+}
+
+func (m *MockDataStore) GetMatches(ctx context.Context, tenant, id string) ([]models.Transaction, error) {
+	m.Lock()
+	defer m.Unlock()
+	if _, err := m.subscription(tenant, id); err != nil {
+		return nil, err
+	}
+	return append(make([]models.Transaction, 0), m.matches[id]...), nil
 }
 
-func (m *MockDataStore) GetTransactions(address models.Address) []models.Transaction {
+func (m *MockDataStore) BatchGetMatches(ctx context.Context, tenant string, ids []string) map[string][]models.Transaction {
 	m.Lock()
 	defer m.Unlock()
-	return m.transactions[address]
+	matches := make(map[string][]models.Transaction, len(ids))
+	for _, id := range ids {
+		if _, err := m.subscription(tenant, id); err == nil {
+			matches[id] = append(make([]models.Transaction, 0), m.matches[id]...)
+		}
+	}
+	return matches
+}
+
+func (m *MockDataStore) PurgeAbove(ctx context.Context, block int) int {
+	m.Lock()
+	defer m.Unlock()
+	above := func(tx models.Transaction) bool {
+		number, err := helpers.ParseHexInt(tx.BlockNumber)
+		return err == nil && number > block
+	}
+	purged := 0
+	for addr, txs := range m.transactions {
+		for tenant, offset := range m.subscribedAddresses[addr] {
+			m.subscribedAddresses[addr][tenant] = len(slices.DeleteFunc(slices.Clone(txs[:offset]), above))
+		}
+		kept := slices.DeleteFunc(txs, above)
+		purged += len(txs) - len(kept)
+		m.transactions[addr] = kept
+	}
+	for id, matches := range m.matches {
+		kept := slices.DeleteFunc(matches, above)
+		purged += len(matches) - len(kept)
+		m.matches[id] = kept
+	}
+	return purged
+}
+
+func (m *MockDataStore) Scan(ctx context.Context, q data_store.TxQuery) iter.Seq2[models.Address, models.Transaction] {
+	return func(yield func(models.Address, models.Transaction) bool) {
+		addrs := q.Addresses
+		if addrs == nil {
+			m.Lock()
+			for addr, tenants := range m.subscribedAddresses {
+				if _, ok := tenants[q.Tenant]; ok {
+					addrs = append(addrs, addr)
+				}
+			}
+			m.Unlock()
+			slices.Sort(addrs)
+		}
+		for _, addr := range addrs {
+			for _, tx := range m.GetTransactions(ctx, q.Tenant, addr) {
+				block, _ := helpers.ParseHexInt(tx.BlockNumber)
+				if block < q.FromBlock || (q.ToBlock > 0 && block > q.ToBlock) {
+					continue
+				}
+				if !yield(addr, tx) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// TestMockDataStore - keeps the mock honest to the DataStore contract
+func TestMockDataStore(t *testing.T) {
+	storetest.Run(t, func() data_store.DataStore {
+		return &MockDataStore{}
+	})
 }
 
 func TestParserRuntime_parseTxs(t *testing.T) {
+	ctx := context.Background()
 	mockDataStore := &MockDataStore{}
-	mockDataStore.AddSubscriber("0xdef")
-	mockDataStore.AddSubscriber("0xghi")
+	mockDataStore.AddSubscriber(ctx, models.DefaultTenant, "0xdef")
+	mockDataStore.AddSubscriber(ctx, models.DefaultTenant, "0xghi")
 
 	txs := []models.Transaction{
 		{Hash: "0x123", From: "0xabc", To: "0xdef", TransactionIndex: "0x1", BlockNumber: "0xa"},
@@ -95,27 +339,27 @@ func TestParserRuntime_parseTxs(t *testing.T) {
 	}
 
 	cfg := ParserConfig{Workers: 2}
-	ctx := context.Background()
 	parser := NewParserRuntime(ctx, nil, mockDataStore, cfg)
 
 	err := parser.parseTxs(ctx, &txs)
 	assert.NoError(t, err)
 
 	// Verify transactions were added to the subscribed addresses
-	assert.Len(t, mockDataStore.GetTransactions("0xdef"), 1)
-	assert.Equal(t, "0x123", mockDataStore.GetTransactions("0xdef")[0].Hash)
+	assert.Len(t, mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xdef"), 1)
+	assert.Equal(t, "0x123", mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xdef")[0].Hash)
 
-	assert.Len(t, mockDataStore.GetTransactions("0xghi"), 1)
-	assert.Equal(t, "0x456", mockDataStore.GetTransactions("0xghi")[0].Hash)
+	assert.Len(t, mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xghi"), 1)
+	assert.Equal(t, "0x456", mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xghi")[0].Hash)
 
 	// Verify the current block and last processed transaction index were updated
-	assert.Equal(t, 10, mockDataStore.GetCurrentBlock())        // 0xa in decimal
-	assert.Equal(t, 2, mockDataStore.GetLastProcessedTxIndex()) // 0x2 in decimal
+	assert.Equal(t, 10, mockDataStore.GetCurrentBlock(ctx))        // 0xa in decimal
+	assert.Equal(t, 2, mockDataStore.GetLastProcessedTxIndex(ctx)) // 0x2 in decimal
 }
 
 func TestParserRuntime_matchTx(t *testing.T) {
+	ctx := context.Background()
 	mockDataStore := &MockDataStore{}
-	mockDataStore.AddSubscriber("0xdef")
+	mockDataStore.AddSubscriber(ctx, models.DefaultTenant, "0xdef")
 
 	txStream := make(chan models.Transaction, 2)
 	txStream <- models.Transaction{Hash: "0x123", From: "0xabc", To: "0xdef", TransactionIndex: "0x1", BlockNumber: "0xa"}
@@ -123,15 +367,70 @@ func TestParserRuntime_matchTx(t *testing.T) {
 	close(txStream)
 
 	cfg := ParserConfig{}
-	ctx := context.Background()
 	parser := NewParserRuntime(ctx, nil, mockDataStore, cfg)
 
 	parser.matchTx(ctx, txStream)
 
 	// Verify the transaction was added to the subscribed address
-	assert.Len(t, mockDataStore.GetTransactions("0xdef"), 1)
-	assert.Equal(t, "0x123", mockDataStore.GetTransactions("0xdef")[0].Hash)
+	assert.Len(t, mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xdef"), 1)
+	assert.Equal(t, "0x123", mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xdef")[0].Hash)
 }
+
+func TestParserRuntime_matchTx_creditsEveryParty(t *testing.T) {
+	ctx := context.Background()
+	mockDataStore := &MockDataStore{}
+	mockDataStore.AddSubscriber(ctx, models.DefaultTenant, "0xabc")
+	mockDataStore.AddSubscriber(ctx, models.DefaultTenant, "0xdef")
+
+	tx := models.Transaction{Hash: "0x123", From: "0xabc", To: "0xdef", TransactionIndex: "0x1", BlockNumber: "0xa"}
+	txStream := make(chan models.Transaction, 2)
+	// the same transaction seen twice, e.g. when the current block is re-fetched
+	txStream <- tx
+	txStream <- tx
+	close(txStream)
+
+	parser := NewParserRuntime(ctx, nil, mockDataStore, ParserConfig{})
+
+	parser.matchTx(ctx, txStream)
+
+	assert.Len(t, mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xabc"), 1)
+	assert.Len(t, mockDataStore.GetTransactions(ctx, models.DefaultTenant, "0xdef"), 1)
+}
+func TestParserRuntime_matchTx_rules(t *testing.T) {
+	mockDataStore := &MockDataStore{}
+
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, nil, mockDataStore, ParserConfig{})
+
+	sub, err := parser.CreateSubscription(ctx, models.DefaultTenant, models.Rule{
+		Type: models.RuleOr,
+		Rules: []models.Rule{
+			{Type: models.RuleContractCreation},
+			{Type: models.RuleValueAbove, Value: "1000"},
+		},
+	})
+	require.NoError(t, err)
+
+	txStream := make(chan models.Transaction, 3)
+	txStream <- models.Transaction{Hash: "0x123", From: "0xabc", Value: "0x1"}
+	txStream <- models.Transaction{Hash: "0x456", From: "0xabc", To: "0xdef", Value: "0x3e9"}
+	txStream <- models.Transaction{Hash: "0x789", From: "0xabc", To: "0xdef", Value: "0x1"}
+	close(txStream)
+
+	parser.matchTx(ctx, txStream)
+
+	txs, err := parser.GetSubscriptionTransactions(ctx, models.DefaultTenant, sub.ID)
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, "0x123", txs[0].Hash)
+	assert.Equal(t, "0x456", txs[1].Hash)
+	assert.Equal(t, map[string][]models.Transaction{sub.ID: txs}, parser.BatchGetSubscriptionTransactions(ctx, models.DefaultTenant, []string{sub.ID, "missing"}))
+
+	require.NoError(t, parser.DeleteSubscription(ctx, models.DefaultTenant, sub.ID))
+	_, err = parser.GetSubscriptionTransactions(ctx, models.DefaultTenant, sub.ID)
+	assert.ErrorIs(t, err, data_store.ErrSubscriptionNotFound)
+}
+
 func TestParserRuntime_getNewTxs(t *testing.T) {
 	mockDataStore := &MockDataStore{
 		currentBlock: 10,
@@ -164,3 +463,384 @@ func TestParserRuntime_getNewTxs(t *testing.T) {
 	assert.Equal(t, "0x456", (*txs)[1].Hash)
 	assert.Equal(t, "0x789", (*txs)[2].Hash)
 }
+
+func TestParserRuntime_processNewTxs_emptyBlocks(t *testing.T) {
+	ctx := context.Background()
+	mockDataStore := &MockDataStore{currentBlock: 10}
+	mockClient := &MockClient{
+		blockNumber: 13,
+		txs: map[int][]models.Transaction{
+			11: {{Hash: "0x456", From: "0xghi", To: "0xjkl", TransactionIndex: "0x0", BlockNumber: "0xb"}},
+		},
+	}
+	parser := NewParserRuntime(ctx, mockClient, mockDataStore, ParserConfig{Workers: 1})
+
+	// blocks 12 and 13 have no transactions, they are parsed all the same
+	require.NoError(t, parser.processNewTxs(ctx))
+	assert.Equal(t, 13, parser.GetCurrentBlock(ctx))
+
+	// a node behind the parser does not move it back
+	mockClient.blockNumber = 12
+	require.NoError(t, parser.processNewTxs(ctx))
+	assert.Equal(t, 13, parser.GetCurrentBlock(ctx))
+}
+
+type recordingMetrics struct {
+	blocks  int
+	lag     int
+	matches []int
+}
+
+func (m *recordingMetrics) BlocksProcessed(n int)       { m.blocks += n }
+func (m *recordingMetrics) SetLag(blocks int)           { m.lag = blocks }
+func (m *recordingMetrics) ObserveAddressMatches(n int) { m.matches = append(m.matches, n) }
+
+func TestParserRuntime_processNewTxs_metrics(t *testing.T) {
+	ctx := context.Background()
+	mockDataStore := &MockDataStore{currentBlock: 10}
+	mockDataStore.AddSubscriber(ctx, models.DefaultTenant, "0xdef")
+	mockClient := &MockClient{
+		blockNumber: 12,
+		txs: map[int][]models.Transaction{
+			11: {{Hash: "0x456", From: "0xabc", To: "0xdef", TransactionIndex: "0x0", BlockNumber: "0xb"}},
+			12: {{Hash: "0x789", From: "0xdef", To: "0xghi", TransactionIndex: "0x0", BlockNumber: "0xc"}},
+		},
+	}
+	metrics := &recordingMetrics{}
+
+	parser := NewParserRuntime(ctx, mockClient, mockDataStore, ParserConfig{Workers: 1}, WithMetrics(metrics))
+
+	require.NoError(t, parser.processNewTxs(ctx))
+
+	assert.Equal(t, 2, metrics.blocks)
+	assert.Equal(t, 0, metrics.lag)
+	assert.Equal(t, []int{2}, metrics.matches)
+
+	// a node behind the parser processes no blocks rather than a negative number
+	mockClient.blockNumber = 11
+	require.NoError(t, parser.processNewTxs(ctx))
+	assert.Equal(t, 2, metrics.blocks)
+}
+
+func TestParserRuntime_processNewTxs_tracing(t *testing.T) {
+	ctx := context.Background()
+	mockDataStore := &MockDataStore{currentBlock: 10}
+	mockClient := &MockClient{
+		blockNumber: 12,
+		txs: map[int][]models.Transaction{
+			11: {{Hash: "0x456", From: "0xabc", To: "0xdef", TransactionIndex: "0x0", BlockNumber: "0xb"}},
+			12: {{Hash: "0x789", From: "0xdef", To: "0xghi", TransactionIndex: "0x0", BlockNumber: "0xc"}},
+		},
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	parser := NewParserRuntime(ctx, mockClient, mockDataStore, ParserConfig{Workers: 1}, WithTracerProvider(tp))
+
+	require.NoError(t, parser.processNewTxs(ctx))
+
+	spans := exporter.GetSpans()
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	// blocks 10 to 12 are fetched, children end before the tick
+	assert.Equal(t, []string{"parser.fetch_block", "parser.fetch_block", "parser.fetch_block", "parser.match", "parser.tick"}, names)
+
+	tick := spans[len(spans)-1]
+	for _, span := range spans[:len(spans)-1] {
+		assert.Equal(t, tick.SpanContext.SpanID(), span.Parent.SpanID())
+		assert.Equal(t, tick.SpanContext.TraceID(), span.SpanContext.TraceID())
+	}
+}
+
+func TestParserRuntime_storeSpansInTick(t *testing.T) {
+	ctx := context.Background()
+	mockClient := &MockClient{
+		blockNumber: 12,
+		txs: map[int][]models.Transaction{
+			12: {{Hash: "0x789", From: "0xdef", To: "0xghi", TransactionIndex: "0x0", BlockNumber: "0xc"}},
+		},
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	store := data_store.NewTracedDataStore(&MockDataStore{currentBlock: 11}, tp)
+
+	parser := NewParserRuntime(ctx, mockClient, store, ParserConfig{Workers: 1}, WithTracerProvider(tp))
+	require.NoError(t, parser.processNewTxs(ctx))
+
+	spans := exporter.GetSpans()
+	tick := spans[len(spans)-1]
+	require.Equal(t, "parser.tick", tick.Name)
+	reads := 0
+	for _, span := range spans {
+		if span.Name == "store.GetCurrentBlock" {
+			reads++
+			assert.Equal(t, tick.SpanContext.TraceID(), span.SpanContext.TraceID())
+		}
+	}
+	// the current block is read within the tick, not under the parser's root context
+	assert.Positive(t, reads)
+}
+
+func TestParserRuntime_Status(t *testing.T) {
+	mockDataStore := &MockDataStore{}
+	mockClient := &MockClient{
+		blockNumber: 12,
+		txs: map[int][]models.Transaction{
+			12: {{Hash: "0x789", From: "0xdef", To: "0xghi", TransactionIndex: "0x0", BlockNumber: "0xc"}},
+		},
+	}
+
+	ctx := context.Background()
+	parser := NewParserRuntime(ctx, mockClient, mockDataStore, ParserConfig{Workers: 1})
+
+	status := parser.Status()
+	assert.True(t, status.LastSuccessfulTick.IsZero())
+	assert.Equal(t, "mock", status.Endpoint)
+
+	parser.recordTick(parser.processNewTxs(ctx))
+	parser.recordTick(assert.AnError)
+
+	status = parser.Status()
+	assert.False(t, status.LastSuccessfulTick.IsZero())
+	assert.Equal(t, 12, status.RemoteHead)
+	assert.Equal(t, 12, status.CurrentBlock)
+	assert.Zero(t, status.Lag())
+	assert.Equal(t, assert.AnError.Error(), status.LastError)
+}
+
+func TestParserRuntime_Reconfigure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockDataStore := &MockDataStore{}
+	oldClient := &MockClient{blockNumber: 5, endpoint: "old"}
+	newClient := &MockClient{
+		blockNumber: 7,
+		endpoint:    "new",
+		txs: map[int][]models.Transaction{
+			7: {{Hash: "0x789", From: "0xdef", To: "0xghi", TransactionIndex: "0x0", BlockNumber: "0x7"}},
+		},
+	}
+	parser := NewParserRuntime(ctx, oldClient, mockDataStore, ParserConfig{TxFetchInterval: time.Hour, Workers: 1, ExpectedSubscribers: 8})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parser.Parse()
+	}()
+
+	// the hour long ticker would never fire, the reload wakes the loop and shortens it
+	parser.Reconfigure(newClient, ParserConfig{TxFetchInterval: 10 * time.Millisecond, Workers: 2, ExpectedSubscribers: 1024})
+
+	require.Eventually(t, func() bool {
+		return parser.Status().CurrentBlock == 7
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "new", parser.Status().Endpoint)
+
+	cancel()
+	<-done
+	assert.Equal(t, 2, parser.cfg.Workers)
+	assert.Equal(t, 8, parser.cfg.ExpectedSubscribers)
+}
+
+type failingClient struct {
+	MockClient
+}
+
+func (c *failingClient) GetTxsFromBlock(ctx context.Context, blockNumber int) ([]models.Transaction, error) {
+	return nil, assert.AnError
+}
+
+func TestParserRuntime_PauseResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockClient := &MockClient{
+		blockNumber: 7,
+		txs: map[int][]models.Transaction{
+			7: {{Hash: "0x789", From: "0xdef", To: "0xghi", TransactionIndex: "0x0", BlockNumber: "0x7"}},
+		},
+	}
+	parser := NewParserRuntime(ctx, mockClient, &MockDataStore{}, ParserConfig{TxFetchInterval: 5 * time.Millisecond, Workers: 1})
+	parser.Pause()
+	parser.Pause()
+	require.True(t, parser.Status().Paused)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parser.Parse()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, parser.Status().CurrentBlock)
+
+	parser.Resume()
+	require.Eventually(t, func() bool {
+		return parser.Status().CurrentBlock == 7
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, parser.Status().Paused)
+
+	cancel()
+	<-done
+}
+
+// rewindFixture - a parser that parsed blocks 10 to 12, block 11 as served by a faulty provider
+func rewindFixture(t *testing.T) (*ParserRuntime, *MockClient, *MockDataStore, models.Address) {
+	t.Helper()
+	ctx := t.Context()
+	addr := storetest.Address(1)
+	tx := func(hash string, block int) models.Transaction {
+		return models.Transaction{Hash: hash, From: addr, To: "0xdef", TransactionIndex: "0x0", BlockNumber: fmt.Sprintf("0x%x", block)}
+	}
+	mockClient := &MockClient{
+		blockNumber: 12,
+		txs: map[int][]models.Transaction{
+			10: {tx("0x10", 10)},
+			11: {tx("0xbad", 11)},
+			12: {tx("0x12", 12)},
+		},
+	}
+	mockDataStore := &MockDataStore{}
+	parser := NewParserRuntime(ctx, mockClient, mockDataStore, ParserConfig{Workers: 2})
+	require.True(t, parser.Subscribe(ctx, models.DefaultTenant, addr))
+	mockDataStore.SetCurrentBlock(ctx, 10)
+	require.NoError(t, parser.processNewTxs(ctx))
+	require.Len(t, parser.GetTransactions(ctx, models.DefaultTenant, addr), 3)
+
+	mockClient.txs[11] = []models.Transaction{tx("0x11", 11), tx("0x11b", 11)}
+	return parser, mockClient, mockDataStore, addr
+}
+
+func TestParserRuntime_Rewind(t *testing.T) {
+	ctx := t.Context()
+	parser, _, _, addr := rewindFixture(t)
+
+	for _, block := range []int{0, -1, 13} {
+		_, err := parser.Rewind(ctx, block, true)
+		require.ErrorIs(t, err, ErrInvalidBlock)
+	}
+	require.Equal(t, 12, parser.GetCurrentBlock(ctx))
+
+	purged, err := parser.Rewind(ctx, 10, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.Equal(t, 10, parser.GetCurrentBlock(ctx))
+	assert.Len(t, parser.GetTransactions(ctx, models.DefaultTenant, addr), 1)
+
+	require.NoError(t, parser.processNewTxs(ctx))
+	var hashes []string
+	for _, tx := range parser.GetTransactions(ctx, models.DefaultTenant, addr) {
+		hashes = append(hashes, tx.Hash)
+	}
+	assert.ElementsMatch(t, []string{"0x10", "0x11", "0x11b", "0x12"}, hashes)
+	assert.Equal(t, 12, parser.GetCurrentBlock(ctx))
+
+	// without purge the bad transactions stay, only the missing ones are added
+	purged, err = parser.Rewind(ctx, 12, false)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func TestParserRuntime_Rescan(t *testing.T) {
+	ctx := t.Context()
+	parser, mockClient, _, addr := rewindFixture(t)
+
+	for _, r := range [][2]int{{0, 1}, {12, 11}, {1, MaxRescanBlocks + 1}, {11, 13}} {
+		_, err := parser.Rescan(ctx, r[0], r[1])
+		require.ErrorIs(t, err, ErrInvalidBlock, "%d..%d", r[0], r[1])
+	}
+
+	matches, err := parser.Rescan(ctx, 10, 12)
+	require.NoError(t, err)
+	assert.Equal(t, 2, matches)
+	assert.Len(t, parser.GetTransactions(ctx, models.DefaultTenant, addr), 5)
+	assert.Equal(t, 12, parser.GetCurrentBlock(ctx))
+
+	matches, err = parser.Rescan(ctx, 10, 12)
+	require.NoError(t, err)
+	assert.Zero(t, matches)
+
+	parser.client = &failingClient{MockClient: *mockClient}
+	_, err = parser.Rescan(ctx, 11, 11)
+	require.ErrorIs(t, err, ErrFetchFailed)
+	require.ErrorIs(t, err, assert.AnError)
+}
+
+func TestParserRuntime_Replay(t *testing.T) {
+	ctx := t.Context()
+	addr := storetest.Address(1)
+	tx := func(hash string, block int) models.Transaction {
+		return models.Transaction{Hash: hash, From: addr, To: "0xdef", TransactionIndex: "0x0", BlockNumber: fmt.Sprintf("0x%x", block)}
+	}
+	// the head is never asked for, blocks after it are replayed all the same
+	mockClient := &MockClient{
+		txs: map[int][]models.Transaction{
+			3: {tx("0x3", 3)},
+			5: {tx("0x5", 5), tx("0x5b", 5)},
+		},
+	}
+	metrics := &recordingMetrics{}
+	parser := NewParserRuntime(ctx, mockClient, &MockDataStore{}, ParserConfig{Workers: 2}, WithMetrics(metrics))
+	require.True(t, parser.Subscribe(ctx, models.DefaultTenant, addr))
+
+	for _, r := range [][2]int{{0, 1}, {5, 4}} {
+		_, _, err := parser.Replay(ctx, r[0], r[1])
+		require.ErrorIs(t, err, ErrInvalidBlock, "%d..%d", r[0], r[1])
+	}
+
+	matches, missing, err := parser.Replay(ctx, 3, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 3, matches)
+	assert.Zero(t, missing)
+	assert.Len(t, parser.GetTransactions(ctx, models.DefaultTenant, addr), 3)
+	assert.Equal(t, 5, parser.GetCurrentBlock(ctx))
+	assert.Equal(t, 3, metrics.blocks)
+	assert.Zero(t, metrics.lag)
+
+	parser.client = &failingClient{MockClient: *mockClient}
+	_, _, err = parser.Replay(ctx, 6, 7)
+	require.ErrorIs(t, err, ErrFetchFailed)
+	assert.Equal(t, 5, parser.GetCurrentBlock(ctx))
+}
+
+// gappedClient - an archive without some of its blocks
+type gappedClient struct {
+	MockClient
+	missing map[int]bool
+}
+
+func (c *gappedClient) GetTxsFromBlock(ctx context.Context, blockNumber int) ([]models.Transaction, error) {
+	if c.missing[blockNumber] {
+		return nil, fmt.Errorf("%w: %d", client.ErrBlockNotFound, blockNumber)
+	}
+	return c.MockClient.GetTxsFromBlock(ctx, blockNumber)
+}
+
+func TestParserRuntime_ReplayGaps(t *testing.T) {
+	ctx := t.Context()
+	addr := storetest.Address(1)
+	tx := func(hash string, block int) models.Transaction {
+		return models.Transaction{Hash: hash, From: addr, To: "0xdef", TransactionIndex: "0x0", BlockNumber: fmt.Sprintf("0x%x", block)}
+	}
+	gapped := &gappedClient{
+		MockClient: MockClient{txs: map[int][]models.Transaction{
+			3: {tx("0x3", 3)},
+			6: {tx("0x6", 6)},
+		}},
+		missing: map[int]bool{4: true, 5: true},
+	}
+	metrics := &recordingMetrics{}
+	parser := NewParserRuntime(ctx, gapped, &MockDataStore{}, ParserConfig{Workers: 1}, WithMetrics(metrics))
+	require.True(t, parser.Subscribe(ctx, models.DefaultTenant, addr))
+
+	// the blocks around the gap are replayed, the missing ones are counted
+	matches, missing, err := parser.Replay(ctx, 3, 6)
+	require.NoError(t, err)
+	assert.Equal(t, 2, matches)
+	assert.Equal(t, 2, missing)
+	assert.Equal(t, 6, parser.GetCurrentBlock(ctx))
+	assert.Equal(t, 2, metrics.blocks)
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	l := New(rate, burst)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestAllowBurstThenRefill(t *testing.T) {
+	l, clock := newTestLimiter(2, 3)
+
+	for range 3 {
+		ok, _ := l.Allow("a")
+		require.True(t, ok)
+	}
+	ok, wait := l.Allow("a")
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	// other clients have their own bucket
+	ok, _ = l.Allow("b")
+	require.True(t, ok)
+
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	require.True(t, ok)
+	ok, _ = l.Allow("a")
+	require.False(t, ok)
+
+	// refills never exceed the burst
+	clock.t = clock.t.Add(time.Hour)
+	for range 3 {
+		ok, _ = l.Allow("a")
+		require.True(t, ok)
+	}
+	ok, _ = l.Allow("a")
+	require.False(t, ok)
+}
+
+func TestSweepDropsIdleClients(t *testing.T) {
+	l, clock := newTestLimiter(1, 1)
+	l.Allow("a")
+	l.Allow("b")
+	require.Equal(t, 2, l.Len())
+
+	clock.t = clock.t.Add(sweepInterval)
+	l.Allow("c")
+	require.Equal(t, 1, l.Len())
+}
+
+func TestAllowConcurrent(t *testing.T) {
+	l, _ := newTestLimiter(1, 100)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if ok, _ := l.Allow(fmt.Sprint(i % 2)); ok {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the clock stands still, so each of the two clients gets exactly its burst
+	require.Equal(t, 200, allowed)
+}
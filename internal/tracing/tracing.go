@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Attribute keys shared by the instrumented packages
+const (
+	KeyBlock     = attribute.Key("block.number")
+	KeyAddress   = attribute.Key("eth.address")
+	KeyRPCMethod = attribute.Key("rpc.method")
+)
+
+type Config struct {
+	// Exporter - none, stdout or otlp
+	Exporter string
+	// OTLPEndpoint - host:port of the OTLP/HTTP collector, the exporter default when empty
+	OTLPEndpoint string
+	// OTLPInsecure - plain HTTP to the collector
+	OTLPInsecure bool
+	ServiceName  string
+}
+
+// Shutdown - flushes pending spans and stops the exporter
+type Shutdown func(ctx context.Context) error
+
+// Setup - tracer provider for the configured exporter, stdout spans go to w
+func Setup(ctx context.Context, cfg Config, w io.Writer) (trace.TracerProvider, Shutdown, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return Noop(), func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		opts := make([]otlptracehttp.Option, 0, 2)
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+		}
+		if cfg.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, nil, fmt.Errorf("invalid trace exporter %q, want %s, %s or %s", cfg.Exporter, ExporterNone, ExporterStdout, ExporterOTLP)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+
+	return tp, tp.Shutdown, nil
+}
+
+// Noop - default provider of components that were not given one
+func Noop() trace.TracerProvider {
+	return noop.NewTracerProvider()
+}
+
+// End - records err, when there is one, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Propagator - W3C trace context and baggage carried in HTTP headers
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupStdout(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	tp, shutdown, err := Setup(ctx, Config{Exporter: ExporterStdout, ServiceName: "test"}, &buf)
+	require.NoError(t, err)
+
+	_, span := tp.Tracer("test").Start(ctx, "tick")
+	span.End()
+	require.NoError(t, shutdown(ctx))
+
+	require.Contains(t, buf.String(), `"Name":"tick"`)
+	require.Contains(t, buf.String(), `"Value":"test"`)
+}
+
+func TestSetupNone(t *testing.T) {
+	ctx := context.Background()
+	tp, shutdown, err := Setup(ctx, Config{Exporter: ExporterNone}, nil)
+	require.NoError(t, err)
+
+	_, span := tp.Tracer("test").Start(ctx, "tick")
+	require.False(t, span.SpanContext().IsValid())
+	require.NoError(t, shutdown(ctx))
+}
+
+func TestEnd(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	_, span := tracer.Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = tracer.Start(context.Background(), "failed")
+	End(span, errors.New("boom"))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, codes.Unset, spans[0].Status.Code)
+	require.Empty(t, spans[0].Events)
+	require.Equal(t, codes.Error, spans[1].Status.Code)
+	require.Equal(t, "boom", spans[1].Status.Description)
+	require.Len(t, spans[1].Events, 1)
+}
+
+func TestSetupInvalidExporter(t *testing.T) {
+	_, _, err := Setup(context.Background(), Config{Exporter: "jaeger"}, nil)
+	require.ErrorContains(t, err, "invalid trace exporter")
+}
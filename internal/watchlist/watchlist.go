@@ -0,0 +1,112 @@
+package watchlist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+)
+
+// Item - a line of a watchlist holding an address
+type Item struct {
+	Line int
+	// Input - the address as written
+	Input string
+	// Address - Input lower cased, not validated
+	Address models.Address
+}
+
+// Items - one address per line, or the first column of a CSV file. Blank lines, lines starting with #
+// and an "address" header are skipped.
+func Items(r io.Reader) ([]Item, error) {
+	items := make([]Item, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), ",")
+		text = strings.Trim(strings.TrimSpace(text), `"`)
+		if text == "" || strings.HasPrefix(text, "#") || (line == 1 && strings.EqualFold(text, "address")) {
+			continue
+		}
+		items = append(items, Item{
+			Line:    line,
+			Input:   text,
+			Address: models.NormalizeAddress(text),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Parse - the valid addresses of Items, duplicates are dropped. Any invalid address fails the whole list.
+func Parse(r io.Reader) ([]models.Address, error) {
+	items, err := Items(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		addrs = make([]models.Address, 0, len(items))
+		seen  = make(map[models.Address]struct{}, len(items))
+		errs  []error
+	)
+	for _, item := range items {
+		if !item.Address.Valid() {
+			errs = append(errs, fmt.Errorf("line %d: invalid address %q", item.Line, item.Input))
+			continue
+		}
+		if _, ok := seen[item.Address]; ok {
+			continue
+		}
+		seen[item.Address] = struct{}{}
+		addrs = append(addrs, item.Address)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return addrs, nil
+}
+
+// Read - parses the watchlist file at path
+func Read(path string) ([]models.Address, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watchlist: %w", err)
+	}
+	defer f.Close()
+
+	addrs, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse watchlist %s: %w", path, err)
+	}
+
+	return addrs, nil
+}
+
+// Diff - addresses of next missing from prev and addresses of prev missing from next
+func Diff(prev, next []models.Address) (added, removed []models.Address) {
+	inPrev := make(map[models.Address]struct{}, len(prev))
+	for _, addr := range prev {
+		inPrev[addr] = struct{}{}
+	}
+	inNext := make(map[models.Address]struct{}, len(next))
+	for _, addr := range next {
+		inNext[addr] = struct{}{}
+		if _, ok := inPrev[addr]; !ok {
+			added = append(added, addr)
+		}
+	}
+	for _, addr := range prev {
+		if _, ok := inNext[addr]; !ok {
+			removed = append(removed, addr)
+		}
+	}
+
+	return added, removed
+}
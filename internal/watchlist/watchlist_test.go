@@ -0,0 +1,61 @@
+package watchlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/galecic/ethereum_parser/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	addrA = models.Address("0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497")
+	addrB = models.Address("0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5")
+	addrC = models.Address("0x4838b106fce9647bdf1e7877bf73ce8b0bad5f97")
+)
+
+func TestParse(t *testing.T) {
+	input := `# treasury
+0xB0BC44CA9EF6EB6F4EAAC6807C9F6307F8136497
+
+  0x95222290dd7278aa3ddd389cc1e1d165cc4bafe5
+0xb0bc44ca9ef6eb6f4eaac6807c9f6307f8136497
+`
+	addrs, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []models.Address{addrA, addrB}, addrs)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("0x123\n" + string(addrA) + "\nnope\n"))
+	require.ErrorContains(t, err, `line 1: invalid address "0x123"`)
+	require.ErrorContains(t, err, `line 3: invalid address "nope"`)
+}
+
+func TestParseCSV(t *testing.T) {
+	input := "address,label\n" + string(addrA) + ",treasury\n\"" + string(addrB) + "\",\"hot, wallet\"\n"
+	addrs, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, []models.Address{addrA, addrB}, addrs)
+}
+
+func TestItems(t *testing.T) {
+	items, err := Items(strings.NewReader("# deposits\n0xB0BC44CA9EF6EB6F4EAAC6807C9F6307F8136497\n\nnope\n"))
+	require.NoError(t, err)
+	require.Equal(t, []Item{
+		{Line: 2, Input: "0xB0BC44CA9EF6EB6F4EAAC6807C9F6307F8136497", Address: addrA},
+		{Line: 4, Input: "nope", Address: "nope"},
+	}, items)
+}
+
+func TestParseEmpty(t *testing.T) {
+	addrs, err := Parse(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Empty(t, addrs)
+}
+
+func TestDiff(t *testing.T) {
+	added, removed := Diff([]models.Address{addrA, addrB}, []models.Address{addrB, addrC})
+	require.Equal(t, []models.Address{addrC}, added)
+	require.Equal(t, []models.Address{addrA}, removed)
+}